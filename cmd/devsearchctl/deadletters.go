@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+	"github.com/KingrogKDR/Dev-Search/internal/streams"
+)
+
+const deadLettersUsage = `usage: devsearchctl deadletters <command> [flags] [message ids]
+
+commands:
+  list      list dead indexer messages
+  show <id> print a dead message with its attempt history
+  redrive   move dead messages back into parser-events
+  purge     delete dead messages
+
+flags for list, redrive and purge:
+  -error <text>  only messages whose last error contains text
+  -all           allow redrive or purge without ids or filters`
+
+func runDeadLettersCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println(deadLettersUsage)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("deadletters "+args[0], flag.ExitOnError)
+	fs.Usage = func() { fmt.Println(deadLettersUsage) }
+	errText := fs.String("error", "", "")
+	all := fs.Bool("all", false, "")
+	fs.Parse(args[1:])
+
+	filter := streams.DeadLetterFilter{
+		IDs:   fs.Args(),
+		Error: *errText,
+	}
+
+	stream := streams.NewMsgStream(storage.GetRedisClient(), "parser", "indexer")
+
+	var err error
+
+	switch args[0] {
+	case "list":
+		var dead []streams.DeadLetter
+		dead, err = stream.DeadLetters(filter)
+		for _, d := range dead {
+			fmt.Printf("%-52s %-20s %d attempts\n  %s\n", d.Msg.ID, age(d.Msg.DeadAt), len(d.Msg.Attempts), d.Msg.LastError)
+		}
+
+	case "show":
+		if len(filter.IDs) != 1 {
+			fmt.Println(deadLettersUsage)
+			os.Exit(2)
+		}
+
+		var dead []streams.DeadLetter
+		dead, err = stream.DeadLetters(filter)
+		if err == nil && len(dead) == 0 {
+			err = fmt.Errorf("no dead message %s in %s", filter.IDs[0], stream.DeadStreamName())
+		}
+		if err == nil {
+			out, _ := json.MarshalIndent(dead[0], "", "  ")
+			fmt.Println(string(out))
+		}
+
+	case "redrive", "purge":
+		if filter.Empty() && !*all {
+			log.Fatal("give message ids or a filter, or pass -all")
+		}
+
+		var n int
+		if args[0] == "redrive" {
+			n, err = stream.Redrive(filter)
+		} else {
+			n, err = stream.PurgeDeadLetters(filter)
+		}
+		fmt.Printf("%s: %d messages\n", args[0], n)
+
+	default:
+		fmt.Println(deadLettersUsage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
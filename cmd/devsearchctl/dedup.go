@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/KingrogKDR/Dev-Search/internal/config"
+	"github.com/KingrogKDR/Dev-Search/internal/indexer"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/deduplication"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+	"github.com/KingrogKDR/Dev-Search/internal/storage/db"
+)
+
+const dedupUsage = `usage: devsearchctl dedup <command>
+
+commands:
+  cluster <url>          duplicate cluster of a url, its canonical member and
+                         the aliases search lists as "also at"
+  eval [flags] <pairs>   precision and recall of the dedup algorithms on
+                         labeled page pairs
+
+flags for eval:
+  -algorithm simhash,minhash  algorithms to run
+  -shingle n                  words per shingle (default dedup.shingle_size)
+  -max-dist 1,2,3             simhash distances to try, at most 3 (default dedup.max_hamming_dist)
+  -lsh 16x8,20x5              minhash bands x rows to try (default dedup.minhash)
+  -threshold t                minhash similarity a candidate needs (default dedup.minhash.threshold)
+  -v                          list the pairs each setting got wrong`
+
+func runDedupCommand(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Println(dedupUsage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "cluster":
+		if len(args) != 2 {
+			fmt.Println(dedupUsage)
+			os.Exit(2)
+		}
+		showCluster(cfg, args[1])
+	case "eval":
+		runDedupEval(cfg, args[1:])
+	default:
+		fmt.Println(dedupUsage)
+		os.Exit(2)
+	}
+}
+
+func showCluster(cfg *config.Config, u string) {
+	ctx := context.Background()
+
+	clusters := deduplication.NewClusters(storage.GetRedisClient(), cfg.Dedup.CanonicalBy)
+	cluster, err := clusters.Lookup(ctx, u)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cluster == nil {
+		fmt.Printf("%s has no known duplicates\n", u)
+		return
+	}
+
+	fmt.Printf("cluster %s, version %d, canonical %s\n", cluster.ID, cluster.Version, cluster.Canonical)
+	for _, m := range cluster.Members {
+		mark := " "
+		if m.URL == cluster.Canonical {
+			mark = "*"
+		}
+		fmt.Printf("%s %-60s %5d inbound  joined %s", mark, m.URL, m.Authority, m.JoinedAt.Format("2006-01-02 15:04"))
+		if m.Canonical != "" {
+			fmt.Printf("  rel=canonical %s", m.Canonical)
+		}
+		fmt.Println()
+	}
+	if cluster.Dropped > 0 {
+		fmt.Printf("  and %d more not kept\n", cluster.Dropped)
+	}
+
+	db.InitDB(cfg.Postgres.DSN)
+	defer db.Pool.Close()
+
+	alsoAt, err := indexer.AlsoAt(ctx, cluster.Canonical)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("\nindexed as %s, also at %d urls\n", cluster.Canonical, len(alsoAt))
+	for _, u := range alsoAt {
+		fmt.Printf("  %s\n", u)
+	}
+}
+
+func runDedupEval(cfg *config.Config, args []string) {
+	fs := flag.NewFlagSet("dedup eval", flag.ExitOnError)
+	algorithms := fs.String("algorithm", "simhash,minhash", "algorithms to run")
+	shingle := fs.Int("shingle", cfg.Dedup.ShingleSize, "words per shingle")
+	maxDists := fs.String("max-dist", strconv.Itoa(cfg.Dedup.MaxHammingDist), "simhash distances to try")
+	lsh := fs.String("lsh", fmt.Sprintf("%dx%d", cfg.Dedup.MinHash.Bands, cfg.Dedup.MinHash.Rows), "minhash bands x rows to try")
+	threshold := fs.Float64("threshold", cfg.Dedup.MinHash.Threshold, "minhash similarity a candidate needs")
+	verbose := fs.Bool("v", false, "list the pairs each setting got wrong")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 || *shingle <= 0 {
+		fmt.Println(dedupUsage)
+		os.Exit(2)
+	}
+
+	pairs, err := deduplication.LoadPairs(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	type setting struct {
+		algorithm, params string
+		dedup             func() deduplication.Deduplicator
+	}
+	var settings []setting
+
+	for _, algorithm := range strings.Split(*algorithms, ",") {
+		switch strings.TrimSpace(algorithm) {
+		case deduplication.ALGORITHM_SIMHASH:
+			for _, d := range strings.Split(*maxDists, ",") {
+				dist, err := strconv.Atoi(strings.TrimSpace(d))
+				if err != nil || dist < 0 || dist > deduplication.MaxBucketDist {
+					log.Fatalf("invalid -max-dist %q, want 0 to %d", d, deduplication.MaxBucketDist)
+				}
+				settings = append(settings, setting{
+					algorithm: deduplication.ALGORITHM_SIMHASH,
+					params:    fmt.Sprintf("shingle=%d max_dist=%d", *shingle, dist),
+					dedup: func() deduplication.Deduplicator {
+						return deduplication.NewSimhashDeduplicator(deduplication.NewSimIndex(), *shingle, dist)
+					},
+				})
+			}
+
+		case deduplication.ALGORITHM_MINHASH:
+			for _, br := range strings.Split(*lsh, ",") {
+				var mc deduplication.MinHashConfig
+				if _, err := fmt.Sscanf(strings.TrimSpace(br), "%dx%d", &mc.Bands, &mc.Rows); err != nil {
+					log.Fatalf("invalid -lsh %q, want <bands>x<rows>", br)
+				}
+				mc.Threshold = *threshold
+				if err := mc.Validate(); err != nil {
+					log.Fatalf("invalid -lsh %q: %v", br, err)
+				}
+				settings = append(settings, setting{
+					algorithm: deduplication.ALGORITHM_MINHASH,
+					params:    fmt.Sprintf("shingle=%d lsh=%dx%d threshold=%.2f", *shingle, mc.Bands, mc.Rows, mc.Threshold),
+					dedup: func() deduplication.Deduplicator {
+						return deduplication.NewMinHashIndex(mc, *shingle, 0)
+					},
+				})
+			}
+
+		default:
+			log.Fatalf("unknown algorithm %q", algorithm)
+		}
+	}
+
+	duplicates := 0
+	for _, p := range pairs {
+		if p.Duplicate {
+			duplicates++
+		}
+	}
+	fmt.Printf("%d pairs, %d duplicate and %d distinct\n\n", len(pairs), duplicates, len(pairs)-duplicates)
+	fmt.Printf("%-8s %-40s %9s %7s %6s %5s %5s %5s %5s\n", "ALGO", "PARAMS", "PRECISION", "RECALL", "F1", "TP", "FP", "FN", "TN")
+
+	for _, s := range settings {
+		res, err := deduplication.Evaluate(context.Background(), s.dedup, pairs)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("%-8s %-40s %9.3f %7.3f %6.3f %5d %5d %5d %5d\n", s.algorithm, s.params,
+			res.Precision(), res.Recall(), res.F1(),
+			res.TruePositives, res.FalsePositives, res.FalseNegatives, res.TrueNegatives)
+
+		if *verbose {
+			if len(res.Missed) > 0 {
+				fmt.Printf("         missed duplicates on lines %v\n", res.Missed)
+			}
+			if len(res.Wrong) > 0 {
+				fmt.Printf("         false duplicates on lines %v\n", res.Wrong)
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+)
+
+const dlqUsage = `usage: devsearchctl dlq <command> [flags] [job ids]
+
+commands:
+  list      list dead jobs
+  reasons   count dead jobs by error class
+  show <id> print a dead job and its last result
+  requeue   put dead jobs back with a fresh retry budget
+  purge     delete dead jobs
+
+flags for list, reasons, requeue and purge:
+  -queue frontier|parser  dead-letter list to use (default frontier)
+  -host <host>            only jobs for this host
+  -error <text>           only jobs whose last error contains text
+  -min-age <duration>     only jobs that failed at least this long ago
+  -max-age <duration>     only jobs that failed at most this long ago
+  -all                    allow requeue or purge without ids or filters`
+
+func runDLQCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println(dlqUsage)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("dlq "+args[0], flag.ExitOnError)
+	fs.Usage = func() { fmt.Println(dlqUsage) }
+	queueName := fs.String("queue", "frontier", "")
+	host := fs.String("host", "", "")
+	errText := fs.String("error", "", "")
+	minAge := fs.Duration("min-age", 0, "")
+	maxAge := fs.Duration("max-age", 0, "")
+	all := fs.Bool("all", false, "")
+	fs.Parse(args[1:])
+
+	filter := queues.DLQFilter{
+		IDs:    fs.Args(),
+		Host:   *host,
+		Error:  *errText,
+		MinAge: *minAge,
+		MaxAge: *maxAge,
+	}
+
+	ctx := context.Background()
+	opts := queues.DefaultOptions()
+	opts.MergeURLs = *queueName == "frontier"
+	q := queues.NewQueue(storage.GetRedisClient(), *queueName, opts)
+
+	var err error
+
+	switch args[0] {
+	case "list":
+		var dead []queues.DeadJob
+		dead, err = q.DeadJobs(ctx, filter)
+		for _, d := range dead {
+			fmt.Printf("%-36s %-13s %-20s %s\n  %s\n", d.Job.ID, d.ErrorClass, age(d.Job.FailedAt), d.Job.URL, d.Job.ErrorMsg)
+		}
+
+	case "reasons":
+		var dead []queues.DeadJob
+		dead, err = q.DeadJobs(ctx, filter)
+		fmt.Printf("%-14s %7s  %s\n", "CLASS", "COUNT", "EXAMPLE")
+		for _, r := range queues.FailureReasons(dead) {
+			fmt.Printf("%-14s %7d  %s\n", r.ErrorClass, r.Count, r.Example)
+		}
+
+	case "show":
+		if len(filter.IDs) != 1 {
+			fmt.Println(dlqUsage)
+			os.Exit(2)
+		}
+
+		var entry *queues.DeadJob
+		entry, err = q.DeadJob(ctx, filter.IDs[0])
+		if err == nil && entry == nil {
+			err = fmt.Errorf("no dead job %s in %s", filter.IDs[0], *queueName)
+		}
+		if err == nil {
+			out, _ := json.MarshalIndent(entry, "", "  ")
+			fmt.Println(string(out))
+		}
+
+	case "requeue", "purge":
+		if filter.Empty() && !*all {
+			log.Fatal("give job ids or a filter, or pass -all")
+		}
+
+		var n int
+		if args[0] == "requeue" {
+			n, err = q.RequeueDead(ctx, filter)
+		} else {
+			n, err = q.PurgeDead(ctx, filter)
+		}
+		fmt.Printf("%s: %d jobs\n", args[0], n)
+
+	default:
+		fmt.Println(dlqUsage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func age(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return time.Since(t).Round(time.Second).String() + " ago"
+}
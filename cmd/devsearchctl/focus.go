@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/KingrogKDR/Dev-Search/internal/config"
+	"github.com/KingrogKDR/Dev-Search/internal/indexer"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/focus"
+	"github.com/KingrogKDR/Dev-Search/internal/storage/db"
+)
+
+const focusUsage = `usage: devsearchctl focus <command>
+
+commands:
+  train [-out file] [-labels file] [-holdout n]   train the focus classifier from the indexed corpus
+  score [-model file] <text>                      relevance of a piece of text`
+
+func runFocusCommand(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Println(focusUsage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "train":
+		fs := flag.NewFlagSet("focus train", flag.ExitOnError)
+		out := fs.String("out", cfg.Focus.ModelFile, "where to write the model")
+		labels := fs.String("labels", "", "label rules, the built-in docs/marketing rules when empty")
+		holdout := fs.Int("holdout", 10, "hold out every n-th labelled document for evaluation, 0 to train on all")
+		fs.Parse(args[1:])
+
+		if *out == "" {
+			log.Fatal("focus train: -out or focus.model_file is required")
+		}
+
+		labeler := focus.DefaultLabeler()
+		if *labels != "" {
+			var err error
+			if labeler, err = focus.LoadLabeler(*labels); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		db.InitDB(cfg.Postgres.DSN)
+		defer db.Pool.Close()
+
+		examples, err := loadExamples(context.Background(), labeler)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		var train, test []focus.Example
+		for i, ex := range examples {
+			if *holdout > 0 && i%*holdout == *holdout-1 {
+				test = append(test, ex)
+			} else {
+				train = append(train, ex)
+			}
+		}
+
+		model, err := focus.Train(train, focus.DefaultTrainOptions())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("trained on %d relevant and %d irrelevant documents, %d terms\n",
+			model.Relevant, model.Irrelevant, len(model.Weights))
+
+		if len(test) > 0 {
+			correct := 0
+			for _, ex := range test {
+				if (model.Relevance(ex.Terms) >= 0.5) == ex.Relevant {
+					correct++
+				}
+			}
+			fmt.Printf("held-out accuracy: %.1f%% of %d documents\n", 100*float64(correct)/float64(len(test)), len(test))
+		}
+
+		if err := model.Save(*out); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("model written to %s\n", *out)
+
+	case "score":
+		fs := flag.NewFlagSet("focus score", flag.ExitOnError)
+		file := fs.String("model", cfg.Focus.ModelFile, "model file")
+		fs.Parse(args[1:])
+
+		if fs.NArg() == 0 || *file == "" {
+			fmt.Println(focusUsage)
+			os.Exit(2)
+		}
+
+		model, err := focus.LoadModel(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%.3f\n", model.Relevance(indexer.Terms(strings.Join(fs.Args(), " "))))
+
+	default:
+		fmt.Println(focusUsage)
+		os.Exit(2)
+	}
+}
+
+// loadExamples reads the postings of every indexed document the labeler has
+// an opinion on.
+func loadExamples(ctx context.Context, labeler *focus.Labeler) ([]focus.Example, error) {
+	rows, err := db.Pool.Query(ctx, `
+	SELECT d.content_hash, COALESCE(d.url, ''), i.term, i.freq
+	FROM documents d
+	JOIN inverted_index i ON i.content_hash = d.content_hash
+	ORDER BY d.content_hash
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var examples []focus.Example
+	var terms map[string]int
+	lastHash := ""
+	skip := false
+
+	for rows.Next() {
+		var hash, url, term string
+		var freq int
+		if err := rows.Scan(&hash, &url, &term, &freq); err != nil {
+			return nil, err
+		}
+
+		if hash != lastHash {
+			lastHash = hash
+			relevant, ok := labeler.Label(url)
+			skip = !ok
+			if skip {
+				continue
+			}
+			terms = map[string]int{}
+			examples = append(examples, focus.Example{Terms: terms, Relevant: relevant})
+		}
+		if skip {
+			continue
+		}
+
+		terms[term] = freq
+	}
+
+	return examples, rows.Err()
+}
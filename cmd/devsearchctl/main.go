@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/KingrogKDR/Dev-Search/internal/config"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+)
+
+const usage = `usage: devsearchctl [config flags] <command>
+
+commands:
+  dlq          inspect, requeue and purge dead-lettered scraper jobs
+  deadletters  inspect, re-drive and purge dead-lettered indexer messages
+  streams      stream lag, retention trimming and stale consumer cleanup
+  focus        train and try the focused-crawl classifier
+  dedup        duplicate clusters and dedup algorithm evaluation`
+
+func main() {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	storage.ConfigureRedis(cfg.Redis.Addr)
+
+	args := flag.Args()
+	if len(args) == 0 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "dlq":
+		runDLQCommand(args[1:])
+	case "deadletters":
+		runDeadLettersCommand(args[1:])
+	case "streams":
+		runStreamsCommand(cfg, args[1:])
+	case "focus":
+		runFocusCommand(cfg, args[1:])
+	case "dedup":
+		runDedupCommand(cfg, args[1:])
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/config"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+	"github.com/KingrogKDR/Dev-Search/internal/streams"
+)
+
+const streamsUsage = `usage: devsearchctl streams <command>
+
+commands:
+  status    length, pending entries, lag and consumer idle times of parser-events
+  trim      drop acknowledged entries outside the configured retention
+  cleanup   remove idle consumers without pending entries`
+
+func runStreamsCommand(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Println(streamsUsage)
+		os.Exit(2)
+	}
+
+	stream := streams.NewMsgStream(storage.GetRedisClient(), "parser", "indexer")
+
+	switch args[0] {
+	case "status":
+		status, err := stream.Status()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Printf("stream %s: %d entries, %d retrying, %d dead\n", status.Stream, status.Length, status.Retrying, status.DeadLetter)
+		for _, g := range status.Groups {
+			fmt.Printf("\ngroup %s: %d pending, oldest %v, lag %d, last delivered %s\n",
+				g.Name, g.Pending, g.OldestPendingAge, g.Lag, g.LastDeliveredID)
+			for _, c := range g.Consumers {
+				fmt.Printf("  %-50s %6d pending  idle %v\n", c.Name, c.Pending, c.Idle.Round(time.Second))
+			}
+		}
+
+	case "trim":
+		n, err := stream.Trim(cfg.Streams)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("trim: %d entries\n", n)
+
+	case "cleanup":
+		n, err := stream.CleanupConsumers(cfg.Streams.StaleConsumerIdle)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("cleanup: %d consumers\n", n)
+
+	default:
+		fmt.Println(streamsUsage)
+		os.Exit(2)
+	}
+}
@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"github.com/KingrogKDR/Dev-Search/internal/config"
 	"github.com/KingrogKDR/Dev-Search/internal/indexer"
 	"github.com/KingrogKDR/Dev-Search/internal/indexer/worker"
 	"github.com/KingrogKDR/Dev-Search/internal/storage"
@@ -15,7 +18,13 @@ import (
 )
 
 func main() {
-	db.InitDB()
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	storage.ConfigureRedis(cfg.Redis.Addr)
+	db.InitDB(cfg.Postgres.DSN)
 
 	log.Println("Running DB migrations...")
 	if err := db.RunMigrations(); err != nil {
@@ -25,13 +34,24 @@ func main() {
 	defer indexer.FinalReport()
 	rdb := storage.GetRedisClient()
 	parserStream := streams.NewMsgStream(rdb, "parser", "indexer")
+	parserStream.SetBackoff(streams.Backoff{
+		Base: cfg.Indexer.RetryBaseDelay,
+		Max:  cfg.Indexer.RetryMaxDelay,
+	})
+
+	if n, err := parserStream.RetryCount(); err == nil && n > 0 {
+		log.Printf("%d messages waiting for a retry in %s", n, parserStream.RetryKey())
+	}
+	if n, err := parserStream.DeadLetterCount(); err == nil && n > 0 {
+		log.Printf("%d messages waiting in %s, see devsearchctl deadletters", n, parserStream.DeadStreamName())
+	}
 
 	store, err := storage.NewMinioStore(
-		"localhost:9000",
-		"minioadmin",
-		"minioadmin",
-		"devsearch-data",
-		false,
+		cfg.Minio.Endpoint,
+		cfg.Minio.AccessKey,
+		cfg.Minio.SecretKey,
+		cfg.Minio.Bucket,
+		cfg.Minio.UseSSL,
 	)
 
 	if err != nil {
@@ -43,18 +63,60 @@ func main() {
 		log.Fatal("Bucket doesn't exist in s3:", err)
 	}
 
+	checked, repaired, err := indexer.RepairDocuments(context.Background(), store, cfg.Indexer.RepairBatch)
+	if err != nil {
+		log.Printf("Repairing half-indexed documents failed: %v", err)
+	} else if repaired > 0 {
+		log.Printf("Repaired %d of %d checked documents", repaired, checked)
+	}
+
 	indexExec := func(ctx context.Context, msg *streams.Msg) error {
 		return indexer.CreateAndStoreIndexedDocument(ctx, msg, store)
 	}
 
-	indexerWorker := worker.NewWorker("Indexer", parserStream, 2, indexExec)
+	indexerWorker := worker.NewWorker("Indexer", parserStream, cfg.Indexer.Workers, indexExec, worker.Options{
+		TaskTimeout:       cfg.Indexer.TaskTimeout,
+		RetryPollInterval: worker.RetryPollInterval,
+		Retention:         cfg.Streams,
+	})
 
 	indexerWorker.Start()
+
+	// idempotency keys are only needed while the stream still holds the message
+	pruneCtx, stopPruning := context.WithCancel(context.Background())
+	if cfg.Streams.TrimInterval > 0 {
+		go pruneProcessedMessages(pruneCtx, parserStream, cfg.Streams.TrimInterval)
+	}
+
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	<-c
 
 	log.Println("Shutting down worker...")
+	stopPruning()
 	indexerWorker.Stop()
+}
+
+func pruneProcessedMessages(ctx context.Context, stream *streams.MsgStream, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		floor, err := stream.Floor()
+		if err != nil {
+			log.Printf("Error reading stream floor: %v", err)
+			continue
+		}
+		if n, err := indexer.PruneProcessedMessages(ctx, floor); err != nil {
+			log.Printf("Error pruning processed messages: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d processed message keys older than %s", n, floor.Format(time.RFC3339))
+		}
+	}
 }
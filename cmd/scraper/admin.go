@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/KingrogKDR/Dev-Search/internal/admin"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/crawler"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scope"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/seeds"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/traps"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/urlseen"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+)
+
+func registerAdminRoutes(srv *admin.Server, registry *seeds.Registry, frontier *queues.Queue, scopeEnforcer *scope.Enforcer, seenFilter *urlseen.Filter) {
+	syncSeeds := func() {
+		// enqueue in the background so the request isn't held by the enqueue pacing
+		go func() {
+			if err := enqueuePendingSeeds(context.Background(), registry, frontier, scopeEnforcer); err != nil {
+				log.Printf("[Admin] Seed sync failed: %v", err)
+			}
+		}()
+	}
+
+	srv.Mux.HandleFunc("GET /seeds", func(w http.ResponseWriter, r *http.Request) {
+		list, err := registry.List(r.Context())
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, list)
+	})
+
+	srv.Mux.HandleFunc("POST /seeds", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			URLs []string `json:"urls"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			admin.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		added, err := registry.Add(r.Context(), body.URLs, "admin")
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		syncSeeds()
+		admin.WriteJSON(w, http.StatusCreated, map[string]int{"added": added})
+	})
+
+	srv.Mux.HandleFunc("POST /seeds/import", func(w http.ResponseWriter, r *http.Request) {
+		format := seeds.Format(r.URL.Query().Get("format"))
+		if format == "" {
+			format = seeds.FORMAT_TEXT
+		}
+
+		urls, err := seeds.Parse(r.Body, format)
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		added, err := registry.Add(r.Context(), urls, "import:"+string(format))
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		syncSeeds()
+		admin.WriteJSON(w, http.StatusCreated, map[string]int{"found": len(urls), "added": added})
+	})
+
+	srv.Mux.HandleFunc("DELETE /seeds", func(w http.ResponseWriter, r *http.Request) {
+		u := r.URL.Query().Get("url")
+		if u == "" {
+			admin.WriteError(w, http.StatusBadRequest, errors.New("missing url parameter"))
+			return
+		}
+
+		removed, err := registry.Remove(r.Context(), u)
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !removed {
+			admin.WriteError(w, http.StatusNotFound, fmt.Errorf("unknown seed %s", u))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	srv.Mux.HandleFunc("GET /pacing", func(w http.ResponseWriter, r *http.Request) {
+		rates, err := crawler.HostRates(r.Context(), storage.GetRedisClient())
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, rates)
+	})
+
+	srv.Mux.HandleFunc("GET /frontier", func(w http.ResponseWriter, r *http.Request) {
+		hosts, err := frontier.Hosts(r.Context())
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, hosts)
+	})
+
+	srv.Mux.HandleFunc("GET /frontier/url", func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("url")
+		if target == "" {
+			admin.WriteError(w, http.StatusBadRequest, errors.New("url is required"))
+			return
+		}
+
+		queued, err := frontier.LookupURL(r.Context(), target)
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if queued == nil {
+			admin.WriteError(w, http.StatusNotFound, fmt.Errorf("%s is not queued", target))
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, queued)
+	})
+
+	srv.Mux.HandleFunc("GET /frontier/top", func(w http.ResponseWriter, r *http.Request) {
+		n := int64(20)
+		if raw := r.URL.Query().Get("n"); raw != "" {
+			var err error
+			if n, err = strconv.ParseInt(raw, 10, 64); err != nil || n <= 0 {
+				admin.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid n %q", raw))
+				return
+			}
+		}
+
+		top, err := frontier.Top(r.Context(), n)
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, top)
+	})
+
+	srv.Mux.HandleFunc("GET /seen", func(w http.ResponseWriter, r *http.Request) {
+		stats, err := seenFilter.Stats(r.Context())
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, stats)
+	})
+
+	srv.Mux.HandleFunc("GET /traps", func(w http.ResponseWriter, r *http.Request) {
+		entries, err := traps.List(r.Context(), storage.GetRedisClient())
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, entries)
+	})
+
+	srv.Mux.HandleFunc("GET /robots", func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("url")
+		if target == "" {
+			admin.WriteError(w, http.StatusBadRequest, errors.New("url is required"))
+			return
+		}
+
+		verdict, err := crawler.TestRobots(r.Context(), target)
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, verdict)
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/KingrogKDR/Dev-Search/internal/admin"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/deduplication"
+)
+
+func registerDedupRoutes(srv *admin.Server, dedup deduplication.Deduplicator, clusters *deduplication.Clusters) {
+	srv.Mux.HandleFunc("GET /dedup", func(w http.ResponseWriter, r *http.Request) {
+		stats, err := dedup.Stats(r.Context())
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, stats)
+	})
+
+	srv.Mux.HandleFunc("GET /dedup/cluster", func(w http.ResponseWriter, r *http.Request) {
+		u := r.URL.Query().Get("url")
+		if u == "" {
+			admin.WriteError(w, http.StatusBadRequest, errors.New("url is required"))
+			return
+		}
+
+		cluster, err := clusters.Lookup(r.Context(), u)
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if cluster == nil {
+			admin.WriteError(w, http.StatusNotFound, fmt.Errorf("%s has no known duplicates", u))
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, cluster)
+	})
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/admin"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+)
+
+type dlqRequest struct {
+	Queue  string   `json:"queue"`
+	IDs    []string `json:"ids"`
+	Host   string   `json:"host"`
+	Error  string   `json:"error"`
+	MinAge string   `json:"min_age"`
+	MaxAge string   `json:"max_age"`
+	All    bool     `json:"all"`
+}
+
+func (r dlqRequest) filter() (queues.DLQFilter, error) {
+	filter := queues.DLQFilter{
+		IDs:   r.IDs,
+		Host:  r.Host,
+		Error: r.Error,
+	}
+
+	var err error
+	if r.MinAge != "" {
+		if filter.MinAge, err = time.ParseDuration(r.MinAge); err != nil {
+			return filter, fmt.Errorf("invalid min_age: %w", err)
+		}
+	}
+	if r.MaxAge != "" {
+		if filter.MaxAge, err = time.ParseDuration(r.MaxAge); err != nil {
+			return filter, fmt.Errorf("invalid max_age: %w", err)
+		}
+	}
+
+	return filter, nil
+}
+
+func dlqRequestFromQuery(v url.Values) dlqRequest {
+	req := dlqRequest{
+		Queue:  v.Get("queue"),
+		Host:   v.Get("host"),
+		Error:  v.Get("error"),
+		MinAge: v.Get("min_age"),
+		MaxAge: v.Get("max_age"),
+	}
+	if ids := v.Get("ids"); ids != "" {
+		req.IDs = strings.Split(ids, ",")
+	}
+	return req
+}
+
+func registerDLQRoutes(srv *admin.Server, byName map[string]*queues.Queue) {
+	resolve := func(w http.ResponseWriter, req dlqRequest) (*queues.Queue, queues.DLQFilter, bool) {
+		name := req.Queue
+		if name == "" {
+			name = "frontier"
+		}
+
+		q, ok := byName[name]
+		if !ok {
+			admin.WriteError(w, http.StatusBadRequest, fmt.Errorf("unknown queue %q", name))
+			return nil, queues.DLQFilter{}, false
+		}
+
+		filter, err := req.filter()
+		if err != nil {
+			admin.WriteError(w, http.StatusBadRequest, err)
+			return nil, queues.DLQFilter{}, false
+		}
+
+		return q, filter, true
+	}
+
+	srv.Mux.HandleFunc("GET /dlq", func(w http.ResponseWriter, r *http.Request) {
+		q, filter, ok := resolve(w, dlqRequestFromQuery(r.URL.Query()))
+		if !ok {
+			return
+		}
+
+		dead, err := q.DeadJobs(r.Context(), filter)
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, dead)
+	})
+
+	srv.Mux.HandleFunc("GET /dlq/reasons", func(w http.ResponseWriter, r *http.Request) {
+		q, filter, ok := resolve(w, dlqRequestFromQuery(r.URL.Query()))
+		if !ok {
+			return
+		}
+
+		dead, err := q.DeadJobs(r.Context(), filter)
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, queues.FailureReasons(dead))
+	})
+
+	srv.Mux.HandleFunc("GET /dlq/{id}", func(w http.ResponseWriter, r *http.Request) {
+		q, _, ok := resolve(w, dlqRequestFromQuery(r.URL.Query()))
+		if !ok {
+			return
+		}
+
+		entry, err := q.DeadJob(r.Context(), r.PathValue("id"))
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if entry == nil {
+			admin.WriteError(w, http.StatusNotFound, errors.New("no such dead job"))
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, entry)
+	})
+
+	mutate := func(action string, apply func(q *queues.Queue, r *http.Request, filter queues.DLQFilter) (int, error)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var req dlqRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				admin.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+
+			q, filter, ok := resolve(w, req)
+			if !ok {
+				return
+			}
+
+			// an empty filter would touch every entry
+			if filter.Empty() && !req.All {
+				admin.WriteError(w, http.StatusBadRequest, errors.New("give ids or a filter, or set all"))
+				return
+			}
+
+			n, err := apply(q, r, filter)
+			if err != nil {
+				admin.WriteError(w, http.StatusInternalServerError, err)
+				return
+			}
+			admin.WriteJSON(w, http.StatusOK, map[string]int{action: n})
+		}
+	}
+
+	srv.Mux.HandleFunc("POST /dlq/requeue", mutate("requeued", func(q *queues.Queue, r *http.Request, filter queues.DLQFilter) (int, error) {
+		return q.RequeueDead(r.Context(), filter)
+	}))
+
+	srv.Mux.HandleFunc("POST /dlq/purge", mutate("purged", func(q *queues.Queue, r *http.Request, filter queues.DLQFilter) (int, error) {
+		return q.PurgeDead(r.Context(), filter)
+	}))
+}
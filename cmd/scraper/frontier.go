@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+)
+
+func runFrontierCommand(args []string) {
+	frontier := queues.NewQueue(storage.GetRedisClient(), "frontier", queues.DefaultOptions())
+
+	const usage = "usage: scraper frontier [url <url> | top [n]]"
+
+	if len(args) > 0 {
+		switch {
+		case args[0] == "url" && len(args) == 2:
+			lookupURL(args[1])
+		case args[0] == "top" && len(args) <= 2:
+			n := int64(20)
+			if len(args) == 2 {
+				var err error
+				if n, err = strconv.ParseInt(args[1], 10, 64); err != nil || n <= 0 {
+					log.Fatal(usage)
+				}
+			}
+			printTop(frontier, n)
+		default:
+			log.Fatal(usage)
+		}
+		return
+	}
+
+	hosts, err := frontier.Hosts(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	now := time.Now()
+
+	fmt.Printf("%-40s %8s %12s\n", "HOST", "QUEUED", "READY IN")
+	for _, h := range hosts {
+		fmt.Printf("%-40s %8d %12v\n", h.Host, h.Queued, max(h.NextFetch.Sub(now), 0).Round(time.Millisecond))
+	}
+}
+
+// lookupURL prints where a URL sits in the frontier. Parse jobs are indexed
+// by job id, not URL, so the parser queue can't be asked.
+func lookupURL(rawUrl string) {
+	q := queues.NewQueue(storage.GetRedisClient(), "frontier", queues.DefaultOptions())
+
+	queued, err := q.LookupURL(context.Background(), rawUrl)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if queued == nil {
+		fmt.Println("frontier not queued")
+		return
+	}
+	fmt.Printf("frontier %-10s score %-4d job %s\n", queued.State, queued.Score, queued.JobID)
+}
+
+// printTop prints the best jobs of a zset frontier.
+func printTop(frontier *queues.Queue, n int64) {
+	top, err := frontier.Top(context.Background(), n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(top) == 0 {
+		fmt.Println("no ranked jobs, is scraper.queue_backend zset?")
+		return
+	}
+
+	fmt.Printf("%8s %6s  %s\n", "AGED", "SCORE", "URL")
+	for _, j := range top {
+		fmt.Printf("%8.1f %6d  %s\n", j.AgedScore, j.Score, j.URL)
+	}
+}
@@ -2,20 +2,32 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/KingrogKDR/Dev-Search/internal/admin"
+	"github.com/KingrogKDR/Dev-Search/internal/config"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/crawler"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/deduplication"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/focus"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/leader"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/parsing"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scope"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scoring"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/seeds"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/stats"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/templates"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/traps"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/urlseen"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/worker"
 	"github.com/KingrogKDR/Dev-Search/internal/storage"
 	"github.com/KingrogKDR/Dev-Search/internal/streams"
+	"github.com/google/uuid"
 )
 
 var priorityQueues = []string{
@@ -25,34 +37,84 @@ var priorityQueues = []string{
 	string(queues.P3_LOW),
 }
 
-var seedUrls = []string{
-	"https://go.dev/doc/",
-	"https://doc.rust-lang.org/book/",
-	"https://docs.python.org/3/",
-	"https://developer.mozilla.org/en-US/docs/Web/JavaScript",
-	"https://docs.docker.com/",
-	"https://kubernetes.io/docs/",
-	"https://redis.io/docs/",
-	"https://github.com/donnemartin/system-design-primer",
-	"https://github.com/codecrafters-io/build-your-own-x",
-	"https://github.com/public-apis/public-apis",
-}
-
 func main() {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	storage.ConfigureRedis(cfg.Redis.Addr)
+
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "traps":
+			runTrapsCommand(args[1:])
+		case "seeds":
+			runSeedsCommand(args[1:])
+		case "pacing":
+			runPacingCommand()
+		case "robots":
+			runRobotsCommand(args[1:])
+		case "frontier":
+			runFrontierCommand(args[1:])
+		case "seen":
+			runSeenCommand(cfg.URLSeen, args[1:])
+		case "score":
+			runScoreCommand(cfg.Scoring, args[1:])
+		default:
+			log.Fatalf("unknown command %q", args[0])
+		}
+		return
+	}
+
 	defer stats.FinalReport()
 	rdb := storage.GetRedisClient()
-	frontier := queues.NewQueue(rdb, "frontier")
-	parseQ := queues.NewQueue(rdb, "parser")
+
+	queueOpts := queues.Options{
+		ProcessingTimeout: cfg.Scraper.ProcessingTimeout,
+		MaxRetries:        cfg.Scraper.MaxRetries,
+		Backend:           cfg.Scraper.QueueBackend,
+	}
+	frontierOpts := queueOpts
+	frontierOpts.NextFetch = crawler.NextFetchTime
+	frontierOpts.MergeURLs = true
+	parserOpts := queueOpts
+
+	// each queue shares its own dequeues between priorities
+	if frontierOpts.Scheduler, err = queues.NewScheduler(cfg.Schedule); err != nil {
+		log.Fatalf("Invalid schedule: %v", err)
+	}
+	if parserOpts.Scheduler, err = queues.NewScheduler(cfg.Schedule); err != nil {
+		log.Fatalf("Invalid schedule: %v", err)
+	}
+	defer frontierOpts.Scheduler.LogReport("frontier")
+	defer parserOpts.Scheduler.LogReport("parser")
+
+	frontier := queues.NewQueue(rdb, "frontier", frontierOpts)
+	parseQ := queues.NewQueue(rdb, "parser", parserOpts)
 	parserStream := streams.NewMsgStream(rdb, "parser", "indexer")
 
-	simIndex := deduplication.NewSimIndex()
+	crawler.Configure(crawler.Config{
+		FetchTimeout:      cfg.Scraper.FetchTimeout,
+		RobotsTimeout:     cfg.Scraper.RobotsTimeout,
+		RobotsMaxAge:      cfg.Scraper.RobotsMaxAge,
+		RobotsRetryTTL:    cfg.Scraper.RobotsRetryTTL,
+		DefaultCrawlDelay: cfg.Scraper.DefaultCrawlDelay,
+		MinCrawlDelay:     cfg.Scraper.Pacing.MinDelay,
+		MaxCrawlDelay:     cfg.Scraper.Pacing.MaxDelay,
+		SpeedupStep:       cfg.Scraper.Pacing.SpeedupStep,
+		BackoffFactor:     cfg.Scraper.Pacing.BackoffFactor,
+		LatencyTarget:     cfg.Scraper.Pacing.LatencyTarget,
+		ErrorRateLimit:    cfg.Scraper.Pacing.ErrorRateLimit,
+		PacingIdleTTL:     cfg.Scraper.Pacing.IdleTTL,
+	})
 
 	store, err := storage.NewMinioStore(
-		"localhost:9000",
-		"minioadmin",
-		"minioadmin",
-		"devsearch-data",
-		false,
+		cfg.Minio.Endpoint,
+		cfg.Minio.AccessKey,
+		cfg.Minio.SecretKey,
+		cfg.Minio.Bucket,
+		cfg.Minio.UseSSL,
 	)
 
 	if err != nil {
@@ -64,36 +126,129 @@ func main() {
 		log.Fatal("Bucket doesn't exist in s3:", err)
 	}
 
-	for _, u := range seedUrls {
-		job := queues.NewJob(u)
-		job.Type = string(queues.JOB_CRAWL)
+	dedup, err := deduplication.New(cfg.Dedup, rdb)
+	if err != nil {
+		log.Fatalf("Invalid dedup config: %v", err)
+	}
 
-		job.BaseScore += 100
+	clusters := deduplication.NewClusters(rdb, cfg.Dedup.CanonicalBy)
+	learner := templates.NewLearner(rdb, cfg.Templates)
 
-		if err := frontier.Enqueue(job); err != nil {
-			log.Printf("Failed to enqueue job: %v", err)
+	var snapshotter *deduplication.Snapshotter
+	if snap, ok := dedup.(deduplication.Snapshottable); ok && cfg.Dedup.Snapshots() {
+		snapshotter, err = deduplication.NewSnapshotter(snap, cfg.Dedup, store)
+		if err != nil {
+			log.Fatalf("Invalid dedup config: %v", err)
+		}
+		if err := snapshotter.Load(context.Background()); err != nil {
+			log.Printf("Starting with an empty dedup index: %v", err)
 		}
+	}
+
+	scopeEnforcer, err := scope.NewEnforcer(rdb, &cfg.Scope, uuid.NewString())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	trapDetector := traps.NewDetector(rdb, cfg.Traps)
+
+	scorer, err := scoring.NewRuleScorer(cfg.Scoring)
+	if err != nil {
+		log.Fatalf("Invalid scoring rules: %v", err)
+	}
 
-		time.Sleep(100 * time.Millisecond)
+	// SIGHUP re-reads the scoring rules without a restart
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := scorer.Reload(cfg.Scoring); err != nil {
+				log.Printf("Scoring reload failed, keeping the old rules: %v", err)
+				continue
+			}
+			log.Println("Scoring rules reloaded")
+		}
+	}()
 
+	predictor, err := focus.NewPredictor(cfg.Focus)
+	if err != nil {
+		log.Fatalf("Invalid focus model: %v", err)
+	}
+	if predictor.Enabled() {
+		log.Printf("Focused crawling with model %s", cfg.Focus.ModelFile)
+	}
+
+	seenFilter := urlseen.NewFilter(rdb, cfg.URLSeen)
+	if err := seenFilter.Open(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("URL-seen filter: capacity %d per first layer, target false positive rate %.4f%%",
+		cfg.URLSeen.Capacity, cfg.URLSeen.FalsePositiveRate*100)
+
+	registry := seeds.NewRegistry(rdb)
+	if _, err := registry.Add(context.Background(), cfg.Scraper.Seeds, seeds.SOURCE_CONFIG); err != nil {
+		log.Fatalf("Failed to register seeds: %v", err)
+	}
+
+	// retry moving and seed syncing run in one scraper process of the fleet
+	maintenanceCtx, stopMaintenance := context.WithCancel(context.Background())
+	elector := leader.NewElector(rdb, "scraper-maintenance", cfg.Scraper.LeaderTTL)
+
+	if elector.Campaign(maintenanceCtx) {
+		if err := enqueuePendingSeeds(context.Background(), registry, frontier, scopeEnforcer); err != nil {
+			log.Printf("Failed to enqueue seeds: %v", err)
+		}
+	}
+	go elector.Run(maintenanceCtx)
+
+	// every process snapshots its own memory index
+	if snapshotter != nil {
+		go snapshotter.Run(maintenanceCtx)
+	}
+
+	if known, err := registry.List(context.Background()); err == nil {
+		urls := make([]string, 0, len(known))
+		for _, s := range known {
+			urls = append(urls, s.URL)
+		}
+		if err := scopeEnforcer.RegisterSeeds(context.Background(), urls); err != nil {
+			log.Printf("Failed to register seed hosts: %v", err)
+		}
 	}
 
 	crawlExec := func(ctx context.Context, job *queues.Job) error {
-		return crawler.FetchAndStoreRaw(ctx, job, simIndex, store, parseQ)
+		return crawler.FetchAndStoreRaw(ctx, job, dedup, clusters, learner, store, parseQ)
 	}
 
 	parseExec := func(ctx context.Context, job *queues.Job) error {
-		return parsing.ExtractTextAndStore(ctx, job, store, frontier, parserStream)
+		return parsing.ExtractTextAndStore(ctx, job, store, frontier, parserStream, scopeEnforcer, trapDetector, seenFilter, scorer, predictor, clusters, learner)
 	}
 
-	crawlerWorker := worker.NewWorker(crawler.UserAgent, frontier, priorityQueues, 8, crawlExec)
-	parserWorker := worker.NewWorker("Parser", parseQ, priorityQueues, 6, parseExec)
+	workerOpts := worker.Options{
+		TaskTimeout:    cfg.Scraper.TaskTimeout,
+		DequeueTimeout: cfg.Scraper.DequeueTimeout,
+		ReapInterval:   cfg.Scraper.ReapInterval,
+
+		HeartbeatInterval: cfg.Scraper.HeartbeatInterval,
+	}
+
+	crawlerWorker := worker.NewWorker(crawler.UserAgent, frontier, priorityQueues, cfg.Scraper.Crawlers, crawlExec, workerOpts)
+	parserWorker := worker.NewWorker("Parser", parseQ, priorityQueues, cfg.Scraper.Parsers, parseExec, workerOpts)
 
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
+		ticker := time.NewTicker(cfg.Scraper.RetryInterval)
 		defer ticker.Stop()
 
-		for range ticker.C {
+		for {
+			select {
+			case <-maintenanceCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if !elector.IsLeader() {
+				continue
+			}
 			if err := frontier.ProcessRetryJobs(); err != nil {
 				log.Printf("Error processing retry jobs: %v", err)
 			}
@@ -101,16 +256,57 @@ func main() {
 	}()
 
 	go func() {
-		ticker := time.NewTicker(10 * time.Second)
+		ticker := time.NewTicker(cfg.Scraper.RetryInterval)
 		defer ticker.Stop()
 
-		for range ticker.C {
+		for {
+			select {
+			case <-maintenanceCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if !elector.IsLeader() {
+				continue
+			}
 			if err := parseQ.ProcessRetryJobs(); err != nil {
 				log.Printf("Error processing retry jobs: %v", err)
 			}
 		}
 	}()
 
+	go func() {
+		ticker := time.NewTicker(cfg.Scraper.SeedSyncInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-maintenanceCtx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if !elector.IsLeader() {
+				continue
+			}
+			if err := enqueuePendingSeeds(context.Background(), registry, frontier, scopeEnforcer); err != nil {
+				log.Printf("Error syncing seeds: %v", err)
+			}
+		}
+	}()
+
+	var adminServer *admin.Server
+	if cfg.Admin.Addr != "" {
+		adminServer = admin.NewServer(cfg.Admin.Addr)
+		registerAdminRoutes(adminServer, registry, frontier, scopeEnforcer, seenFilter)
+		registerDLQRoutes(adminServer, map[string]*queues.Queue{"frontier": frontier, "parser": parseQ})
+		registerScoringRoutes(adminServer, scorer, cfg.Scoring)
+		registerScheduleRoutes(adminServer, map[string]*queues.Scheduler{"frontier": frontierOpts.Scheduler, "parser": parserOpts.Scheduler})
+		registerDedupRoutes(adminServer, dedup, clusters)
+		registerTemplateRoutes(adminServer, learner)
+		adminServer.Start()
+	}
+
 	crawlerWorker.Start()
 	parserWorker.Start()
 
@@ -119,7 +315,16 @@ func main() {
 	<-c
 
 	log.Println("Shutting down worker...")
+	if adminServer != nil {
+		adminServer.Stop()
+	}
+	stopMaintenance()
 	parserWorker.Stop()
 	crawlerWorker.Stop()
 
+	if snapshotter != nil {
+		if err := snapshotter.Save(context.Background()); err != nil {
+			log.Printf("Final dedup snapshot failed: %v", err)
+		}
+	}
 }
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/crawler"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+)
+
+func runPacingCommand() {
+	rates, err := crawler.HostRates(context.Background(), storage.GetRedisClient())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("%-40s %10s %10s %10s %12s %8s\n", "HOST", "DELAY", "FLOOR", "REQ/MIN", "AVG LATENCY", "ERRORS")
+	for _, r := range rates {
+		fmt.Printf("%-40s %10v %10v %10.1f %12v %7.0f%%\n",
+			r.Host,
+			r.Delay.Round(time.Millisecond),
+			r.Floor.Round(time.Millisecond),
+			r.RequestsPerMin,
+			r.AvgLatency.Round(time.Millisecond),
+			r.ErrorRate*100,
+		)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/crawler"
+)
+
+const robotsUsage = `usage: scraper robots <command>
+
+commands:
+  test <url>   check a url against the cached robots.txt of its host`
+
+func runRobotsCommand(args []string) {
+	if len(args) != 2 || args[0] != "test" {
+		fmt.Println(robotsUsage)
+		os.Exit(2)
+	}
+
+	verdict, err := crawler.TestRobots(context.Background(), args[1])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("url:         %s\n", verdict.URL)
+	fmt.Printf("allowed:     %v\n", verdict.Allowed)
+	fmt.Printf("status:      %s\n", verdict.Status)
+	if len(verdict.Agents) > 0 {
+		fmt.Printf("group:       %v\n", verdict.Agents)
+	}
+	if verdict.Rule != nil {
+		kind := "disallow"
+		if verdict.Rule.Allow {
+			kind = "allow"
+		}
+		fmt.Printf("rule:        %s: %s\n", kind, verdict.Rule.Pattern)
+	}
+	fmt.Printf("crawl delay: %v\n", verdict.CrawlDelay)
+	fmt.Printf("fetched:     %s\n", verdict.FetchedAt.Format(time.RFC3339))
+	fmt.Printf("expires:     %s\n", verdict.ExpiresAt.Format(time.RFC3339))
+}
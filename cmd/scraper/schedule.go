@@ -0,0 +1,20 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/KingrogKDR/Dev-Search/internal/admin"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+)
+
+// registerScheduleRoutes reports per-priority dequeue rates and wait times of
+// this process's queues.
+func registerScheduleRoutes(srv *admin.Server, schedulers map[string]*queues.Scheduler) {
+	srv.Mux.HandleFunc("GET /frontier/schedule", func(w http.ResponseWriter, r *http.Request) {
+		out := map[string][]queues.PriorityStats{}
+		for name, s := range schedulers {
+			out[name] = s.Stats()
+		}
+		admin.WriteJSON(w, http.StatusOK, out)
+	})
+}
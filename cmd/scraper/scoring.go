@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/KingrogKDR/Dev-Search/internal/admin"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scoring"
+)
+
+const scoreUsage = `usage: scraper score [-depth n] [-rules file] [url ...]
+
+Scores the given URLs, or one URL per line from stdin, and explains each
+score. Nothing is enqueued.`
+
+func runScoreCommand(cfg scoring.Config, args []string) {
+	fs := flag.NewFlagSet("score", flag.ExitOnError)
+	fs.Usage = func() { fmt.Println(scoreUsage) }
+	depth := fs.Int("depth", 1, "crawl depth to assume for every url")
+	rules := fs.String("rules", "", "scoring rules file to try instead of the configured ones")
+	fs.Parse(args)
+
+	if *rules != "" {
+		cfg = scoring.Config{File: *rules}
+	}
+
+	scorer, err := scoring.NewRuleScorer(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	urls := fs.Args()
+	if len(urls) == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+				urls = append(urls, line)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for _, e := range dryRun(scorer, urls, *depth) {
+		fmt.Printf("%s\n  %s\n", e.URL, e)
+	}
+}
+
+// dryRun scores urls as if they had just been discovered at depth.
+func dryRun(scorer scoring.Scorer, urls []string, depth int) []scoring.Explanation {
+	explained := make([]scoring.Explanation, 0, len(urls))
+
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			log.Printf("Skipping %q: not an absolute url", raw)
+			continue
+		}
+
+		meta := queues.NewUrlMeta(depth)
+		scorer.Classify(u, meta)
+		explained = append(explained, scorer.Score(u, meta))
+	}
+
+	return explained
+}
+
+func registerScoringRoutes(srv *admin.Server, scorer *scoring.RuleScorer, configured scoring.Config) {
+	srv.Mux.HandleFunc("GET /scoring", func(w http.ResponseWriter, r *http.Request) {
+		admin.WriteJSON(w, http.StatusOK, scorer.Config())
+	})
+
+	// replaces the rules of this process until the next reload
+	srv.Mux.HandleFunc("PUT /scoring", func(w http.ResponseWriter, r *http.Request) {
+		var cfg scoring.Config
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			admin.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		cfg.File = ""
+
+		if err := scorer.Reload(cfg); err != nil {
+			admin.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		log.Printf("[Admin] Scoring rules replaced")
+		admin.WriteJSON(w, http.StatusOK, scorer.Config())
+	})
+
+	// back to the configured rules, re-reading the rules file if there is one
+	srv.Mux.HandleFunc("POST /scoring/reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := scorer.Reload(configured); err != nil {
+			admin.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		log.Printf("[Admin] Scoring rules reloaded")
+		admin.WriteJSON(w, http.StatusOK, scorer.Config())
+	})
+
+	srv.Mux.HandleFunc("POST /scoring/dry-run", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			URLs  []string `json:"urls"`
+			Depth *int     `json:"depth"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			admin.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		if len(req.URLs) == 0 {
+			admin.WriteError(w, http.StatusBadRequest, errors.New("urls is required"))
+			return
+		}
+
+		depth := 1
+		if req.Depth != nil {
+			depth = *req.Depth
+		}
+		admin.WriteJSON(w, http.StatusOK, dryRun(scorer, req.URLs, depth))
+	})
+}
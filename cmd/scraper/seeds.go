@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scope"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/seeds"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+)
+
+var seedSyncMu sync.Mutex
+
+const seedsUsage = `usage: scraper seeds <command>
+
+commands:
+  list                                 show every seed with its status and crawl stats
+  add <url>...                         register new seeds
+  remove <url>                         drop a seed from the registry
+  requeue <url>                        crawl a seed again on the next sync
+  import [-format text|opml|md] <file> bulk import seeds from a file`
+
+func runSeedsCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println(seedsUsage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	registry := seeds.NewRegistry(storage.GetRedisClient())
+
+	var err error
+
+	switch args[0] {
+	case "list":
+		var list []seeds.Seed
+		list, err = registry.List(ctx)
+		for _, s := range list {
+			lastCrawl := "never"
+			if !s.LastCrawledAt.IsZero() {
+				lastCrawl = s.LastCrawledAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%-60s %-9s source=%-8s pages=%-6d last_crawl=%s\n",
+				s.URL, s.Status, s.Source, s.PagesDiscovered, lastCrawl)
+		}
+
+	case "add":
+		var added int
+		added, err = registry.Add(ctx, args[1:], "cli")
+		fmt.Printf("%d seeds added\n", added)
+
+	case "remove", "requeue":
+		if len(args) != 2 {
+			fmt.Println(seedsUsage)
+			os.Exit(2)
+		}
+		if args[0] == "remove" {
+			var removed bool
+			removed, err = registry.Remove(ctx, args[1])
+			if err == nil && !removed {
+				err = fmt.Errorf("unknown seed %s", args[1])
+			}
+		} else {
+			err = registry.Requeue(ctx, args[1])
+		}
+		if err == nil {
+			fmt.Printf("%s: %s\n", args[0], args[1])
+		}
+
+	case "import":
+		fs := flag.NewFlagSet("import", flag.ExitOnError)
+		format := fs.String("format", "", "text, opml or md (detected from the file extension by default)")
+		fs.Parse(args[1:])
+
+		if fs.NArg() != 1 {
+			fmt.Println(seedsUsage)
+			os.Exit(2)
+		}
+
+		var added int
+		added, err = importSeeds(ctx, registry, fs.Arg(0), seeds.Format(*format))
+		if err == nil {
+			fmt.Printf("%d seeds added\n", added)
+		}
+
+	default:
+		fmt.Println(seedsUsage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func importSeeds(ctx context.Context, registry *seeds.Registry, file string, format seeds.Format) (int, error) {
+	if format == "" {
+		format = seeds.DetectFormat(file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	urls, err := seeds.Parse(f, format)
+	if err != nil {
+		return 0, err
+	}
+
+	return registry.Add(ctx, urls, "import:"+string(format))
+}
+
+// enqueuePendingSeeds crawls seeds that were registered but never enqueued,
+// so restarts don't push the same seeds into the frontier again.
+func enqueuePendingSeeds(ctx context.Context, registry *seeds.Registry, frontier *queues.Queue, scopeEnforcer *scope.Enforcer) error {
+	seedSyncMu.Lock()
+	defer seedSyncMu.Unlock()
+
+	pending, err := registry.Pending(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	urls := make([]string, 0, len(pending))
+	for _, s := range pending {
+		urls = append(urls, s.URL)
+	}
+
+	if err := scopeEnforcer.RegisterSeeds(ctx, urls); err != nil {
+		log.Printf("Failed to register seed hosts: %v", err)
+	}
+
+	for _, s := range pending {
+		job := queues.NewJob(s.URL)
+		job.Type = string(queues.JOB_CRAWL)
+		job.Seed = true
+
+		job.BaseScore += 100
+
+		if _, err := frontier.Enqueue(job); err != nil {
+			log.Printf("Failed to enqueue job: %v", err)
+			continue
+		}
+
+		if err := registry.MarkEnqueued(ctx, s.URL); err != nil {
+			log.Printf("Failed to mark seed %s as enqueued: %v", s.URL, err)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	log.Printf("Enqueued %d pending seeds", len(pending))
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/urlseen"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+)
+
+func runSeenCommand(cfg urlseen.Config, args []string) {
+	filter := urlseen.NewFilter(storage.GetRedisClient(), cfg)
+
+	if len(args) > 0 {
+		if args[0] != "reset" {
+			log.Fatalf("unknown seen command %q", args[0])
+		}
+		if err := filter.Reset(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("url-seen filter reset, every url counts as new again")
+		return
+	}
+
+	stats, err := filter.Stats(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("urls seen:                  %d\n", stats.Items)
+	fmt.Printf("layers:                     %d\n", stats.Layers)
+	fmt.Printf("size:                       %.2f MB\n", float64(stats.Bytes)/(1024*1024))
+	fmt.Printf("target false positive rate: %.4f%%\n", stats.FalsePositiveRate*100)
+	fmt.Printf("estimated false positives:  %.4f%%\n", stats.EstimatedFalsePositiveRate*100)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/KingrogKDR/Dev-Search/internal/admin"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/templates"
+)
+
+// registerTemplateRoutes shows and resets the page templates learned per
+// host.
+func registerTemplateRoutes(srv *admin.Server, learner *templates.Learner) {
+	srv.Mux.HandleFunc("GET /templates", func(w http.ResponseWriter, r *http.Request) {
+		host := r.URL.Query().Get("host")
+		if host == "" {
+			admin.WriteError(w, http.StatusBadRequest, errors.New("host is required"))
+			return
+		}
+
+		status, err := learner.Status(r.Context(), host)
+		if err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, status)
+	})
+
+	srv.Mux.HandleFunc("DELETE /templates", func(w http.ResponseWriter, r *http.Request) {
+		host := r.URL.Query().Get("host")
+		if host == "" {
+			admin.WriteError(w, http.StatusBadRequest, errors.New("host is required"))
+			return
+		}
+
+		if err := learner.Forget(r.Context(), host); err != nil {
+			admin.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+		admin.WriteJSON(w, http.StatusOK, map[string]string{"host": host, "status": "relearning"})
+	})
+}
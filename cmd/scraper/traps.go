@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/traps"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+)
+
+const trapsUsage = `usage: scraper traps <command>
+
+commands:
+  list               show quarantined url patterns and operator overrides
+  allow <pattern>    lift a quarantine and never quarantine the pattern again
+  block <pattern>    quarantine a pattern by hand
+  release <pattern>  forget a pattern so the heuristics decide again`
+
+func runTrapsCommand(args []string) {
+	if len(args) == 0 {
+		fmt.Println(trapsUsage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	rdb := storage.GetRedisClient()
+
+	var err error
+
+	switch args[0] {
+	case "list":
+		var entries []traps.QuarantineEntry
+		entries, err = traps.List(ctx, rdb)
+		if err != nil {
+			break
+		}
+
+		fmt.Println("Quarantined:")
+		for _, e := range entries {
+			fmt.Printf("  %-60s %-18s count=%-6d since=%s\n", e.Pattern, e.Reason, e.Count, e.Since)
+		}
+
+		var allowed []string
+		allowed, err = traps.ListAllowed(ctx, rdb)
+		if err != nil {
+			break
+		}
+
+		fmt.Println("Allowed:")
+		for _, p := range allowed {
+			fmt.Printf("  %s\n", p)
+		}
+
+	case "allow", "block", "release":
+		if len(args) != 2 {
+			fmt.Println(trapsUsage)
+			os.Exit(2)
+		}
+
+		switch args[0] {
+		case "allow":
+			err = traps.Allow(ctx, rdb, args[1])
+		case "block":
+			err = traps.Block(ctx, rdb, args[1])
+		case "release":
+			err = traps.Release(ctx, rdb, args[1])
+		}
+
+		if err == nil {
+			fmt.Printf("%s: %s\n", args[0], args[1])
+		}
+
+	default:
+		fmt.Println(trapsUsage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scope"
+	"go.yaml.in/yaml/v3"
+)
+
+// Load builds the configuration shared by cmd/scraper and cmd/indexer.
+// Precedence, lowest first: defaults, config file, environment, flags.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+
+	configFile := fs.String("config", os.Getenv("DEVSEARCH_CONFIG"), "path to a YAML config file")
+	registerFlags(fs, cfg)
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// flags were written into cfg already; remember them so they can win over file and env
+	explicit := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = f.Value.String()
+	})
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	for name, value := range explicit {
+		if err := fs.Set(name, value); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func loadFile(file string, cfg *Config) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("Can't read config file: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	if err := dec.Decode(cfg); err != nil {
+		return fmt.Errorf("Can't parse config file %s: %w", file, err)
+	}
+
+	return nil
+}
+
+func applyEnv(cfg *Config) error {
+	strs := map[string]*string{
+		"REDIS_ADDR":       &cfg.Redis.Addr,
+		"MINIO_ENDPOINT":   &cfg.Minio.Endpoint,
+		"MINIO_ACCESS_KEY": &cfg.Minio.AccessKey,
+		"MINIO_SECRET_KEY": &cfg.Minio.SecretKey,
+		"MINIO_BUCKET":     &cfg.Minio.Bucket,
+		"POSTGRES_DSN":     &cfg.Postgres.DSN,
+		"DEDUP_INSTANCE":   &cfg.Dedup.Instance,
+	}
+
+	for env, dst := range strs {
+		if v, ok := os.LookupEnv(env); ok && v != "" {
+			*dst = v
+		}
+	}
+
+	if v := os.Getenv("MINIO_USE_SSL"); v != "" {
+		useSSL, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("MINIO_USE_SSL: %w", err)
+		}
+		cfg.Minio.UseSSL = useSSL
+	}
+
+	if v := os.Getenv("DEVSEARCH_SEEDS"); v != "" {
+		cfg.Scraper.Seeds = splitList(v)
+	}
+
+	// kept for setups that still ship the scope policy as its own JSON file
+	if v := os.Getenv("SCOPE_CONFIG"); v != "" {
+		policy, err := scope.LoadPolicy(v)
+		if err != nil {
+			return err
+		}
+		cfg.Scope = *policy
+	}
+
+	return nil
+}
+
+func registerFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.StringVar(&cfg.Redis.Addr, "redis-addr", cfg.Redis.Addr, "redis address")
+
+	fs.StringVar(&cfg.Minio.Endpoint, "minio-endpoint", cfg.Minio.Endpoint, "minio endpoint")
+	fs.StringVar(&cfg.Minio.AccessKey, "minio-access-key", cfg.Minio.AccessKey, "minio access key")
+	fs.StringVar(&cfg.Minio.SecretKey, "minio-secret-key", cfg.Minio.SecretKey, "minio secret key")
+	fs.StringVar(&cfg.Minio.Bucket, "minio-bucket", cfg.Minio.Bucket, "minio bucket")
+	fs.BoolVar(&cfg.Minio.UseSSL, "minio-ssl", cfg.Minio.UseSSL, "use TLS for minio")
+
+	fs.StringVar(&cfg.Postgres.DSN, "pg-dsn", cfg.Postgres.DSN, "postgres connection string")
+
+	fs.Var((*listFlag)(&cfg.Scraper.Seeds), "seeds", "comma separated seed urls")
+	fs.IntVar(&cfg.Scraper.Crawlers, "crawlers", cfg.Scraper.Crawlers, "number of crawler goroutines")
+	fs.IntVar(&cfg.Scraper.Parsers, "parsers", cfg.Scraper.Parsers, "number of parser goroutines")
+	fs.DurationVar(&cfg.Scraper.RetryInterval, "retry-interval", cfg.Scraper.RetryInterval, "how often due retries are moved back to the queues")
+	fs.DurationVar(&cfg.Scraper.FetchTimeout, "fetch-timeout", cfg.Scraper.FetchTimeout, "http timeout for page fetches")
+	fs.DurationVar(&cfg.Scraper.DefaultCrawlDelay, "crawl-delay", cfg.Scraper.DefaultCrawlDelay, "minimum delay between requests to one host")
+
+	fs.StringVar(&cfg.Admin.Addr, "admin-addr", cfg.Admin.Addr, "listen address of the admin api, empty disables it")
+
+	fs.IntVar(&cfg.Indexer.Workers, "indexers", cfg.Indexer.Workers, "number of indexer goroutines")
+}
+
+type listFlag []string
+
+func (l *listFlag) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = splitList(v)
+	return nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
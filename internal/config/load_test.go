@@ -0,0 +1,62 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	data := []byte(`
+redis:
+  addr: file:6379
+minio:
+  endpoint: staging-minio:9000
+scraper:
+  crawlers: 3
+  fetch_timeout: 20s
+`)
+	if err := os.WriteFile(file, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("REDIS_ADDR", "env:6379")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := Load(fs, []string{"-config", file, "-crawlers", "4", "traps", "list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Minio.Endpoint != "staging-minio:9000" {
+		t.Errorf("file value lost: minio endpoint = %q", cfg.Minio.Endpoint)
+	}
+	if cfg.Redis.Addr != "env:6379" {
+		t.Errorf("env should override file: redis addr = %q", cfg.Redis.Addr)
+	}
+	if cfg.Scraper.Crawlers != 4 {
+		t.Errorf("flag should override file: crawlers = %d", cfg.Scraper.Crawlers)
+	}
+	if cfg.Scraper.FetchTimeout != 20*time.Second {
+		t.Errorf("fetch timeout = %v", cfg.Scraper.FetchTimeout)
+	}
+	if cfg.Scraper.Parsers != 6 {
+		t.Errorf("default lost: parsers = %d", cfg.Scraper.Parsers)
+	}
+	if args := fs.Args(); len(args) != 2 || args[0] != "traps" {
+		t.Errorf("remaining args = %v", args)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Scraper.Crawlers = 0
+	cfg.Scraper.Seeds = append(cfg.Scraper.Seeds, "ftp://nope")
+
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("expected validation error")
+	}
+}
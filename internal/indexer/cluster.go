@@ -0,0 +1,121 @@
+package indexer
+
+import (
+	"context"
+
+	"github.com/KingrogKDR/Dev-Search/internal/storage/db"
+	"github.com/jackc/pgx/v5"
+)
+
+// applyCluster records the canonical URL and the aliases of a duplicate
+// cluster and drops the documents indexed for its aliases, e.g. a mirror
+// that was crawled before the original. An update older than the one
+// applied already changes nothing. It reports whether the record's URL is
+// still the cluster's canonical one.
+func applyCluster(ctx context.Context, tx pgx.Tx, record *Record) (bool, error) {
+	aliases := record.Aliases
+	if aliases == nil {
+		aliases = []string{}
+	}
+
+	tag, err := tx.Exec(ctx, `
+	INSERT INTO document_clusters (cluster, canonical_url, version)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (cluster) DO UPDATE
+	SET canonical_url = EXCLUDED.canonical_url, version = EXCLUDED.version
+	WHERE document_clusters.version <= EXCLUDED.version
+	`, record.Cluster, record.URL, record.ClusterVersion)
+	if err != nil {
+		return false, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		var canonical string
+		err := tx.QueryRow(ctx, `SELECT canonical_url FROM document_clusters WHERE cluster = $1`, record.Cluster).Scan(&canonical)
+		if err != nil || canonical != record.URL {
+			return false, err
+		}
+		// the canonical page arrived after newer aliases, whose documents
+		// were kept until now
+		if record.AliasesOnly() {
+			return true, nil
+		}
+		return true, dropAliasDocuments(ctx, tx, record.Cluster)
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM document_aliases WHERE cluster = $1 OR url = $2`, record.Cluster, record.URL)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.Exec(ctx, `
+	INSERT INTO document_aliases (url, cluster, canonical_url)
+	SELECT unnest($1::text[]), $2, $3
+	ON CONFLICT (url) DO UPDATE
+	SET cluster = EXCLUDED.cluster, canonical_url = EXCLUDED.canonical_url
+	`, aliases, record.Cluster, record.URL)
+	if err != nil {
+		return false, err
+	}
+
+	// aliases only replace the documents of other members once the canonical
+	// page is indexed, a canonical page that never made it must not take its
+	// cluster out of search
+	if record.AliasesOnly() {
+		var indexed bool
+		err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM documents WHERE url = $1)`, record.URL).Scan(&indexed)
+		if err != nil || !indexed {
+			return true, err
+		}
+	}
+
+	return true, dropAliasDocuments(ctx, tx, record.Cluster)
+}
+
+// dropAliasDocuments removes the documents and postings of a cluster's
+// aliases.
+func dropAliasDocuments(ctx context.Context, tx pgx.Tx, cluster string) error {
+	_, err := tx.Exec(ctx, `
+	DELETE FROM inverted_index
+	WHERE content_hash IN (
+		SELECT d.content_hash FROM documents d
+		JOIN document_aliases a ON a.url = d.url
+		WHERE a.cluster = $1
+	)
+	`, cluster)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+	DELETE FROM documents
+	WHERE url IN (SELECT url FROM document_aliases WHERE cluster = $1)
+	`, cluster)
+	return err
+}
+
+// SaveAliases applies a record that carries only a cluster's aliases.
+func SaveAliases(ctx context.Context, record *Record) error {
+	tx, err := db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := applyCluster(ctx, tx, record); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// AlsoAt lists the other URLs the page at canonicalUrl can be found at.
+func AlsoAt(ctx context.Context, canonicalUrl string) ([]string, error) {
+	rows, err := db.Pool.Query(ctx, `
+	SELECT url FROM document_aliases WHERE canonical_url = $1 ORDER BY url
+	`, canonicalUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
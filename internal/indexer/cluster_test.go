@@ -0,0 +1,58 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/storage/db"
+)
+
+func TestAliasesKeepDocumentsUntilCanonicalIsIndexed(t *testing.T) {
+	testDB(t)
+	ctx := context.Background()
+
+	n := time.Now().UnixNano()
+	cluster := fmt.Sprintf("test-%d", n)
+	original := NewRecord(uint64(n), fmt.Sprintf("https://go.dev/doc/%d", n), "Doc", "snippet", "text/test", 0)
+	challenger := fmt.Sprintf("https://mirror.example/doc/%d", n)
+	t.Cleanup(func() {
+		db.Pool.Exec(ctx, `DELETE FROM inverted_index WHERE content_hash = $1 OR content_hash = $2`, fmt.Sprintf("%x", n), fmt.Sprintf("%x", n+1))
+		db.Pool.Exec(ctx, `DELETE FROM documents WHERE url = $1 OR url = $2`, original.URL, challenger)
+		db.Pool.Exec(ctx, `DELETE FROM document_aliases WHERE cluster = $1`, cluster)
+		db.Pool.Exec(ctx, `DELETE FROM document_clusters WHERE cluster = $1`, cluster)
+	})
+
+	doc := NewDocument(original)
+	doc.BuildIndex("goroutines and channels", original.ID)
+	if _, err := doc.Save(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	indexed := func(u string) bool {
+		var ok bool
+		db.Pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM documents WHERE url = $1)`, u).Scan(&ok)
+		return ok
+	}
+
+	// the challenger won the cluster but its page is not indexed (yet)
+	if err := SaveAliases(ctx, NewClusterRecord(cluster, 2, challenger, []string{original.URL})); err != nil {
+		t.Fatal(err)
+	}
+	if !indexed(original.URL) {
+		t.Fatal("alias document dropped before the canonical page was indexed")
+	}
+
+	// once the challenger's page arrives, the alias goes
+	page := NewRecord(uint64(n+1), challenger, "Doc", "snippet", "text/test", 0)
+	page.Cluster, page.ClusterVersion, page.Aliases = cluster, 1, []string{original.URL}
+	doc = NewDocument(page)
+	doc.BuildIndex("goroutines and channels", page.ID)
+	if _, err := doc.Save(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !indexed(challenger) || indexed(original.URL) {
+		t.Errorf("after the canonical page: canonical indexed %v, alias indexed %v", indexed(challenger), indexed(original.URL))
+	}
+}
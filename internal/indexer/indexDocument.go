@@ -62,9 +62,17 @@ func tokenize(text string) []string {
 	})
 }
 func (d *Document) BuildIndex(text string, recordId uint64) {
-	words := tokenize(text)
+	for term, freq := range Terms(text) {
+		d.InvertedIndex[term] += freq
+	}
+}
 
-	for _, word := range words {
+// Terms counts the index terms of text: lowercased, stemmed and without
+// stopwords, exactly as they are stored in inverted_index.
+func Terms(text string) map[string]int {
+	terms := make(map[string]int)
+
+	for _, word := range tokenize(text) {
 		word = strings.ToLower(word)
 
 		if _, exists := stopwords[word]; exists {
@@ -81,12 +89,17 @@ func (d *Document) BuildIndex(text string, recordId uint64) {
 			continue
 		}
 
-		d.InvertedIndex[word]++
+		terms[word]++
 	}
+
+	return terms
 }
 
-func (d *Document) Save(ctx context.Context) error {
-	return insertInvertedIndex(ctx, d)
+// Save writes the document and its postings. messageID is the idempotency key
+// of the delivery; Save reports false when that message was already indexed.
+// An empty messageID always writes, which is how repairs re-index.
+func (d *Document) Save(ctx context.Context, messageID string) (bool, error) {
+	return insertInvertedIndex(ctx, d, messageID)
 }
 
 func stemmer(word string) string {
@@ -101,13 +114,44 @@ func stemmer(word string) string {
 	return stemmedWord
 }
 
-func insertInvertedIndex(ctx context.Context, doc *Document) error {
+// insertInvertedIndex records the message, the document and its postings in
+// one transaction. A second delivery of the same message finds its
+// processed_messages row and changes nothing. Postings are rewritten unless
+// the document already holds exactly the expected number, so a document left
+// half-indexed is repaired by the next delivery of its content.
+func insertInvertedIndex(ctx context.Context, doc *Document, messageID string) (bool, error) {
 	tx, err := db.Pool.Begin(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer tx.Rollback(ctx)
 	hashStr := fmt.Sprintf("%x", doc.Record.ID)
+
+	if messageID != "" {
+		tag, err := tx.Exec(ctx, `
+		INSERT INTO processed_messages (message_id, content_hash)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+		`, messageID, hashStr)
+		if err != nil {
+			return false, err
+		}
+		if tag.RowsAffected() == 0 {
+			return false, nil
+		}
+	}
+
+	if doc.Record.Cluster != "" {
+		canonical, err := applyCluster(ctx, tx, doc.Record)
+		if err != nil {
+			return false, err
+		}
+		// a better duplicate took over, only the message is recorded
+		if !canonical {
+			return true, tx.Commit(ctx)
+		}
+	}
+
 	_, err = tx.Exec(ctx, `
 	INSERT INTO documents (content_hash, url, title, snippet, object_key, inbound_links)
 	VALUES ($1, $2, $3, $4, $5, $6)
@@ -121,7 +165,28 @@ func insertInvertedIndex(ctx context.Context, doc *Document) error {
 	)
 
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	var expected *int
+	var actual int
+	err = tx.QueryRow(ctx, `
+	SELECT posting_count, (SELECT count(*) FROM inverted_index WHERE content_hash = $1)
+	FROM documents WHERE content_hash = $1
+	FOR UPDATE
+	`, hashStr).Scan(&expected, &actual)
+	if err != nil {
+		return false, err
+	}
+
+	if expected != nil && *expected == actual && actual == len(doc.InvertedIndex) {
+		return true, tx.Commit(ctx)
+	}
+
+	if actual > 0 {
+		if _, err = tx.Exec(ctx, `DELETE FROM inverted_index WHERE content_hash = $1`, hashStr); err != nil {
+			return false, err
+		}
 	}
 
 	rows := make([][]any, 0, len(doc.InvertedIndex))
@@ -142,8 +207,14 @@ func insertInvertedIndex(ctx context.Context, doc *Document) error {
 			pgx.CopyFromRows(rows),
 		)
 		if err != nil {
-			return err
+			return false, err
 		}
 	}
-	return tx.Commit(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE documents SET posting_count = $2 WHERE content_hash = $1`, hashStr, len(rows))
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
 }
@@ -0,0 +1,70 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/storage/db"
+	"github.com/KingrogKDR/Dev-Search/internal/streams"
+)
+
+// testDB connects to the scratch Postgres at TEST_POSTGRES_DSN and applies
+// the schema, the test is skipped when it is not set.
+func testDB(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN not set")
+	}
+
+	db.InitDB(dsn)
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("migrations: %v", err)
+	}
+	t.Cleanup(db.Pool.Close)
+}
+
+func TestSaveIsIdempotentForStreamMessages(t *testing.T) {
+	testDB(t)
+	ctx := context.Background()
+
+	record := NewRecord(uint64(time.Now().UnixNano()), fmt.Sprintf("https://example.com/%d", time.Now().UnixNano()), "Title", "snippet", "text/test", 0)
+	hash := fmt.Sprintf("%x", record.ID)
+	t.Cleanup(func() {
+		db.Pool.Exec(ctx, `DELETE FROM processed_messages WHERE content_hash = $1`, hash)
+		db.Pool.Exec(ctx, `DELETE FROM inverted_index WHERE content_hash = $1`, hash)
+		db.Pool.Exec(ctx, `DELETE FROM documents WHERE content_hash = $1`, hash)
+	})
+
+	doc := NewDocument(record)
+	doc.BuildIndex("goroutines and channels", record.ID)
+
+	// the key is the id the scraper gives the message, not its stream id
+	msg := streams.NewMsg([]byte("{}"), "parser")
+
+	if applied, err := doc.Save(ctx, msg.ID); err != nil || !applied {
+		t.Fatalf("first delivery: applied %v, %v", applied, err)
+	}
+	if applied, err := doc.Save(ctx, msg.ID); err != nil || applied {
+		t.Fatalf("second delivery: applied %v, %v", applied, err)
+	}
+
+	if _, err := PruneProcessedMessages(ctx, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	var kept int
+	db.Pool.QueryRow(ctx, `SELECT count(*) FROM processed_messages WHERE message_id = $1`, msg.ID).Scan(&kept)
+	if kept != 1 {
+		t.Errorf("a fresh key was pruned")
+	}
+
+	if _, err := PruneProcessedMessages(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	db.Pool.QueryRow(ctx, `SELECT count(*) FROM processed_messages WHERE message_id = $1`, msg.ID).Scan(&kept)
+	if kept != 0 {
+		t.Errorf("a key older than the floor was kept")
+	}
+}
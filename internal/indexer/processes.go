@@ -3,11 +3,16 @@ package indexer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
 	"time"
 
 	"github.com/KingrogKDR/Dev-Search/internal/storage"
+	"github.com/KingrogKDR/Dev-Search/internal/storage/db"
 	"github.com/KingrogKDR/Dev-Search/internal/streams"
+	"github.com/jackc/pgx/v5"
 )
 
 func CreateAndStoreIndexedDocument(ctx context.Context, msg *streams.Msg, store *storage.MinioStore) error {
@@ -15,6 +20,12 @@ func CreateAndStoreIndexedDocument(ctx context.Context, msg *streams.Msg, store
 	if err := json.Unmarshal(msg.Payload, &record); err != nil {
 		return fmt.Errorf("Can't unmarshal record: %w", err)
 	}
+	if record.AliasesOnly() {
+		if err := SaveAliases(ctx, &record); err != nil {
+			return fmt.Errorf("Can't save aliases of cluster %s: %w", record.Cluster, err)
+		}
+		return nil
+	}
 	data, err := store.GetObject(ctx, record.TextObjectKey)
 	if err != nil {
 		return fmt.Errorf("Can't get data from minio: %w", err)
@@ -25,10 +36,126 @@ func CreateAndStoreIndexedDocument(ctx context.Context, msg *streams.Msg, store
 
 	startDB := time.Now()
 
-	if err = doc.Save(ctx); err != nil {
+	applied, err := doc.Save(ctx, msg.ID)
+	if err != nil {
 		return fmt.Errorf("Can't save document after indexing: %w", err)
 	}
 
 	AddDBLatency(time.Since(startDB))
+
+	if !applied {
+		IncrementDuplicate()
+		log.Printf("Message %s was already indexed, skipping", msg.ID)
+	}
 	return nil
 }
+
+// RepairDocuments re-indexes documents whose postings do not match the
+// posting count written with them, e.g. after a crash between writes made
+// before postings were transactional. Every call checks the next batch of
+// documents after where the previous one stopped, wrapping around at the
+// end, so a start never scans the whole table. Returns the number checked
+// and repaired.
+func RepairDocuments(ctx context.Context, store *storage.MinioStore, batch int) (int, int, error) {
+	if batch <= 0 {
+		return 0, 0, nil
+	}
+
+	var cursor string
+	err := db.Pool.QueryRow(ctx, `SELECT value FROM indexer_state WHERE name = 'repair_cursor'`).Scan(&cursor)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, 0, err
+	}
+
+	rows, err := db.Pool.Query(ctx, `
+	SELECT d.content_hash, COALESCE(d.url, ''), COALESCE(d.title, ''), COALESCE(d.snippet, ''),
+		COALESCE(d.object_key, ''), COALESCE(d.inbound_links, 0),
+		d.posting_count IS NULL OR d.posting_count <> (
+			SELECT count(*) FROM inverted_index i WHERE i.content_hash = d.content_hash
+		)
+	FROM documents d
+	WHERE d.content_hash > $1
+	ORDER BY d.content_hash
+	LIMIT $2
+	`, cursor, batch)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	checked := 0
+	var broken []Record
+	for rows.Next() {
+		var hashStr string
+		var record Record
+		var mismatch bool
+		if err := rows.Scan(&hashStr, &record.URL, &record.Title, &record.Snippet, &record.TextObjectKey, &record.InboundLinks, &mismatch); err != nil {
+			rows.Close()
+			return checked, 0, err
+		}
+		checked++
+		cursor = hashStr
+		if !mismatch {
+			continue
+		}
+		if record.ID, err = strconv.ParseUint(hashStr, 16, 64); err != nil {
+			log.Printf("Skipping document with bad content hash %q: %v", hashStr, err)
+			continue
+		}
+		broken = append(broken, record)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return checked, 0, err
+	}
+
+	// a short batch reached the end, the next one starts over
+	if checked < batch {
+		cursor = ""
+	}
+
+	repaired := 0
+
+	for i := range broken {
+		record := &broken[i]
+
+		data, err := store.GetObject(ctx, record.TextObjectKey)
+		if err != nil {
+			log.Printf("Can't repair %s, text object %s unavailable: %v", record.URL, record.TextObjectKey, err)
+			continue
+		}
+
+		doc := NewDocument(record)
+		doc.BuildIndex(string(data), record.ID)
+
+		if _, err := doc.Save(ctx, ""); err != nil {
+			return checked, repaired, fmt.Errorf("Can't repair document %s: %w", record.URL, err)
+		}
+		repaired++
+	}
+
+	_, err = db.Pool.Exec(ctx, `
+	INSERT INTO indexer_state (name, value) VALUES ('repair_cursor', $1)
+	ON CONFLICT (name) DO UPDATE SET value = EXCLUDED.value
+	`, cursor)
+	if err != nil {
+		return checked, repaired, err
+	}
+
+	return checked, repaired, nil
+}
+
+// PruneProcessedMessages drops the idempotency keys recorded before floor. A
+// message is added to the stream before it is processed, so those messages
+// are trimmed and can't be delivered again. Returns the number of rows
+// removed.
+func PruneProcessedMessages(ctx context.Context, floor time.Time) (int64, error) {
+	// processed_at is local to the session time zone, like NOW()
+	tag, err := db.Pool.Exec(ctx, `
+	DELETE FROM processed_messages
+	WHERE processed_at < to_timestamp($1::float8 / 1000)::timestamp
+	`, floor.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
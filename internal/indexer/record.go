@@ -1,12 +1,18 @@
 package indexer
 
+// Record is a parsed page to index. A record with a Cluster is the canonical
+// page of a duplicate cluster and replaces its aliases in search. A record
+// without a text object carries only a cluster's aliases.
 type Record struct {
-	ID            uint64 // text object Key
-	URL           string
-	TextObjectKey string
-	Title         string
-	Snippet       string
-	InboundLinks  int
+	ID             uint64 // text object Key
+	URL            string
+	TextObjectKey  string
+	Title          string
+	Snippet        string
+	InboundLinks   int
+	Cluster        string
+	ClusterVersion int
+	Aliases        []string
 }
 
 func NewRecord(hash uint64, rawUrl string, title string, snippet string, objectKey string, inboundLinks int) *Record {
@@ -19,3 +25,19 @@ func NewRecord(hash uint64, rawUrl string, title string, snippet string, objectK
 		InboundLinks:  inboundLinks,
 	}
 }
+
+// NewClusterRecord updates the aliases of a cluster whose canonical page is
+// indexed already.
+func NewClusterRecord(cluster string, version int, canonicalUrl string, aliases []string) *Record {
+	return &Record{
+		URL:            canonicalUrl,
+		Cluster:        cluster,
+		ClusterVersion: version,
+		Aliases:        aliases,
+	}
+}
+
+// AliasesOnly reports whether the record carries no page.
+func (r *Record) AliasesOnly() bool {
+	return r.TextObjectKey == "" && r.Cluster != ""
+}
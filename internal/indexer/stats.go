@@ -14,6 +14,8 @@ var (
 	successMsgs   int64
 	failedMsgs    int64
 	retriedMsgs   int64
+	deadMsgs      int64
+	duplicateMsgs int64
 
 	indexLatencyNs int64
 	indexCount     int64
@@ -38,6 +40,14 @@ func IncrementRetry() {
 	atomic.AddInt64(&retriedMsgs, 1)
 }
 
+func IncrementDead() {
+	atomic.AddInt64(&deadMsgs, 1)
+}
+
+func IncrementDuplicate() {
+	atomic.AddInt64(&duplicateMsgs, 1)
+}
+
 func AddIndexLatency(d time.Duration) {
 	atomic.AddInt64(&indexLatencyNs, d.Nanoseconds())
 	atomic.AddInt64(&indexCount, 1)
@@ -75,6 +85,8 @@ func FinalReport() {
 	success := atomic.LoadInt64(&successMsgs)
 	failed := atomic.LoadInt64(&failedMsgs)
 	retried := atomic.LoadInt64(&retriedMsgs)
+	dead := atomic.LoadInt64(&deadMsgs)
+	duplicates := atomic.LoadInt64(&duplicateMsgs)
 
 	indexLat := atomic.LoadInt64(&indexLatencyNs)
 	indexCnt := atomic.LoadInt64(&indexCount)
@@ -103,6 +115,8 @@ func FinalReport() {
 	log.Printf("%d successful", success)
 	log.Printf("%d failed", failed)
 	log.Printf("%d retried", retried)
+	log.Printf("%d dead-lettered", dead)
+	log.Printf("%d duplicate deliveries skipped", duplicates)
 	log.Printf("%.2f msgs/sec", pps)
 	log.Printf("%.2f ms avg indexing time", avgIndex)
 	log.Printf("%.2f ms avg DB time", avgDB)
@@ -13,11 +13,26 @@ import (
 )
 
 const (
-	TaskTimeout = 15 * time.Minute
-	StreamName  = "parser-events"
-	GroupName   = "indexer-group"
+	TaskTimeout       = 15 * time.Minute
+	RetryPollInterval = time.Second
+	StreamName        = "parser-events"
+	GroupName         = "indexer-group"
 )
 
+type Options struct {
+	TaskTimeout       time.Duration
+	RetryPollInterval time.Duration
+	Retention         streams.Config
+}
+
+func DefaultOptions() Options {
+	return Options{
+		TaskTimeout:       TaskTimeout,
+		RetryPollInterval: RetryPollInterval,
+		Retention:         streams.DefaultConfig(),
+	}
+}
+
 type ExecFunc func(ctx context.Context, msg *streams.Msg) error
 
 type Worker struct {
@@ -29,21 +44,28 @@ type Worker struct {
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
-	concurrency int
-	timeout     time.Duration
+	concurrency  int
+	timeout      time.Duration
+	retryPolling time.Duration
+	retention    streams.Config
 }
 
-func NewWorker(workerName string, stream *streams.MsgStream, concurrency int, exec ExecFunc) *Worker {
+func NewWorker(workerName string, stream *streams.MsgStream, concurrency int, exec ExecFunc, opts Options) *Worker {
 	ctx, cancel := context.WithCancel(context.Background())
 	workerID := fmt.Sprintf("%s-%s", workerName, uuid.New().String())
+	if opts.RetryPollInterval <= 0 {
+		opts.RetryPollInterval = RetryPollInterval
+	}
 	return &Worker{
-		ID:          workerID,
-		stream:      stream,
-		execFunc:    exec,
-		ctx:         ctx,
-		cancel:      cancel,
-		concurrency: concurrency,
-		timeout:     TaskTimeout,
+		ID:           workerID,
+		stream:       stream,
+		execFunc:     exec,
+		ctx:          ctx,
+		cancel:       cancel,
+		concurrency:  concurrency,
+		timeout:      opts.TaskTimeout,
+		retryPolling: opts.RetryPollInterval,
+		retention:    opts.Retention,
 	}
 }
 
@@ -54,6 +76,14 @@ func (w *Worker) Start() {
 		go w.processMessages()
 	}
 
+	w.wg.Add(1)
+	go w.moveRetries()
+
+	if w.retention.TrimInterval > 0 {
+		w.wg.Add(1)
+		go w.maintainStream()
+	}
+
 	log.Printf("Worker %s started successfully", w.ID)
 
 }
@@ -87,6 +117,59 @@ func (w *Worker) processMessages() {
 	}
 }
 
+// moveRetries re-publishes failed messages once their backoff has passed.
+func (w *Worker) moveRetries() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.retryPolling)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.stream.ProcessRetries(); err != nil {
+				log.Printf("Worker %s: Error moving retries: %v", w.ID, err)
+			}
+		}
+	}
+}
+
+// maintainStream trims acknowledged history, drops consumers left behind by
+// restarted indexers and logs how far each group is behind.
+func (w *Worker) maintainStream() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.retention.TrimInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := w.stream.Trim(w.retention); err != nil {
+			log.Printf("Worker %s: Error trimming stream: %v", w.ID, err)
+		}
+		if _, err := w.stream.CleanupConsumers(w.retention.StaleConsumerIdle); err != nil {
+			log.Printf("Worker %s: Error removing stale consumers: %v", w.ID, err)
+		}
+
+		status, err := w.stream.Status()
+		if err != nil {
+			log.Printf("Worker %s: Error reading stream status: %v", w.ID, err)
+			continue
+		}
+		for _, g := range status.Groups {
+			log.Printf("Stream %s group %s: %d pending (oldest %v), lag %d, %d consumers",
+				status.Stream, g.Name, g.Pending, g.OldestPendingAge, g.Lag, len(g.Consumers))
+		}
+	}
+}
+
 func (w *Worker) processMessage(message *streams.Msg) {
 	start := time.Now()
 	message.Status = streams.PROCESSING
@@ -140,7 +223,12 @@ func (w *Worker) completeMsgProcessing(msg *streams.Msg, success bool, errMsg st
 	queueLag := time.Since(msg.AddedAt)
 	log.Printf("Worker %s: Queue lag for msg %s: %v", w.ID, msg.ID, queueLag)
 
-	if err := w.stream.CompleteMessage(msg, success, StreamName, GroupName); err != nil {
+	if err := w.stream.CompleteMessage(msg, success, errMsg, StreamName, GroupName); err != nil {
 		log.Printf("Worker %s: Error completing message processing %s: %v", w.ID, msg.ID, err)
+		return
+	}
+
+	if msg.Status == streams.DEAD {
+		indexer.IncrementDead()
 	}
 }
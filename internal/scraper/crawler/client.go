@@ -1,19 +1,57 @@
 package crawler
 
 import (
-	"crypto/tls"
 	"net/http"
 	"time"
 )
 
+type Config struct {
+	FetchTimeout      time.Duration
+	RobotsTimeout     time.Duration
+	RobotsMaxAge      time.Duration
+	RobotsRetryTTL    time.Duration
+	DefaultCrawlDelay time.Duration
+
+	// adaptive per-host pacing
+	MinCrawlDelay  time.Duration
+	MaxCrawlDelay  time.Duration
+	SpeedupStep    time.Duration
+	BackoffFactor  float64
+	LatencyTarget  time.Duration
+	ErrorRateLimit float64
+	PacingIdleTTL  time.Duration // pacing state of a host not fetched for this long is dropped
+}
+
+func DefaultConfig() Config {
+	return Config{
+		FetchTimeout:      10 * time.Second,
+		RobotsTimeout:     10 * time.Second,
+		RobotsMaxAge:      24 * time.Hour,
+		RobotsRetryTTL:    10 * time.Minute,
+		DefaultCrawlDelay: 5 * time.Second,
+		MinCrawlDelay:     500 * time.Millisecond,
+		MaxCrawlDelay:     2 * time.Minute,
+		SpeedupStep:       250 * time.Millisecond,
+		BackoffFactor:     2,
+		LatencyTarget:     1500 * time.Millisecond,
+		ErrorRateLimit:    0.1,
+		PacingIdleTTL:     24 * time.Hour,
+	}
+}
+
+var crawlCfg = DefaultConfig()
+
 var crawlerClient = &http.Client{
 	Timeout: 10 * time.Second,
 }
 var robotsClient = &http.Client{
-	Timeout: 10 * time.Second,
-	Transport: &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: true,
-		},
-	},
+	Timeout:       10 * time.Second,
+	CheckRedirect: checkRobotsRedirect,
+}
+
+// Configure must be called before any worker starts fetching.
+func Configure(cfg Config) {
+	crawlerClient.Timeout = cfg.FetchTimeout
+	robotsClient.Timeout = cfg.RobotsTimeout
+	crawlCfg = cfg
 }
@@ -0,0 +1,232 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scripts"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	PacingKey      = "pacing:%s"
+	PacingHostsKey = "pacing:active" // host -> when its pacing state expires
+
+	pacingEwmaAlpha = 0.2
+)
+
+type FetchError struct {
+	URL string
+	Err error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("Error fetching request for %s: %v", e.URL, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+type StatusError struct {
+	URL        string
+	StatusCode int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded with status %d", e.URL, e.StatusCode)
+}
+
+type HostRate struct {
+	Host           string        `json:"host"`
+	Delay          time.Duration `json:"delay"`
+	Floor          time.Duration `json:"floor"`
+	RequestsPerMin float64       `json:"requests_per_min"`
+	AvgLatency     time.Duration `json:"avg_latency"`
+	ErrorRate      float64       `json:"error_rate"`
+	NextAllowed    time.Time     `json:"next_allowed"`
+}
+
+// the robots Crawl-delay is the hard floor, the crawler never goes faster
+func pacingFloor(meta *DomainMeta) time.Duration {
+	return max(meta.CrawlDelay, crawlCfg.MinCrawlDelay)
+}
+
+func reserveDomainAccess(ctx context.Context, domain string, meta *DomainMeta) (time.Duration, error) {
+	key := fmt.Sprintf(PacingKey, domain)
+
+	now := time.Now().UnixMilli()
+	floor := pacingFloor(meta)
+	initial := max(crawlCfg.DefaultCrawlDelay, floor)
+
+	wait, err := scripts.RateLimitScript.Run(
+		ctx,
+		storage.GetRedisClient(),
+		[]string{key, PacingHostsKey},
+		now,
+		floor.Milliseconds(),
+		initial.Milliseconds(),
+		domain,
+		crawlCfg.PacingIdleTTL.Milliseconds(),
+	).Int64()
+
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(wait) * time.Millisecond, nil
+}
+
+func fetchOutcome(err error) (string, time.Duration) {
+	if err == nil {
+		return "ok", 0
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode == http.StatusTooManyRequests {
+			return "throttle", statusErr.RetryAfter
+		}
+		return "error", statusErr.RetryAfter
+	}
+
+	var fetchErr *FetchError
+	if errors.As(err, &fetchErr) {
+		return "error", 0
+	}
+
+	// the host answered, whatever went wrong happened on our side
+	return "ok", 0
+}
+
+// reportFetch feeds the outcome of a request back into the host's pacing state.
+func reportFetch(ctx context.Context, domain string, meta *DomainMeta, latency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	outcome, retryAfter := fetchOutcome(err)
+
+	delay, rerr := scripts.RateFeedbackScript.Run(
+		ctx,
+		storage.GetRedisClient(),
+		[]string{fmt.Sprintf(PacingKey, domain), PacingHostsKey},
+		time.Now().UnixMilli(),
+		outcome,
+		latency.Milliseconds(),
+		pacingFloor(meta).Milliseconds(),
+		crawlCfg.MaxCrawlDelay.Milliseconds(),
+		crawlCfg.SpeedupStep.Milliseconds(),
+		crawlCfg.BackoffFactor,
+		crawlCfg.LatencyTarget.Milliseconds(),
+		crawlCfg.ErrorRateLimit,
+		pacingEwmaAlpha,
+		retryAfter.Milliseconds(),
+		crawlCfg.PacingIdleTTL.Milliseconds(),
+		domain,
+	).Int64()
+
+	if rerr != nil {
+		log.Printf("[Crawler] Failed to update pacing for %s: %v", domain, rerr)
+		return
+	}
+
+	if outcome != "ok" {
+		log.Printf("[Crawler] Backing off %s (%s): delay now %v", domain, outcome, time.Duration(delay)*time.Millisecond)
+	}
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0)
+	}
+
+	return 0
+}
+
+func HostRates(ctx context.Context, rdb *redis.Client) ([]HostRate, error) {
+	hosts, err := rdb.ZRangeByScore(ctx, PacingHostsKey, &redis.ZRangeBy{
+		Min: strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := rdb.Pipeline()
+	cmds := make([]*redis.SliceCmd, len(hosts))
+	for i, host := range hosts {
+		cmds[i] = pipe.HMGet(ctx, fmt.Sprintf(PacingKey, host), "delay", "floor", "latency", "errors", "next")
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	ms := func(v any) time.Duration {
+		s, _ := v.(string)
+		f, _ := strconv.ParseFloat(s, 64)
+		return time.Duration(f * float64(time.Millisecond))
+	}
+
+	rates := make([]HostRate, 0, len(hosts))
+	for i, host := range hosts {
+		vals := cmds[i].Val()
+		if len(vals) < 5 || vals[0] == nil {
+			continue
+		}
+
+		rate := HostRate{
+			Host:       host,
+			Delay:      ms(vals[0]),
+			Floor:      ms(vals[1]),
+			AvgLatency: ms(vals[2]),
+		}
+
+		if s, ok := vals[3].(string); ok {
+			rate.ErrorRate, _ = strconv.ParseFloat(s, 64)
+		}
+
+		if next := ms(vals[4]); next > 0 {
+			rate.NextAllowed = time.UnixMilli(next.Milliseconds())
+		}
+
+		if rate.Delay > 0 {
+			rate.RequestsPerMin = float64(time.Minute) / float64(rate.Delay)
+		}
+
+		rates = append(rates, rate)
+	}
+
+	sort.Slice(rates, func(i, j int) bool {
+		return rates[i].Host < rates[j].Host
+	})
+
+	return rates, nil
+}
+
+// NextFetchTime is when the host's pacing allows the next request, the
+// frontier parks the host until then.
+func NextFetchTime(ctx context.Context, host string) time.Time {
+	next, err := storage.GetRedisClient().HGet(ctx, fmt.Sprintf(PacingKey, host), "next").Int64()
+	if err != nil {
+		return time.Now()
+	}
+	return time.UnixMilli(next)
+}
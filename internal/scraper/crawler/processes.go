@@ -1,6 +1,7 @@
 package crawler
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -16,30 +17,18 @@ import (
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/deduplication"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/parsing"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
-	"github.com/KingrogKDR/Dev-Search/internal/scraper/scripts"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/seeds"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/stats"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/templates"
 	"github.com/KingrogKDR/Dev-Search/internal/storage"
-	"github.com/redis/go-redis/v9"
-	"github.com/temoto/robotstxt"
-	"golang.org/x/sync/singleflight"
+	"github.com/PuerkitoBio/goquery"
 )
 
-type DomainMeta struct {
-	Host            string        `json:"host"`
-	RobotsRaw       []byte        `json:"robots_raw"`
-	NextAllowedTime time.Time     `json:"next_allowed_time"`
-	CrawlDelay      time.Duration `json:"crawl_delay"`
-}
-
-const (
-	UserAgent     = "Dev_Search/1.0"
-	DomainMetaKey = "domainmeta:%s"
-)
+const UserAgent = "Dev_Search/1.0"
 
-var domainMetaGroup singleflight.Group
 var ErrRateLimited = errors.New("rate limited")
 
-func FetchAndStoreRaw(ctx context.Context, job *queues.Job, simIndex *deduplication.SimhashIndex, store *storage.MinioStore, parseQ *queues.Queue) error {
+func FetchAndStoreRaw(ctx context.Context, job *queues.Job, dedup deduplication.Deduplicator, clusters *deduplication.Clusters, learner *templates.Learner, store *storage.MinioStore, parseQ *queues.Queue) error {
 	log.Printf("[Crawler] Starting job %s for URL: %s", job.ID, job.URL)
 	parsed, err := url.Parse(job.URL)
 	if err != nil {
@@ -56,14 +45,16 @@ func FetchAndStoreRaw(ctx context.Context, job *queues.Job, simIndex *deduplicat
 		return fmt.Errorf("Unable to get domain meta: %w", err)
 	}
 
-	isPathAllowed, err := isAllowedByRobots(meta, rawUrl)
+	isPathAllowed, _, rule := isAllowedByRobots(meta, parsed)
 
-	if err != nil {
-		return fmt.Errorf("Robots error: %w", err)
+	if !isPathAllowed && meta.RobotsStatus == ROBOTS_UNREACHABLE {
+		// complete disallow until robots.txt can be fetched again
+		log.Printf("[Crawler] robots.txt unreachable for %s, retrying after %s", domain, meta.ExpiresAt.Format(time.RFC3339))
+		return fmt.Errorf("%w:%d", ErrRateLimited, max(time.Until(meta.ExpiresAt), time.Second).Milliseconds())
 	}
 
 	if !isPathAllowed {
-		log.Printf("[Crawler] Robots.txt blocked URL: %s", rawUrl)
+		log.Printf("[Crawler] Robots.txt blocked URL: %s (rule %q)", rawUrl, rule.Pattern)
 		return nil
 	}
 
@@ -77,45 +68,74 @@ func FetchAndStoreRaw(ctx context.Context, job *queues.Job, simIndex *deduplicat
 	}
 	if domain == "github.com" {
 		log.Printf("[Crawler] Detected GitHub repo URL: %s", rawUrl)
-		return processGithubRepo(ctx, parsed, simIndex, store, parseQ)
+		err := processGithubRepo(ctx, parsed, meta, dedup, clusters, store, parseQ)
+		if err == nil {
+			recordSeedCrawl(ctx, job)
+		}
+		return err
 	}
 
 	log.Printf("[Crawler] Fetching URL: %s", rawUrl)
 	startFetch := time.Now()
 
-	body, err := fetchReq(ctx, rawUrl)
+	body, header, err := fetchReq(ctx, rawUrl)
+	reportFetch(ctx, domain, meta, time.Since(startFetch), err)
 
 	if err != nil {
 		return fmt.Errorf("Can't fetch from %s: can't read response body: %w", rawUrl, err)
 	}
 	stats.AddFetchLatency(time.Since(startFetch))
+	recordSeedCrawl(ctx, job)
 
 	stats.AddBytes(int64(len(body)))
 
 	log.Printf("[Crawler] Fetched %d bytes from %s", len(body), rawUrl)
 
-	cleanedText, err := deduplication.CleanData(string(body), deduplication.SourceHTML)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("Can't clean html: %w", err)
 	}
 
-	log.Printf("[Crawler] Cleaned text length: %d", len(cleanedText))
+	// blocks repeated all over the host would make its pages look alike
+	removed, err := learner.Strip(ctx, domain, doc, true)
+	if err != nil {
+		log.Printf("[Crawler] %v", err)
+	} else if removed > 0 {
+		log.Printf("[Crawler] Stripped %d template blocks from %s", removed, rawUrl)
+	}
 
-	tokens := deduplication.Tokenize(cleanedText)
-	log.Printf("[Crawler] Tokens generated: %d", len(tokens))
+	cleanedText := deduplication.CleanDocument(doc)
 
-	shingles := deduplication.Shingles(tokens, deduplication.ShingleSize)
-	log.Printf("[Crawler] Shingles generated: %d", len(shingles))
+	log.Printf("[Crawler] Cleaned text length: %d", len(cleanedText))
 
-	hash := deduplication.SimHash(shingles)
-	log.Printf("[Crawler] SimHash computed: %d", hash)
+	match, isDup, err := dedup.Check(ctx, cleanedText, job.URL)
+	if err != nil {
+		return fmt.Errorf("Can't check for duplicates: %w", err)
+	}
 
-	isDup := simIndex.IsNearDuplicate(hash, deduplication.MaxHammingDist)
+	directives := parsing.ParseDirectives(header.Values("X-Robots-Tag"), string(body), productToken)
+	page := deduplication.Member{
+		URL:       job.URL,
+		Canonical: parsing.CanonicalTarget(string(body), parsed),
+		NoIndex:   directives.NoIndex,
+	}
 
+	cluster := ""
 	if isDup {
-		stats.IncrementDuplicate()
-		log.Printf("[Crawler] Duplicate page detected: %s (hash=%d)", job.URL, hash)
-		return nil
+		var keep bool
+		cluster, keep, err = keepDuplicate(ctx, clusters, match, page, parseQ)
+		if err != nil {
+			return fmt.Errorf("Can't record duplicate: %w", err)
+		}
+
+		if !keep {
+			stats.IncrementDuplicate()
+			log.Printf("[Crawler] Duplicate page detected: %s of %s (fingerprint=%x)", job.URL, match.Doc, match.Fingerprint)
+			return nil
+		}
+		log.Printf("[Crawler] %s is canonical for its duplicates of %s (cluster %s)", job.URL, match.Doc, cluster)
+	} else if err := clusters.RecordPage(ctx, page); err != nil {
+		return fmt.Errorf("Can't record page for duplicate clusters: %w", err)
 	}
 
 	contentHash := deduplication.ComputeHash(cleanedText)
@@ -133,6 +153,8 @@ func FetchAndStoreRaw(ctx context.Context, job *queues.Job, simIndex *deduplicat
 	log.Printf("[Crawler] Stored page successfully: %s", job.URL)
 
 	parsePayload := parsing.NewParsePayload(objectKey, contentHash, "html")
+	parsePayload.Directives = directives
+	parsePayload.Cluster = cluster
 
 	payloadBytes, err := json.Marshal(parsePayload)
 
@@ -148,7 +170,7 @@ func FetchAndStoreRaw(ctx context.Context, job *queues.Job, simIndex *deduplicat
 	parseJob.Type = string(queues.JOB_PARSE)
 	parseJob.Payload = payloadBytes
 	log.Printf("[Crawler] Parse payload: key=%s hash=%d", objectKey, contentHash)
-	err = parseQ.Enqueue(parseJob)
+	_, err = parseQ.Enqueue(parseJob)
 	if err != nil {
 		return fmt.Errorf("failed to enqueue parse job: %w", err)
 	}
@@ -158,141 +180,92 @@ func FetchAndStoreRaw(ctx context.Context, job *queues.Job, simIndex *deduplicat
 	return nil
 }
 
-func reserveDomainAccess(ctx context.Context, domain string, meta *DomainMeta) (time.Duration, error) {
-
-	key := fmt.Sprintf("ratelimit:%s", domain)
-
-	now := time.Now().UnixMilli()
-	delay := max(meta.CrawlDelay, 5*time.Second)
-
-	wait, err := scripts.RateLimitScript.Run(
-		ctx,
-		storage.GetRedisClient(),
-		[]string{key},
-		now,
-		delay,
-	).Int64()
-
-	if err != nil {
-		return 0, err
-	}
-
-	return time.Duration(wait) * time.Millisecond, nil
-}
-
-func getDomainMetadata(ctx context.Context, domain string, scheme string) (*DomainMeta, error) {
-	rdb := storage.GetRedisClient()
-	key := fmt.Sprintf(DomainMetaKey, domain)
-
-	data, err := rdb.Get(ctx, key).Bytes()
-
-	if err == nil {
-		var meta DomainMeta
-		if err := json.Unmarshal(data, &meta); err != nil {
-			return nil, err
-		}
-		return &meta, nil
-	}
-
-	if err != redis.Nil {
-		return nil, err
+// keepDuplicate adds a near-duplicate page to the cluster of the page it
+// matched and reports whether it became the canonical member, whose page is
+// kept and indexed in place of the others. Otherwise the page is dropped and
+// the indexer only learns the cluster's new aliases. A page matching itself,
+// e.g. on a recrawl, is simply dropped. The matched page is entered with the
+// rel=canonical and noindex recorded when it was indexed.
+func keepDuplicate(ctx context.Context, clusters *deduplication.Clusters, match deduplication.Match, page deduplication.Member, parseQ *queues.Queue) (string, bool, error) {
+	if match.Doc == "" || match.Doc == page.URL {
+		return "", false, nil
 	}
+	pageUrl := page.URL
 
-	v, err, _ := domainMetaGroup.Do(domain, func() (any, error) {
-		robotsUrl := fmt.Sprintf("%s://%s/robots.txt", scheme, domain)
-
-		body, err := fetchRobots(ctx, robotsUrl)
-		if err != nil {
-			return nil, err
-		}
-
-		robots, _ := robotstxt.FromBytes(body)
-
-		delay := 5 * time.Second
-
-		if robots != nil {
-			group := robots.FindGroup(UserAgent)
-			if group.CrawlDelay > 0 {
-				delay = group.CrawlDelay
-			}
-		}
-
-		meta := DomainMeta{
-			Host:            domain,
-			RobotsRaw:       body,
-			CrawlDelay:      delay,
-			NextAllowedTime: time.Now(),
-		}
-
-		encoded, err := json.Marshal(meta)
-		if err != nil {
-			return nil, err
-		}
-
-		if err := rdb.Set(ctx, key, encoded, 24*time.Hour).Err(); err != nil {
-			return nil, err
-		}
-
-		return &meta, nil
-	})
-
+	id := deduplication.ClusterID(match.Fingerprint)
+	first, err := clusters.Page(ctx, match.Doc)
 	if err != nil {
-		return nil, err
+		return "", false, err
 	}
+	first.Authority = inboundLinks(ctx, match.Doc)
+	page.Authority = inboundLinks(ctx, pageUrl)
 
-	return v.(*DomainMeta), nil
-}
-
-func fetchRobots(ctx context.Context, robotsURL string) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
-	if err != nil {
-		return nil, err
+	_, keep, err := clusters.Join(ctx, id, first, page)
+	if err != nil || keep {
+		return id, keep, err
 	}
 
-	resp, err := robotsClient.Do(req)
+	payloadBytes, err := json.Marshal(parsing.NewAliasPayload(id))
 	if err != nil {
-		return nil, err
+		return "", false, fmt.Errorf("failed marshaling alias payload: %w", err)
 	}
 
-	defer resp.Body.Close()
+	aliasJob := queues.NewJob(pageUrl)
+	aliasJob.Type = string(queues.JOB_PARSE)
+	aliasJob.Payload = payloadBytes
+	if _, err := parseQ.Enqueue(aliasJob); err != nil {
+		return "", false, fmt.Errorf("failed to enqueue alias job: %w", err)
+	}
 
-	return io.ReadAll(resp.Body)
+	return id, false, nil
 }
 
-func isAllowedByRobots(meta *DomainMeta, rawUrl string) (bool, error) {
-	parsed, err := url.Parse(rawUrl)
-	if err != nil {
-		return false, fmt.Errorf("invalid url: %w", err)
+func inboundLinks(ctx context.Context, rawUrl string) int {
+	meta, err := parsing.GetUrlMeta(ctx, rawUrl)
+	if err != nil || meta == nil {
+		return 0
 	}
+	return meta.InboundLinks
+}
 
-	if len(meta.RobotsRaw) == 0 {
-		log.Printf("No robots.txt found for this url: %s", rawUrl)
-		return true, nil
+func recordSeedCrawl(ctx context.Context, job *queues.Job) {
+	if !job.Seed {
+		return
 	}
 
-	robots, err := robotstxt.FromBytes(meta.RobotsRaw)
-	if err != nil || robots == nil {
-		return true, nil
+	if err := seeds.NewRegistry(storage.GetRedisClient()).RecordCrawl(ctx, job.URL); err != nil {
+		log.Printf("[Crawler] Failed to record seed crawl for %s: %v", job.URL, err)
 	}
-
-	group := robots.FindGroup(UserAgent)
-
-	return group.Test(parsed.Path), nil
 }
 
-func fetchReq(ctx context.Context, rawUrl string) ([]byte, error) {
+func fetchReq(ctx context.Context, rawUrl string) ([]byte, http.Header, error) {
 	request, err := http.NewRequestWithContext(ctx, "GET", rawUrl, nil)
 	if err != nil {
-		return nil, fmt.Errorf("creating request for %s: %w", rawUrl, err)
+		return nil, nil, fmt.Errorf("creating request for %s: %w", rawUrl, err)
 	}
 	request.Header.Set("User-Agent", UserAgent)
 
 	resp, err := crawlerClient.Do(request)
 	if err != nil {
-		return nil, fmt.Errorf("Error fetching request for %s: %w", rawUrl, err)
+		return nil, nil, &FetchError{URL: rawUrl, Err: err}
 	}
 	defer resp.Body.Close()
-	return io.ReadAll(resp.Body)
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		io.Copy(io.Discard, resp.Body)
+		return nil, nil, &StatusError{
+			URL:        rawUrl,
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, &FetchError{URL: rawUrl, Err: err}
+	}
+
+	return body, resp.Header, nil
 }
 
 type githubReadme struct {
@@ -300,7 +273,7 @@ type githubReadme struct {
 	Encoding string `json:"encoding"`
 }
 
-func processGithubRepo(ctx context.Context, parsed *url.URL, simIndex *deduplication.SimhashIndex, store *storage.MinioStore, parseQ *queues.Queue) error {
+func processGithubRepo(ctx context.Context, parsed *url.URL, meta *DomainMeta, dedup deduplication.Deduplicator, clusters *deduplication.Clusters, store *storage.MinioStore, parseQ *queues.Queue) error {
 
 	repoURL := parsed.String()
 	log.Printf("[GitHub] Processing repo URL: %s", repoURL)
@@ -326,7 +299,9 @@ func processGithubRepo(ctx context.Context, parsed *url.URL, simIndex *deduplica
 
 	log.Printf("[GitHub] Fetching README via API: %s", api)
 
-	body, err := fetchReq(ctx, api)
+	startFetch := time.Now()
+	body, _, err := fetchReq(ctx, api)
+	reportFetch(ctx, parsed.Hostname(), meta, time.Since(startFetch), err)
 
 	if err != nil {
 		return fmt.Errorf("Can't fetch repo from %s: can't read response body: %w", api, err)
@@ -360,20 +335,23 @@ func processGithubRepo(ctx context.Context, parsed *url.URL, simIndex *deduplica
 
 	log.Printf("[GitHub] Cleaned markdown length: %d", len(cleanedText))
 
-	tokens := deduplication.Tokenize(cleanedText)
-	log.Printf("[GitHub] Tokens generated: %d", len(tokens))
-
-	shingles := deduplication.Shingles(tokens, deduplication.ShingleSize)
-	log.Printf("[GitHub] Shingles generated: %d", len(shingles))
-
-	hash := deduplication.SimHash(shingles)
-	log.Printf("[GitHub] SimHash computed: %d", hash)
-
-	isDup := simIndex.IsNearDuplicate(hash, deduplication.MaxHammingDist)
+	match, isDup, err := dedup.Check(ctx, cleanedText, repoURL)
+	if err != nil {
+		return fmt.Errorf("Can't check for duplicates: %w", err)
+	}
 
+	cluster := ""
 	if isDup {
-		log.Printf("[GitHub] Duplicate repo README detected: %s (hash=%d)", repoURL, hash)
-		return nil
+		var keep bool
+		cluster, keep, err = keepDuplicate(ctx, clusters, match, deduplication.Member{URL: repoURL}, parseQ)
+		if err != nil {
+			return fmt.Errorf("Can't record duplicate: %w", err)
+		}
+
+		if !keep {
+			log.Printf("[GitHub] Duplicate repo README detected: %s of %s (fingerprint=%x)", repoURL, match.Doc, match.Fingerprint)
+			return nil
+		}
 	}
 
 	contentHash := deduplication.ComputeHash(cleanedText)
@@ -388,6 +366,7 @@ func processGithubRepo(ctx context.Context, parsed *url.URL, simIndex *deduplica
 	log.Printf("[GitHub] Stored README successfully for repo: %s/%s", owner, repo)
 
 	parsePayload := parsing.NewParsePayload(objectKey, contentHash, "md")
+	parsePayload.Cluster = cluster
 
 	payloadBytes, err := json.Marshal(parsePayload)
 
@@ -403,7 +382,7 @@ func processGithubRepo(ctx context.Context, parsed *url.URL, simIndex *deduplica
 		return fmt.Errorf("missing payload for job %s", parseJob.ID)
 	}
 
-	err = parseQ.Enqueue(parseJob)
+	_, err = parseQ.Enqueue(parseJob)
 	if err != nil {
 		return fmt.Errorf("failed to enqueue parse job: %w", err)
 	}
@@ -0,0 +1,268 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/robots"
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// RobotsStatus follows the RFC 9309 access results for fetching robots.txt.
+type RobotsStatus string
+
+const (
+	ROBOTS_OK          RobotsStatus = "ok"          // 2xx, the rules apply
+	ROBOTS_UNAVAILABLE RobotsStatus = "unavailable" // 4xx or too many redirects, everything is allowed
+	ROBOTS_UNREACHABLE RobotsStatus = "unreachable" // 5xx or network error, everything is disallowed
+)
+
+const (
+	DomainMetaKey = "domainmeta:%s"
+
+	maxRobotsRedirects = 5
+	// an unreachable robots.txt stops blocking the host after 30 days
+	unreachableGiveUp = 30 * 24 * time.Hour
+)
+
+type DomainMeta struct {
+	Host             string        `json:"host"`
+	RobotsRaw        []byte        `json:"robots_raw"`
+	RobotsStatus     RobotsStatus  `json:"robots_status"`
+	FetchedAt        time.Time     `json:"fetched_at"`
+	ExpiresAt        time.Time     `json:"expires_at"`
+	UnreachableSince time.Time     `json:"unreachable_since,omitzero"`
+	NextAllowedTime  time.Time     `json:"next_allowed_time"`
+	CrawlDelay       time.Duration `json:"crawl_delay"`
+}
+
+type RobotsVerdict struct {
+	URL        string        `json:"url"`
+	Host       string        `json:"host"`
+	Allowed    bool          `json:"allowed"`
+	Status     RobotsStatus  `json:"status"`
+	Agents     []string      `json:"agents,omitempty"`
+	Rule       *robots.Rule  `json:"rule,omitempty"`
+	CrawlDelay time.Duration `json:"crawl_delay"`
+	FetchedAt  time.Time     `json:"fetched_at"`
+	ExpiresAt  time.Time     `json:"expires_at"`
+}
+
+var domainMetaGroup singleflight.Group
+var errTooManyRedirects = errors.New("too many robots.txt redirects")
+
+// productToken is what robots.txt groups are matched against, e.g. "dev_search"
+var productToken = robots.ProductToken(UserAgent)
+
+func checkRobotsRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRobotsRedirects {
+		return errTooManyRedirects
+	}
+	return nil
+}
+
+func getDomainMetadata(ctx context.Context, domain string, scheme string) (*DomainMeta, error) {
+	rdb := storage.GetRedisClient()
+	key := fmt.Sprintf(DomainMetaKey, domain)
+
+	var previous *DomainMeta
+
+	data, err := rdb.Get(ctx, key).Bytes()
+
+	if err == nil {
+		var meta DomainMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return nil, err
+		}
+		if time.Now().Before(meta.ExpiresAt) {
+			return &meta, nil
+		}
+		previous = &meta
+	} else if err != redis.Nil {
+		return nil, err
+	}
+
+	v, err, _ := domainMetaGroup.Do(domain, func() (any, error) {
+		robotsUrl := fmt.Sprintf("%s://%s/robots.txt", scheme, domain)
+
+		meta, err := fetchRobots(ctx, robotsUrl)
+		if err != nil {
+			return nil, err
+		}
+		meta.Host = domain
+		meta.NextAllowedTime = meta.FetchedAt
+
+		if meta.RobotsStatus == ROBOTS_UNREACHABLE {
+			meta.UnreachableSince = meta.FetchedAt
+			if previous != nil && previous.RobotsStatus == ROBOTS_UNREACHABLE && !previous.UnreachableSince.IsZero() {
+				meta.UnreachableSince = previous.UnreachableSince
+			}
+
+			if meta.FetchedAt.Sub(meta.UnreachableSince) > unreachableGiveUp {
+				log.Printf("[Robots] %s unreachable since %s, treating as unavailable", robotsUrl, meta.UnreachableSince)
+				meta.RobotsStatus = ROBOTS_UNAVAILABLE
+				meta.UnreachableSince = time.Time{}
+			}
+		}
+
+		encoded, err := json.Marshal(meta)
+		if err != nil {
+			return nil, err
+		}
+
+		// unreachable entries outlive their expiry so the outage start survives refetches
+		ttl := time.Until(meta.ExpiresAt)
+		if meta.RobotsStatus == ROBOTS_UNREACHABLE {
+			ttl = unreachableGiveUp
+		}
+
+		if err := rdb.Set(ctx, key, encoded, ttl).Err(); err != nil {
+			return nil, err
+		}
+
+		return meta, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*DomainMeta), nil
+}
+
+// fetchRobots only errors when the context is done, every other failure is
+// an access result that gets cached.
+func fetchRobots(ctx context.Context, robotsURL string) (*DomainMeta, error) {
+	now := time.Now()
+	meta := &DomainMeta{
+		FetchedAt: now,
+		ExpiresAt: now.Add(crawlCfg.RobotsRetryTTL),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+
+	resp, err := robotsClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if errors.Is(err, errTooManyRedirects) {
+			meta.RobotsStatus = ROBOTS_UNAVAILABLE
+			meta.ExpiresAt = now.Add(crawlCfg.RobotsMaxAge)
+			return meta, nil
+		}
+		log.Printf("[Robots] %s unreachable: %v", robotsURL, err)
+		meta.RobotsStatus = ROBOTS_UNREACHABLE
+		return meta, nil
+	}
+
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		body, err := io.ReadAll(io.LimitReader(resp.Body, robots.MaxSize))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			meta.RobotsStatus = ROBOTS_UNREACHABLE
+			return meta, nil
+		}
+
+		meta.RobotsStatus = ROBOTS_OK
+		meta.RobotsRaw = body
+		meta.ExpiresAt = now.Add(robotsMaxAge(resp.Header))
+		meta.CrawlDelay = robots.Parse(body).Match(productToken).CrawlDelay
+
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		meta.RobotsStatus = ROBOTS_UNAVAILABLE
+		meta.ExpiresAt = now.Add(robotsMaxAge(resp.Header))
+
+	default:
+		meta.RobotsStatus = ROBOTS_UNREACHABLE
+	}
+
+	return meta, nil
+}
+
+// cache lifetime from Cache-Control, never longer than RobotsMaxAge
+func robotsMaxAge(h http.Header) time.Duration {
+	maxAge := crawlCfg.RobotsMaxAge
+
+	for directive := range strings.SplitSeq(h.Get("Cache-Control"), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			return crawlCfg.RobotsRetryTTL
+		case "max-age":
+			secs, err := strconv.Atoi(strings.Trim(value, `"`))
+			if err != nil || secs < 0 {
+				continue
+			}
+			maxAge = min(maxAge, max(time.Duration(secs)*time.Second, crawlCfg.RobotsRetryTTL))
+		}
+	}
+
+	return maxAge
+}
+
+func isAllowedByRobots(meta *DomainMeta, parsed *url.URL) (bool, *robots.Group, *robots.Rule) {
+	switch meta.RobotsStatus {
+	case ROBOTS_UNAVAILABLE:
+		return true, nil, nil
+	case ROBOTS_UNREACHABLE:
+		return false, nil, nil
+	}
+
+	group := robots.Parse(meta.RobotsRaw).Match(productToken)
+	allowed, rule := group.Test(parsed)
+
+	return allowed, group, rule
+}
+
+// TestRobots checks a url against the cached robots.txt of its host,
+// fetching it first when nothing is cached.
+func TestRobots(ctx context.Context, rawUrl string) (*RobotsVerdict, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid url %q", rawUrl)
+	}
+
+	meta, err := getDomainMetadata(ctx, parsed.Hostname(), parsed.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, group, rule := isAllowedByRobots(meta, parsed)
+
+	verdict := &RobotsVerdict{
+		URL:        parsed.String(),
+		Host:       meta.Host,
+		Allowed:    allowed,
+		Status:     meta.RobotsStatus,
+		Rule:       rule,
+		CrawlDelay: meta.CrawlDelay,
+		FetchedAt:  meta.FetchedAt,
+		ExpiresAt:  meta.ExpiresAt,
+	}
+	if group != nil {
+		verdict.Agents = group.Agents
+	}
+
+	return verdict, nil
+}
@@ -33,6 +33,13 @@ func cleanHtml(html string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+
+	return CleanDocument(doc), nil
+}
+
+// CleanDocument is the lowercased text of a parsed HTML page without
+// scripts, styles and navigation. It removes those from doc.
+func CleanDocument(doc *goquery.Document) string {
 	doc.Find("script, style, nav, footer, aside").Remove()
 
 	text := doc.Text()
@@ -41,8 +48,7 @@ func cleanHtml(html string) (string, error) {
 
 	text = strings.ToLower(text)
 
-	return strings.TrimSpace(text), nil
-
+	return strings.TrimSpace(text)
 }
 
 var (
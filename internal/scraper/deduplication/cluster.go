@@ -0,0 +1,286 @@
+package deduplication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ClusterKey        = "dedup:cluster:%s"
+	ClusterMembersKey = "dedup:cluster:members" // url -> cluster id
+	ClusterPagesKey   = "dedup:cluster:pages"   // url -> member fields of an indexed page that matter to elections
+
+	// members kept per cluster, later ones are only counted
+	maxClusterMembers = 100
+	maxJoinAttempts   = 5
+)
+
+// Canonical rules, checked in order until one prefers a member.
+const (
+	CANONICAL_REL       = "rel_canonical" // the member most other members name as rel=canonical
+	CANONICAL_AUTHORITY = "authority"     // the member with the most inbound links
+	CANONICAL_SHORTEST  = "shortest_url"  // the member with the shortest URL
+)
+
+func isCanonicalRule(rule string) bool {
+	switch rule {
+	case CANONICAL_REL, CANONICAL_AUTHORITY, CANONICAL_SHORTEST:
+		return true
+	}
+	return false
+}
+
+// Member is one URL of a duplicate cluster.
+type Member struct {
+	URL       string    `json:"url"`
+	Authority int       `json:"authority"`           // inbound links when it last joined
+	Canonical string    `json:"canonical,omitempty"` // the page's rel=canonical target
+	NoIndex   bool      `json:"noindex,omitempty"`   // the page may not be indexed
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+// Cluster groups the URLs of near-duplicate pages. Only the canonical member
+// is indexed, the others are its aliases. Version counts the changes so the
+// indexer can drop updates that arrive out of order.
+type Cluster struct {
+	ID        string   `json:"id"` // fingerprint of the first member
+	Canonical string   `json:"canonical"`
+	Members   []Member `json:"members"`
+	Dropped   int      `json:"dropped,omitempty"` // members beyond the limit
+	Version   int      `json:"version"`
+}
+
+// ClusterID names the cluster of an indexed fingerprint.
+func ClusterID(fp uint64) string {
+	return fingerprintHex(fp)
+}
+
+// Aliases are the member URLs other than the canonical one.
+func (c *Cluster) Aliases() []string {
+	var aliases []string
+	for _, m := range c.Members {
+		if m.URL != c.Canonical {
+			aliases = append(aliases, m.URL)
+		}
+	}
+	return aliases
+}
+
+func (c *Cluster) member(u string) int {
+	for i, m := range c.Members {
+		if m.URL == u {
+			return i
+		}
+	}
+	return -1
+}
+
+// add inserts or refreshes a member and reports whether it is in the cluster.
+func (c *Cluster) add(m Member) bool {
+	if i := c.member(m.URL); i >= 0 {
+		m.JoinedAt = c.Members[i].JoinedAt
+		c.Members[i] = m
+		return true
+	}
+	if len(c.Members) >= maxClusterMembers {
+		c.Dropped++
+		return false
+	}
+	c.Members = append(c.Members, m)
+	return true
+}
+
+// votes counts the other members naming u as their rel=canonical target. A
+// page naming itself says nothing about its duplicates, most pages do.
+func (c *Cluster) votes(u string) int {
+	n := 0
+	for _, m := range c.Members {
+		if m.Canonical == u && m.URL != u {
+			n++
+		}
+	}
+	return n
+}
+
+// Elect decides between the canonical member and a challenger by the rules
+// in order, and reports whether the challenger takes over. A tie keeps the
+// canonical member. Only the challenger can take over: the other members'
+// pages were dropped when they joined. A page that may not be indexed never
+// takes over from one that may, the cluster would drop out of search.
+func (c *Cluster) Elect(rules []string, challenger string) bool {
+	i, j := c.member(c.Canonical), c.member(challenger)
+	if j < 0 || challenger == c.Canonical {
+		return false
+	}
+	if i < 0 {
+		c.Canonical = challenger
+		return true
+	}
+	cur, ch := c.Members[i], c.Members[j]
+
+	if cur.NoIndex != ch.NoIndex {
+		if cur.NoIndex {
+			c.Canonical = challenger
+		}
+		return cur.NoIndex
+	}
+
+	for _, rule := range rules {
+		var a, b int
+		switch rule {
+		case CANONICAL_REL:
+			a, b = c.votes(cur.URL), c.votes(ch.URL)
+		case CANONICAL_AUTHORITY:
+			a, b = cur.Authority, ch.Authority
+		case CANONICAL_SHORTEST:
+			a, b = -len(cur.URL), -len(ch.URL)
+		}
+		if b > a {
+			c.Canonical = challenger
+			return true
+		}
+		if a > b {
+			return false
+		}
+	}
+	return false
+}
+
+// Clusters keeps the duplicate clusters in Redis, shared by every scraper.
+type Clusters struct {
+	rdb   *redis.Client
+	rules []string
+}
+
+func NewClusters(rdb *redis.Client, rules []string) *Clusters {
+	return &Clusters{rdb: rdb, rules: rules}
+}
+
+// Get returns the cluster, or nil when there is none.
+func (cs *Clusters) Get(ctx context.Context, id string) (*Cluster, error) {
+	data, err := cs.rdb.Get(ctx, fmt.Sprintf(ClusterKey, id)).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Cluster
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("bad cluster %s: %w", id, err)
+	}
+	return &c, nil
+}
+
+// RecordPage remembers what an indexed page brings to the elections of the
+// cluster it may start later. Pages without a rel=canonical elsewhere and
+// without noindex are the common case and aren't stored.
+func (cs *Clusters) RecordPage(ctx context.Context, m Member) error {
+	if (m.Canonical == "" || m.Canonical == m.URL) && !m.NoIndex {
+		return nil
+	}
+
+	data, err := json.Marshal(Member{Canonical: m.Canonical, NoIndex: m.NoIndex})
+	if err != nil {
+		return err
+	}
+	return cs.rdb.HSet(ctx, ClusterPagesKey, m.URL, data).Err()
+}
+
+// Page returns the member fields recorded for an indexed page.
+func (cs *Clusters) Page(ctx context.Context, u string) (Member, error) {
+	m := Member{URL: u}
+
+	data, err := cs.rdb.HGet(ctx, ClusterPagesKey, u).Bytes()
+	if err == redis.Nil {
+		return m, nil
+	}
+	if err != nil {
+		return m, err
+	}
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("bad page record for %s: %w", u, err)
+	}
+	m.URL = u
+	return m, nil
+}
+
+// Lookup returns the cluster a URL belongs to, or nil.
+func (cs *Clusters) Lookup(ctx context.Context, u string) (*Cluster, error) {
+	id, err := cs.rdb.HGet(ctx, ClusterMembersKey, u).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cs.Get(ctx, id)
+}
+
+// Join adds m to the cluster of an indexed page, creating it with first as
+// its canonical member, and elects the canonical member again. It reports
+// whether m is now the canonical member, in which case its page must be
+// kept and the previous canonical one becomes an alias.
+func (cs *Clusters) Join(ctx context.Context, id string, first Member, m Member) (*Cluster, bool, error) {
+	key := fmt.Sprintf(ClusterKey, id)
+	now := time.Now()
+
+	for range maxJoinAttempts {
+		var c *Cluster
+		var won bool
+
+		err := cs.rdb.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Bytes()
+			switch {
+			case err == redis.Nil:
+				first.JoinedAt = now
+				c = &Cluster{ID: id, Canonical: first.URL, Members: []Member{first}}
+			case err != nil:
+				return err
+			default:
+				c = &Cluster{}
+				if err := json.Unmarshal(data, c); err != nil {
+					return fmt.Errorf("bad cluster %s: %w", id, err)
+				}
+			}
+
+			m.JoinedAt = now
+			if c.add(m) {
+				c.Elect(cs.rules, m.URL)
+			}
+			won = c.Canonical == m.URL
+			c.Version++
+
+			out, err := json.Marshal(c)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, out, 0)
+				for _, member := range c.Members {
+					pipe.HSet(ctx, ClusterMembersKey, member.URL, id)
+				}
+				return nil
+			})
+			return err
+		}, key)
+
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("Can't join duplicate cluster %s: %w", id, err)
+		}
+		return c, won, nil
+	}
+
+	return nil, false, fmt.Errorf("Can't join duplicate cluster %s: too much contention", id)
+}
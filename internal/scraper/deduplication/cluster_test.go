@@ -0,0 +1,120 @@
+package deduplication
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestElect(t *testing.T) {
+	rules := DefaultConfig().CanonicalBy
+
+	tests := []struct {
+		name       string
+		first      Member
+		challenger Member
+		want       bool
+	}{
+		{
+			name:       "rel=canonical names the challenger",
+			first:      Member{URL: "https://mirror.example/go/doc/", Authority: 50, Canonical: "https://go.dev/doc/"},
+			challenger: Member{URL: "https://go.dev/doc/", Canonical: "https://go.dev/doc/"},
+			want:       true,
+		},
+		{
+			name:       "both name themselves",
+			first:      Member{URL: "https://go.dev/doc/", Canonical: "https://go.dev/doc/"},
+			challenger: Member{URL: "https://mirror.example/go/doc/", Canonical: "https://mirror.example/go/doc/"},
+			want:       false,
+		},
+		{
+			name:       "only the challenger names itself",
+			first:      Member{URL: "https://go.dev/doc/"},
+			challenger: Member{URL: "https://mirror.example/go/doc/", Canonical: "https://mirror.example/go/doc/"},
+			want:       false,
+		},
+		{
+			name:       "noindex challenger",
+			first:      Member{URL: "https://mirror.example/go/doc/"},
+			challenger: Member{URL: "https://go.dev/doc/", Authority: 90, NoIndex: true},
+			want:       false,
+		},
+		{
+			name:       "noindex first page",
+			first:      Member{URL: "https://go.dev/doc/", Authority: 90, NoIndex: true},
+			challenger: Member{URL: "https://mirror.example/go/doc/"},
+			want:       true,
+		},
+		{
+			name:       "mirror points at the original",
+			first:      Member{URL: "https://go.dev/doc/"},
+			challenger: Member{URL: "https://m.example/doc/", Authority: 90, Canonical: "https://go.dev/doc/"},
+			want:       false,
+		},
+		{
+			name:       "more inbound links",
+			first:      Member{URL: "https://a.example/doc/", Authority: 2},
+			challenger: Member{URL: "https://docs.example/reference/doc/", Authority: 12},
+			want:       true,
+		},
+		{
+			name:       "shorter url",
+			first:      Member{URL: "https://example.com/docs/v2/intro/index.html"},
+			challenger: Member{URL: "https://example.com/docs/intro"},
+			want:       true,
+		},
+		{
+			name:       "tie keeps the first",
+			first:      Member{URL: "https://a.example/x"},
+			challenger: Member{URL: "https://b.example/x"},
+			want:       false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Cluster{Canonical: tt.first.URL, Members: []Member{tt.first}}
+			c.add(tt.challenger)
+
+			if got := c.Elect(rules, tt.challenger.URL); got != tt.want {
+				t.Errorf("Elect = %v, want %v", got, tt.want)
+			}
+
+			wantAlias := tt.challenger.URL
+			if tt.want {
+				wantAlias = tt.first.URL
+			}
+			if aliases := c.Aliases(); !slices.Equal(aliases, []string{wantAlias}) {
+				t.Errorf("aliases = %v, want [%s]", aliases, wantAlias)
+			}
+		})
+	}
+}
+
+func TestElectRuleOrder(t *testing.T) {
+	first := Member{URL: "https://a.example/x", Authority: 10}
+	challenger := Member{URL: "https://b.example/much/longer/path", Authority: 20}
+
+	c := &Cluster{Canonical: first.URL, Members: []Member{first, challenger}}
+	if c.Elect([]string{CANONICAL_SHORTEST, CANONICAL_AUTHORITY}, challenger.URL) {
+		t.Error("shortest url should decide before authority")
+	}
+	if !c.Elect([]string{CANONICAL_AUTHORITY, CANONICAL_SHORTEST}, challenger.URL) {
+		t.Error("authority should decide before shortest url")
+	}
+}
+
+func TestClusterMemberLimit(t *testing.T) {
+	c := &Cluster{}
+	for i := range maxClusterMembers + 3 {
+		c.add(Member{URL: string(rune('a'+i%26)) + string(rune('0'+i/26))})
+	}
+	if len(c.Members) != maxClusterMembers || c.Dropped != 3 {
+		t.Errorf("%d members, %d dropped", len(c.Members), c.Dropped)
+	}
+
+	// a known member is refreshed, not counted again
+	c.add(Member{URL: c.Members[0].URL, Authority: 7})
+	if c.Members[0].Authority != 7 || c.Dropped != 3 {
+		t.Errorf("refresh: %+v, %d dropped", c.Members[0], c.Dropped)
+	}
+}
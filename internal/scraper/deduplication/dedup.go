@@ -0,0 +1,74 @@
+package deduplication
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Deduplicator decides whether the cleaned text of a page near-duplicates a
+// page crawled before.
+type Deduplicator interface {
+	// Check returns the indexed page the text near-duplicates. When there is
+	// none, the text is indexed for doc and ok is false.
+	Check(ctx context.Context, text string, doc string) (match Match, ok bool, err error)
+	Stats(ctx context.Context) (IndexStats, error)
+}
+
+// New builds the deduplicator picked by cfg.
+func New(cfg Config, rdb *redis.Client) (Deduplicator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Algorithm == ALGORITHM_MINHASH {
+		return NewMinHashIndex(cfg.MinHash, cfg.ShingleSize, cfg.MinHash.MaxSignatures), nil
+	}
+
+	index, err := NewIndex(cfg, rdb)
+	if err != nil {
+		return nil, err
+	}
+	return NewSimhashDeduplicator(index, cfg.ShingleSize, cfg.MaxHammingDist), nil
+}
+
+// SimhashDeduplicator fingerprints the shingles of a page with SimHash and
+// looks for a fingerprint at most maxDist bits away.
+type SimhashDeduplicator struct {
+	index       Index
+	shingleSize int
+	maxDist     int
+}
+
+func NewSimhashDeduplicator(index Index, shingleSize int, maxDist int) *SimhashDeduplicator {
+	return &SimhashDeduplicator{index: index, shingleSize: shingleSize, maxDist: maxDist}
+}
+
+func (d *SimhashDeduplicator) Check(ctx context.Context, text string, doc string) (Match, bool, error) {
+	hash := SimHash(Shingles(Tokenize(text), d.shingleSize))
+	return d.index.FindNearDuplicate(ctx, hash, doc, d.maxDist)
+}
+
+func (d *SimhashDeduplicator) Stats(ctx context.Context) (IndexStats, error) {
+	return d.index.Stats(ctx)
+}
+
+var errNoSnapshots = errors.New("only the memory index keeps snapshots")
+
+func (d *SimhashDeduplicator) WriteSnapshot(w io.Writer) error {
+	mem, ok := d.index.(*SimhashIndex)
+	if !ok {
+		return errNoSnapshots
+	}
+	return mem.WriteSnapshot(w)
+}
+
+func (d *SimhashDeduplicator) ReadSnapshot(r io.Reader) error {
+	mem, ok := d.index.(*SimhashIndex)
+	if !ok {
+		return errNoSnapshots
+	}
+	return mem.ReadSnapshot(r)
+}
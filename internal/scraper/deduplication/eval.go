@@ -0,0 +1,162 @@
+package deduplication
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LabeledPair is two cleaned page texts and whether they are near-duplicates.
+type LabeledPair struct {
+	A, B      string
+	Duplicate bool
+	Line      int
+}
+
+// LoadPairs reads labeled page pairs, one per line:
+//
+//	duplicate go/doc.html mirror/doc.html
+//	distinct  a/README.md b/README.md
+//
+// Paths are relative to the pairs file. Pages ending in .md are cleaned as
+// markdown, everything else as HTML.
+func LoadPairs(file string) ([]LabeledPair, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read pairs: %w", err)
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(file)
+	pages := map[string]string{}
+	page := func(name string) (string, error) {
+		if text, ok := pages[name]; ok {
+			return text, nil
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return "", err
+		}
+
+		typ := SourceType(SourceHTML)
+		if strings.HasSuffix(strings.ToLower(name), ".md") {
+			typ = SourceMD
+		}
+		text, err := CleanData(string(data), typ)
+		if err != nil {
+			return "", fmt.Errorf("Can't clean %s: %w", name, err)
+		}
+
+		pages[name] = text
+		return text, nil
+	}
+
+	var pairs []LabeledPair
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: want \"duplicate|distinct <page> <page>\"", file, lineNo)
+		}
+
+		pair := LabeledPair{Line: lineNo}
+		switch fields[0] {
+		case "duplicate":
+			pair.Duplicate = true
+		case "distinct":
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown label %q", file, lineNo, fields[0])
+		}
+
+		if pair.A, err = page(fields[1]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, lineNo, err)
+		}
+		if pair.B, err = page(fields[2]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", file, lineNo, err)
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, scanner.Err()
+}
+
+// EvalResult counts the decisions of a deduplicator on labeled pairs.
+type EvalResult struct {
+	TruePositives  int
+	FalsePositives int
+	FalseNegatives int
+	TrueNegatives  int
+	Missed         []int // lines of duplicate pairs that were not detected
+	Wrong          []int // lines of distinct pairs reported as duplicates
+}
+
+// Precision is the share of reported duplicates that are duplicates, 1 when
+// nothing was reported.
+func (r EvalResult) Precision() float64 {
+	if n := r.TruePositives + r.FalsePositives; n > 0 {
+		return float64(r.TruePositives) / float64(n)
+	}
+	return 1
+}
+
+// Recall is the share of duplicates that were reported, 1 when there were
+// none.
+func (r EvalResult) Recall() float64 {
+	if n := r.TruePositives + r.FalseNegatives; n > 0 {
+		return float64(r.TruePositives) / float64(n)
+	}
+	return 1
+}
+
+func (r EvalResult) F1() float64 {
+	p, rc := r.Precision(), r.Recall()
+	if p+rc == 0 {
+		return 0
+	}
+	return 2 * p * rc / (p + rc)
+}
+
+// Evaluate runs every pair through a fresh deduplicator: the first page is
+// indexed and the second one checked against it.
+func Evaluate(ctx context.Context, newDedup func() Deduplicator, pairs []LabeledPair) (EvalResult, error) {
+	var res EvalResult
+
+	for _, pair := range pairs {
+		d := newDedup()
+		if _, _, err := d.Check(ctx, pair.A, "a"); err != nil {
+			return res, err
+		}
+		_, dup, err := d.Check(ctx, pair.B, "b")
+		if err != nil {
+			return res, err
+		}
+
+		switch {
+		case dup && pair.Duplicate:
+			res.TruePositives++
+		case dup:
+			res.FalsePositives++
+			res.Wrong = append(res.Wrong, pair.Line)
+		case pair.Duplicate:
+			res.FalseNegatives++
+			res.Missed = append(res.Missed, pair.Line)
+		default:
+			res.TrueNegatives++
+		}
+	}
+
+	return res, nil
+}
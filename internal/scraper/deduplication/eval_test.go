@@ -0,0 +1,66 @@
+package deduplication
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	dir := t.TempDir()
+	page := strings.Join(words("word", 300), " ")
+	files := map[string]string{
+		"a.html":  "<html><body><p>" + page + "</p></body></html>",
+		"b.html":  "<html><body><nav>home docs</nav><p>" + page + "</p></body></html>",
+		"c.md":    "# Other\n\n" + strings.Join(words("other", 300), " "),
+		"d.md":    "# Third\n\n" + strings.Join(words("third", 300), " "),
+		"pairs":   "# label a b\nduplicate a.html b.html\ndistinct a.html c.md\nduplicate c.md d.md\n",
+		"bad.txt": "maybe a.html b.html\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pairs, err := LoadPairs(filepath.Join(dir, "pairs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 3 {
+		t.Fatalf("loaded %d pairs, want 3", len(pairs))
+	}
+
+	newDedups := map[string]func() Deduplicator{
+		ALGORITHM_SIMHASH: func() Deduplicator {
+			return NewSimhashDeduplicator(NewSimIndex(), ShingleSize, MaxHammingDist)
+		},
+		ALGORITHM_MINHASH: func() Deduplicator {
+			return NewMinHashIndex(DefaultMinHashConfig(), ShingleSize, 0)
+		},
+	}
+	for name, newDedup := range newDedups {
+		res, err := Evaluate(context.Background(), newDedup, pairs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the last pair is mislabeled on purpose
+		if res.TruePositives != 1 || res.TrueNegatives != 1 || res.FalseNegatives != 1 || res.FalsePositives != 0 {
+			t.Errorf("%s: %+v", name, res)
+		}
+		if !slices.Equal(res.Missed, []int{4}) {
+			t.Errorf("%s: missed lines %v, want [4]", name, res.Missed)
+		}
+		if res.Precision() != 1 || res.Recall() != 0.5 {
+			t.Errorf("%s: precision %.2f recall %.2f", name, res.Precision(), res.Recall())
+		}
+	}
+
+	if _, err := LoadPairs(filepath.Join(dir, "bad.txt")); err == nil {
+		t.Error("unknown label should fail")
+	}
+}
@@ -0,0 +1,170 @@
+package deduplication
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	BACKEND_MEMORY = "memory"
+	BACKEND_REDIS  = "redis"
+)
+
+const (
+	ALGORITHM_SIMHASH = "simhash"
+	ALGORITHM_MINHASH = "minhash"
+)
+
+// Index answers whether a page is a near-duplicate of one already crawled.
+type Index interface {
+	// FindNearDuplicate returns the indexed fingerprint within maxDist bits
+	// of hash and the document it was indexed for. When there is none, hash
+	// is indexed for doc and ok is false.
+	FindNearDuplicate(ctx context.Context, hash uint64, doc string, maxDist int) (match Match, ok bool, err error)
+	Stats(ctx context.Context) (IndexStats, error)
+}
+
+// Match is an indexed fingerprint and the document, a URL, that first had
+// it. For MinHash the fingerprint is a digest of the signature.
+type Match struct {
+	Fingerprint uint64
+	Doc         string
+}
+
+type IndexStats struct {
+	Algorithm       string `json:"algorithm"`
+	Backend         string `json:"backend"`
+	Fingerprints    int64  `json:"fingerprints"`
+	MaxFingerprints int64  `json:"max_fingerprints"` // 0 when unbounded
+	Buckets         int64  `json:"buckets,omitempty"`
+	ApproxBytes     int64  `json:"approx_bytes"`
+	Evicted         int64  `json:"evicted"`
+}
+
+// Config picks the near-duplicate algorithm and where its index is kept.
+// SimHash compares 64-bit fingerprints by Hamming distance, MinHash
+// estimates the Jaccard similarity of the shingle sets. The memory index is
+// private to one process and survives restarts through snapshots, to a file
+// or to a MinIO object named per process and algorithm: {instance} in
+// SnapshotObject is replaced by Instance, or the hostname when that is
+// empty, and {algorithm} by Algorithm, also in SnapshotFile. The redis index is shared by every scraper and needs
+// no snapshots; it only holds SimHash fingerprints. CanonicalBy orders the
+// rules that pick which URL of a duplicate cluster gets indexed; the URL
+// crawled first wins a tie.
+type Config struct {
+	Algorithm        string        `json:"algorithm" yaml:"algorithm"`
+	ShingleSize      int           `json:"shingle_size" yaml:"shingle_size"`         // words per shingle
+	MaxHammingDist   int           `json:"max_hamming_dist" yaml:"max_hamming_dist"` // simhash bits that may differ
+	MinHash          MinHashConfig `json:"minhash" yaml:"minhash"`
+	Backend          string        `json:"backend" yaml:"backend"`
+	MaxFingerprints  int           `json:"max_fingerprints" yaml:"max_fingerprints"` // simhash, oldest are evicted beyond this, 0 keeps all
+	SnapshotFile     string        `json:"snapshot_file" yaml:"snapshot_file"`
+	SnapshotObject   string        `json:"snapshot_object" yaml:"snapshot_object"`
+	Instance         string        `json:"instance" yaml:"instance"` // names this process in snapshot_object
+	SnapshotInterval time.Duration `json:"snapshot_interval" yaml:"snapshot_interval"`
+	CanonicalBy      []string      `json:"canonical_by" yaml:"canonical_by"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Algorithm:        ALGORITHM_SIMHASH,
+		ShingleSize:      ShingleSize,
+		MaxHammingDist:   MaxHammingDist,
+		MinHash:          DefaultMinHashConfig(),
+		Backend:          BACKEND_MEMORY,
+		MaxFingerprints:  5_000_000,
+		SnapshotObject:   "dedup/{instance}/{algorithm}.snapshot",
+		SnapshotInterval: 5 * time.Minute,
+		CanonicalBy:      []string{CANONICAL_REL, CANONICAL_AUTHORITY, CANONICAL_SHORTEST},
+	}
+}
+
+func (c Config) Validate() error {
+	switch c.Algorithm {
+	case ALGORITHM_SIMHASH:
+		if c.MaxHammingDist < 0 || c.MaxHammingDist > MaxBucketDist {
+			return fmt.Errorf("max_hamming_dist must be in [0, %d], the block tables miss matches further away, got %d", MaxBucketDist, c.MaxHammingDist)
+		}
+	case ALGORITHM_MINHASH:
+		if err := c.MinHash.Validate(); err != nil {
+			return fmt.Errorf("minhash: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown algorithm %q", c.Algorithm)
+	}
+
+	if c.ShingleSize <= 0 {
+		return fmt.Errorf("shingle_size must be positive, got %d", c.ShingleSize)
+	}
+
+	switch c.Backend {
+	case BACKEND_MEMORY:
+	case BACKEND_REDIS:
+		if c.Algorithm != ALGORITHM_SIMHASH {
+			return fmt.Errorf("the redis backend only supports simhash")
+		}
+	default:
+		return fmt.Errorf("unknown backend %q", c.Backend)
+	}
+
+	if c.MaxFingerprints < 0 {
+		return fmt.Errorf("max_fingerprints must not be negative")
+	}
+	if c.SnapshotFile != "" && c.SnapshotObject != "" {
+		return fmt.Errorf("set snapshot_file or snapshot_object, not both")
+	}
+	if c.Snapshots() && c.SnapshotInterval <= 0 {
+		return fmt.Errorf("snapshot_interval must be positive")
+	}
+	for _, rule := range c.CanonicalBy {
+		if !isCanonicalRule(rule) {
+			return fmt.Errorf("unknown canonical_by rule %q", rule)
+		}
+	}
+
+	return nil
+}
+
+// Snapshots reports whether the memory index is persisted.
+func (c Config) Snapshots() bool {
+	return c.Backend == BACKEND_MEMORY && (c.SnapshotFile != "" || c.SnapshotObject != "")
+}
+
+// ObjectName is SnapshotObject with {algorithm} and {instance} filled in.
+func (c Config) ObjectName() (string, error) {
+	name := strings.ReplaceAll(c.SnapshotObject, "{algorithm}", c.Algorithm)
+	if !strings.Contains(name, "{instance}") {
+		return name, nil
+	}
+
+	instance := c.Instance
+	if instance == "" {
+		host, err := os.Hostname()
+		if err != nil || host == "" {
+			return "", fmt.Errorf("no instance to name the snapshot object, set dedup.instance: %v", err)
+		}
+		instance = host
+	}
+	return strings.ReplaceAll(name, "{instance}", instance), nil
+}
+
+// FileName is SnapshotFile with {algorithm} filled in.
+func (c Config) FileName() string {
+	return strings.ReplaceAll(c.SnapshotFile, "{algorithm}", c.Algorithm)
+}
+
+func NewIndex(cfg Config, rdb *redis.Client) (Index, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Backend == BACKEND_REDIS {
+		return NewRedisSimIndex(rdb, cfg.MaxFingerprints), nil
+	}
+	return NewBoundedSimIndex(cfg.MaxFingerprints), nil
+}
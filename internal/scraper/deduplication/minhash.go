@@ -0,0 +1,287 @@
+package deduplication
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// rough heap cost of a MinHash entry besides its signature and document: the
+// entry map slot, the eviction order and one bucket slot per band
+const (
+	bytesPerMinHashEntry = 64
+	bytesPerBandEntry    = 8
+
+	// signatures past this many bytes are refused at startup, a bounded
+	// index can't be bigger than it
+	maxMinHashBytes = 2 << 30
+)
+
+// MinHashConfig sets the LSH banding. A signature has Bands*Rows values and
+// two pages become candidates when all Rows values of any band agree, which
+// happens with probability 1-(1-s^Rows)^Bands at Jaccard similarity s. The
+// curve is steepest near (1/Bands)^(1/Rows). Candidates are kept when their
+// estimated similarity reaches Threshold. A signature costs far more than a
+// SimHash fingerprint, so the index has its own bound, MaxSignatures.
+type MinHashConfig struct {
+	Bands         int     `json:"bands" yaml:"bands"`
+	Rows          int     `json:"rows" yaml:"rows"`
+	Threshold     float64 `json:"threshold" yaml:"threshold"`
+	MaxSignatures int     `json:"max_signatures" yaml:"max_signatures"` // oldest are evicted beyond this, 0 keeps all
+}
+
+func DefaultMinHashConfig() MinHashConfig {
+	return MinHashConfig{
+		Bands:         16,
+		Rows:          8,
+		Threshold:     0.8,
+		MaxSignatures: 500_000,
+	}
+}
+
+// EntryBytes is the rough heap cost of one signature without its document.
+func (c MinHashConfig) EntryBytes() int {
+	return bytesPerMinHashEntry + 4*c.Bands*c.Rows + bytesPerBandEntry*c.Bands
+}
+
+func (c MinHashConfig) Validate() error {
+	if c.Bands <= 0 || c.Rows <= 0 {
+		return fmt.Errorf("bands and rows must be positive, got %d and %d", c.Bands, c.Rows)
+	}
+	if c.Bands*c.Rows > 1024 {
+		return fmt.Errorf("bands*rows must be at most 1024, got %d", c.Bands*c.Rows)
+	}
+	if c.Threshold < 0 || c.Threshold > 1 {
+		return fmt.Errorf("threshold must be in [0, 1], got %v", c.Threshold)
+	}
+	if c.MaxSignatures < 0 {
+		return fmt.Errorf("max_signatures must not be negative")
+	}
+	if c.MaxSignatures > 0 && c.MaxSignatures*c.EntryBytes() > maxMinHashBytes {
+		return fmt.Errorf("max_signatures %d of %d bands by %d rows need about %d MB, more than %d MB",
+			c.MaxSignatures, c.Bands, c.Rows, c.MaxSignatures*c.EntryBytes()>>20, maxMinHashBytes>>20)
+	}
+	return nil
+}
+
+type minHashEntry struct {
+	sig []uint32
+	doc string
+}
+
+// MinHashIndex keeps MinHash signatures in memory with one LSH table per
+// band. Like the SimHash index it evicts the oldest signatures beyond a size
+// bound.
+type MinHashIndex struct {
+	mu          sync.RWMutex
+	cfg         MinHashConfig
+	shingleSize int
+	seeds       []uint64
+
+	tables  []map[uint64][]uint64 // per band, band hash -> signature ids
+	entries map[uint64]minHashEntry
+
+	maxSize  int
+	order    []uint64 // insertion order, oldest from head
+	head     int
+	evicted  int64
+	docBytes int
+}
+
+func NewMinHashIndex(cfg MinHashConfig, shingleSize int, maxSize int) *MinHashIndex {
+	idx := &MinHashIndex{
+		cfg:         cfg,
+		shingleSize: shingleSize,
+		seeds:       make([]uint64, cfg.Bands*cfg.Rows),
+		maxSize:     maxSize,
+	}
+
+	// fixed seeds, signatures must stay comparable across restarts
+	state := uint64(0x5EED_0F_D0C5)
+	for i := range idx.seeds {
+		state += 0x9E37_79B9_7F4A_7C15
+		idx.seeds[i] = mix64(state)
+	}
+
+	idx.reset()
+	return idx
+}
+
+// mix64 is the splitmix64 finalizer.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xBF58_476D_1CE4_E5B9
+	x ^= x >> 27
+	x *= 0x94D0_49BB_1331_11EB
+	x ^= x >> 31
+	return x
+}
+
+// Signature is the minimum of every hash function over the shingles.
+func (idx *MinHashIndex) Signature(shingles []string) []uint32 {
+	sig := make([]uint32, len(idx.seeds))
+	for i := range sig {
+		sig[i] = math.MaxUint32
+	}
+
+	for _, sh := range shingles {
+		x := xxhash.Sum64String(sh)
+		for i, seed := range idx.seeds {
+			if h := uint32(mix64(x^seed) >> 32); h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+
+	return sig
+}
+
+// Similarity estimates the Jaccard similarity of two signatures.
+func Similarity(a, b []uint32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(len(a))
+}
+
+func hashValues(values []uint32) uint64 {
+	buf := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(buf[4*i:], v)
+	}
+	return xxhash.Sum64(buf)
+}
+
+func (idx *MinHashIndex) bandKeys(sig []uint32) []uint64 {
+	keys := make([]uint64, idx.cfg.Bands)
+	for b := range keys {
+		keys[b] = hashValues(sig[b*idx.cfg.Rows : (b+1)*idx.cfg.Rows])
+	}
+	return keys
+}
+
+func (idx *MinHashIndex) Check(ctx context.Context, text string, doc string) (Match, bool, error) {
+	sig := idx.Signature(Shingles(Tokenize(text), idx.shingleSize))
+	keys := idx.bandKeys(sig)
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	best, bestSim := uint64(0), -1.0
+	seen := map[uint64]bool{}
+
+	for b, key := range keys {
+		for _, id := range idx.tables[b][key] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			if sim := Similarity(sig, idx.entries[id].sig); sim >= idx.cfg.Threshold && sim > bestSim {
+				best, bestSim = id, sim
+			}
+		}
+	}
+
+	if bestSim >= 0 {
+		return Match{Fingerprint: best, Doc: idx.entries[best].doc}, true, nil
+	}
+
+	idx.insert(sig, doc, keys)
+	return Match{}, false, nil
+}
+
+func (idx *MinHashIndex) insert(sig []uint32, doc string, keys []uint64) {
+	id := hashValues(sig)
+	if _, exists := idx.entries[id]; exists {
+		return
+	}
+
+	idx.entries[id] = minHashEntry{sig: sig, doc: doc}
+	idx.docBytes += len(doc)
+	for b, key := range keys {
+		idx.tables[b][key] = append(idx.tables[b][key], id)
+	}
+	idx.order = append(idx.order, id)
+
+	for idx.maxSize > 0 && idx.len() > idx.maxSize {
+		idx.evictOldest()
+	}
+}
+
+func (idx *MinHashIndex) len() int {
+	return len(idx.order) - idx.head
+}
+
+func (idx *MinHashIndex) evictOldest() {
+	id := idx.order[idx.head]
+	idx.head++
+	idx.evicted++
+
+	// drop the consumed prefix once it outgrows the live part
+	if idx.head > len(idx.order)/2 {
+		idx.order = slices.Clone(idx.order[idx.head:])
+		idx.head = 0
+	}
+
+	entry := idx.entries[id]
+	idx.docBytes -= len(entry.doc)
+	delete(idx.entries, id)
+
+	for b, key := range idx.bandKeys(entry.sig) {
+		bucket := idx.tables[b][key]
+		if j := slices.Index(bucket, id); j >= 0 {
+			bucket = slices.Delete(bucket, j, j+1)
+		}
+		if len(bucket) == 0 {
+			delete(idx.tables[b], key)
+		} else {
+			idx.tables[b][key] = bucket
+		}
+	}
+}
+
+func (idx *MinHashIndex) Stats(ctx context.Context) (IndexStats, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	buckets := 0
+	for _, table := range idx.tables {
+		buckets += len(table)
+	}
+
+	n := idx.len()
+	perEntry := idx.cfg.EntryBytes()
+	return IndexStats{
+		Algorithm:       ALGORITHM_MINHASH,
+		Backend:         BACKEND_MEMORY,
+		Fingerprints:    int64(n),
+		MaxFingerprints: int64(idx.maxSize),
+		Buckets:         int64(buckets),
+		ApproxBytes:     int64(n*perEntry + buckets*bytesPerBucket + idx.docBytes),
+		Evicted:         idx.evicted,
+	}, nil
+}
+
+func (idx *MinHashIndex) reset() {
+	idx.tables = make([]map[uint64][]uint64, idx.cfg.Bands)
+	for b := range idx.tables {
+		idx.tables[b] = make(map[uint64][]uint64)
+	}
+	idx.entries = make(map[uint64]minHashEntry)
+	idx.order = nil
+	idx.head = 0
+	idx.docBytes = 0
+}
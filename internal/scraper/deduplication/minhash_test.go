@@ -0,0 +1,121 @@
+package deduplication
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func words(prefix string, n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return out
+}
+
+func TestMinHashNearDuplicate(t *testing.T) {
+	ctx := context.Background()
+	idx := NewMinHashIndex(DefaultMinHashConfig(), ShingleSize, 0)
+
+	page := words("word", 400)
+	if _, dup, _ := idx.Check(ctx, strings.Join(page, " "), "https://go.dev/doc/"); dup {
+		t.Fatal("first page reported as duplicate")
+	}
+
+	// a few words changed keeps most shingles
+	edited := append([]string(nil), page...)
+	edited[100], edited[250] = "cookie", "banner"
+	match, dup, _ := idx.Check(ctx, strings.Join(edited, " "), "https://mirror.example/doc/")
+	if !dup || match.Doc != "https://go.dev/doc/" {
+		t.Errorf("edited page: dup=%v match=%+v", dup, match)
+	}
+
+	if _, dup, _ := idx.Check(ctx, strings.Join(words("other", 400), " "), "https://example.com/"); dup {
+		t.Error("unrelated page reported as duplicate")
+	}
+
+	if stats, _ := idx.Stats(ctx); stats.Fingerprints != 2 || stats.Algorithm != ALGORITHM_MINHASH {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	idx := NewMinHashIndex(MinHashConfig{Bands: 32, Rows: 8, Threshold: 0.5}, 1, 0)
+
+	a := words("w", 100)
+	b := append(words("w", 50), words("x", 50)...) // jaccard 50/150
+
+	got := Similarity(idx.Signature(a), idx.Signature(b))
+	if got < 0.2 || got > 0.47 {
+		t.Errorf("similarity = %.3f, want about 0.33", got)
+	}
+	if got := Similarity(idx.Signature(a), idx.Signature(a)); got != 1 {
+		t.Errorf("self similarity = %.3f", got)
+	}
+}
+
+func TestMinHashBoundAndSnapshot(t *testing.T) {
+	ctx := context.Background()
+	cfg := MinHashConfig{Bands: 8, Rows: 4, Threshold: 0.9}
+	idx := NewMinHashIndex(cfg, ShingleSize, 2)
+
+	for i := range 3 {
+		idx.Check(ctx, strings.Join(words(fmt.Sprintf("p%d-", i), 50), " "), fmt.Sprintf("https://example.com/%d", i))
+	}
+	if stats, _ := idx.Stats(ctx); stats.Fingerprints != 2 || stats.Evicted != 1 {
+		t.Fatalf("stats = %+v, want 2 fingerprints and 1 evicted", stats)
+	}
+
+	var buf bytes.Buffer
+	if err := idx.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewMinHashIndex(cfg, ShingleSize, 0)
+	if err := restored.ReadSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	match, dup, _ := restored.Check(ctx, strings.Join(words("p2-", 50), " "), "")
+	if !dup || match.Doc != "https://example.com/2" {
+		t.Errorf("after restore: dup=%v match=%+v", dup, match)
+	}
+
+	other := NewMinHashIndex(MinHashConfig{Bands: 4, Rows: 8, Threshold: 0.9}, ShingleSize, 0)
+	if err := other.ReadSnapshot(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("snapshot with other banding should not load")
+	}
+	if err := other.ReadSnapshot(bytes.NewReader([]byte("SIMH"))); err == nil {
+		t.Error("simhash snapshot should not load")
+	}
+}
+
+func TestMinHashBound(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Algorithm = ALGORITHM_MINHASH
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the simhash bound doesn't size the minhash index
+	dedup, err := New(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, _ := dedup.Stats(context.Background())
+	if stats.MaxFingerprints != int64(cfg.MinHash.MaxSignatures) {
+		t.Errorf("bound = %d, want max_signatures %d", stats.MaxFingerprints, cfg.MinHash.MaxSignatures)
+	}
+
+	cfg.MinHash.MaxSignatures = 5_000_000
+	if err := cfg.Validate(); err == nil {
+		t.Error("5M signatures of 16x8 should be refused")
+	}
+
+	cfg.MinHash.MaxSignatures = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("an unbounded index is allowed: %v", err)
+	}
+}
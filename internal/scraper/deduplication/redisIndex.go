@@ -0,0 +1,107 @@
+package deduplication
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scripts"
+	"github.com/redis/go-redis/v9"
+)
+
+// Every key of the index shares the {idx} hash tag, so they all hash to one
+// Redis Cluster slot. The script reaches the bucket sets of the fingerprints
+// it evicts, which can't be declared up front, through that slot.
+const (
+	SimhashOrderKey  = "dedup:simhash:{idx}:order"
+	SimhashMetaKey   = "dedup:simhash:{idx}:meta"
+	SimhashDocsKey   = "dedup:simhash:{idx}:docs"
+	SimhashBucketKey = "dedup:simhash:{idx}:"
+
+	// rough Redis cost of a fingerprint in one bucket set
+	redisBytesPerBucketEntry = 32
+)
+
+// RedisSimIndex keeps the four bucket tables in Redis so every scraper
+// process sees the same fingerprints. Check and insert run in one script,
+// so two processes fetching copies of a page at once can't both keep it.
+type RedisSimIndex struct {
+	rdb     *redis.Client
+	maxSize int
+}
+
+func NewRedisSimIndex(rdb *redis.Client, maxSize int) *RedisSimIndex {
+	return &RedisSimIndex{rdb: rdb, maxSize: maxSize}
+}
+
+func fingerprintHex(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// bucketKeys are the four bucket sets fp goes in, one per 16-bit block.
+func bucketKeys(fp string) []string {
+	keys := make([]string, 4)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s%d:%s", SimhashBucketKey, i+1, fp[i*4:(i+1)*4])
+	}
+	return keys
+}
+
+func (idx *RedisSimIndex) check(ctx context.Context, hash uint64, doc string, maxDist int) (Match, bool, error) {
+	fp := fingerprintHex(hash)
+	keys := append([]string{SimhashOrderKey, SimhashMetaKey, SimhashDocsKey}, bucketKeys(fp)...)
+	res, err := scripts.SimhashCheckScript.Run(
+		ctx,
+		idx.rdb,
+		keys,
+		fp,
+		maxDist,
+		idx.maxSize,
+		SimhashBucketKey,
+		doc,
+	).StringSlice()
+	if err != nil {
+		return Match{}, false, fmt.Errorf("simhash index: %w", err)
+	}
+	if len(res) < 2 {
+		return Match{}, false, nil
+	}
+
+	match, err := strconv.ParseUint(res[0], 16, 64)
+	if err != nil {
+		return Match{}, false, fmt.Errorf("simhash index: bad fingerprint %q", res[0])
+	}
+	return Match{Fingerprint: match, Doc: res[1]}, true, nil
+}
+
+func (idx *RedisSimIndex) Add(ctx context.Context, hash uint64, doc string) error {
+	_, _, err := idx.check(ctx, hash, doc, -1)
+	return err
+}
+
+func (idx *RedisSimIndex) FindNearDuplicate(ctx context.Context, hash uint64, doc string, maxDist int) (Match, bool, error) {
+	return idx.check(ctx, hash, doc, maxDist)
+}
+
+func (idx *RedisSimIndex) Stats(ctx context.Context) (IndexStats, error) {
+	pipe := idx.rdb.Pipeline()
+	card := pipe.ZCard(ctx, SimhashOrderKey)
+	evicted := pipe.HGet(ctx, SimhashMetaKey, "evicted")
+	usage := pipe.MemoryUsage(ctx, SimhashOrderKey)
+	docsUsage := pipe.MemoryUsage(ctx, SimhashDocsKey)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return IndexStats{}, err
+	}
+
+	n := card.Val()
+	stats := IndexStats{
+		Algorithm:       ALGORITHM_SIMHASH,
+		Backend:         BACKEND_REDIS,
+		Fingerprints:    n,
+		MaxFingerprints: int64(idx.maxSize),
+		ApproxBytes:     usage.Val() + docsUsage.Val() + 4*n*redisBytesPerBucketEntry,
+	}
+	stats.Evicted, _ = strconv.ParseInt(evicted.Val(), 10, 64)
+
+	return stats, nil
+}
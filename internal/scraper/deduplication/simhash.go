@@ -1,6 +1,8 @@
 package deduplication
 
 import (
+	"context"
+	"slices"
 	"strings"
 	"sync"
 
@@ -10,14 +12,43 @@ import (
 const ShingleSize = 3
 const MaxHammingDist = 3
 
+// MaxBucketDist is the largest distance the four 16-bit block tables find
+// every match for: 4 bits can differ once in each block.
+const MaxBucketDist = 3
+
+// rough heap cost of the in-memory index: a fingerprint sits in four buckets
+// and the eviction order, a bucket is a map entry plus a slice header, and
+// the document is a map entry plus a string header besides its bytes
+const (
+	bytesPerFingerprint = 5 * 8
+	bytesPerBucket      = 48
+	bytesPerDoc         = 32
+)
+
+// SimhashIndex keeps fingerprints in memory in four tables keyed by one
+// 16-bit block each. Two fingerprints within 3 bits share at least one
+// block, so only that bucket needs comparing. With a size bound the oldest
+// fingerprints are evicted first.
 type SimhashIndex struct {
 	Buckets [4]map[uint16][]uint64
+	docs    map[uint64]string // document each fingerprint was indexed for
 	mu      sync.RWMutex
+
+	maxSize  int
+	order    []uint64 // insertion order, oldest from head
+	head     int
+	evicted  int64
+	docBytes int
 }
 
 func NewSimIndex() *SimhashIndex {
+	return NewBoundedSimIndex(0)
+}
+
+// NewBoundedSimIndex keeps at most maxSize fingerprints, 0 for no bound.
+func NewBoundedSimIndex(maxSize int) *SimhashIndex {
 
-	idx := &SimhashIndex{}
+	idx := &SimhashIndex{maxSize: maxSize, docs: make(map[uint64]string)}
 
 	for i := 0; i < 4; i++ {
 		idx.Buckets[i] = make(map[uint16][]uint64)
@@ -31,45 +62,130 @@ func (idx *SimhashIndex) Add(hash uint64) {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
+	idx.insert(hash, "")
+}
+
+func (idx *SimhashIndex) insert(hash uint64, doc string) {
+	if _, exists := idx.docs[hash]; exists {
+		return
+	}
+	idx.docs[hash] = doc
+	idx.docBytes += len(doc)
+
 	parts := partitionHash(hash)
 
 	for i := 0; i < 4; i++ {
 		key := parts[i]
 		idx.Buckets[i][key] = append(idx.Buckets[i][key], hash)
 	}
+
+	idx.order = append(idx.order, hash)
+
+	for idx.maxSize > 0 && idx.len() > idx.maxSize {
+		idx.evictOldest()
+	}
+}
+
+func (idx *SimhashIndex) len() int {
+	return len(idx.order) - idx.head
+}
+
+func (idx *SimhashIndex) evictOldest() {
+	hash := idx.order[idx.head]
+	idx.head++
+	idx.evicted++
+
+	// drop the consumed prefix once it outgrows the live part
+	if idx.head > len(idx.order)/2 {
+		idx.order = slices.Clone(idx.order[idx.head:])
+		idx.head = 0
+	}
+
+	idx.docBytes -= len(idx.docs[hash])
+	delete(idx.docs, hash)
+
+	parts := partitionHash(hash)
+	for i := range 4 {
+		key := parts[i]
+		bucket := idx.Buckets[i][key]
+		if j := slices.Index(bucket, hash); j >= 0 {
+			bucket = slices.Delete(bucket, j, j+1)
+		}
+		if len(bucket) == 0 {
+			delete(idx.Buckets[i], key)
+		} else {
+			idx.Buckets[i][key] = bucket
+		}
+	}
 }
 
-func (idx *SimhashIndex) IsNearDuplicate(hash uint64, maxDist int) bool {
+// FindNearDuplicate returns the indexed fingerprint within maxDist bits of
+// hash, or indexes hash for doc when there is none.
+func (idx *SimhashIndex) FindNearDuplicate(ctx context.Context, hash uint64, doc string, maxDist int) (Match, bool, error) {
 
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
 	parts := partitionHash(hash)
 
-	candidates := make(map[uint64]struct{})
-
 	for i := range 4 {
 
 		key := parts[i]
 
 		for _, h := range idx.Buckets[i][key] {
-			candidates[h] = struct{}{}
-		}
-	}
-
-	for c := range candidates {
-		if hammingDistance(hash, c) <= maxDist {
-			return true
+			if hammingDistance(hash, h) <= maxDist {
+				return Match{Fingerprint: h, Doc: idx.docs[h]}, true, nil
+			}
 		}
 	}
 
 	// insert hash since it is not duplicate
+	idx.insert(hash, doc)
+
+	return Match{}, false, nil
+}
+
+func (idx *SimhashIndex) Stats(ctx context.Context) (IndexStats, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	buckets := 0
 	for i := range 4 {
-		key := parts[i]
-		idx.Buckets[i][key] = append(idx.Buckets[i][key], hash)
+		buckets += len(idx.Buckets[i])
 	}
 
-	return false
+	n := idx.len()
+	return IndexStats{
+		Algorithm:       ALGORITHM_SIMHASH,
+		Backend:         BACKEND_MEMORY,
+		Fingerprints:    int64(n),
+		MaxFingerprints: int64(idx.maxSize),
+		Buckets:         int64(buckets),
+		ApproxBytes:     int64(n*(bytesPerFingerprint+bytesPerDoc) + buckets*bytesPerBucket + idx.docBytes),
+		Evicted:         idx.evicted,
+	}, nil
+}
+
+// entries lists the indexed fingerprints with their documents, oldest first.
+func (idx *SimhashIndex) entries() []Match {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	out := make([]Match, 0, idx.len())
+	for _, fp := range idx.order[idx.head:] {
+		out = append(out, Match{Fingerprint: fp, Doc: idx.docs[fp]})
+	}
+	return out
+}
+
+func (idx *SimhashIndex) reset() {
+	for i := range 4 {
+		idx.Buckets[i] = make(map[uint16][]uint64)
+	}
+	idx.docs = make(map[uint64]string)
+	idx.docBytes = 0
+	idx.order = nil
+	idx.head = 0
 }
 
 func Tokenize(data string) []string {
@@ -77,7 +193,7 @@ func Tokenize(data string) []string {
 }
 
 func Shingles(tokens []string, n int) []string {
-	if len(tokens) < 2 {
+	if len(tokens) < max(n, 2) {
 		return nil
 	}
 
@@ -1,6 +1,7 @@
 package deduplication
 
 import (
+	"context"
 	"testing"
 )
 
@@ -44,7 +45,7 @@ func BenchmarkSimhashQuery(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		index.IsNearDuplicate(hash, 3)
+		index.FindNearDuplicate(context.Background(), hash, "", 3)
 	}
 }
 
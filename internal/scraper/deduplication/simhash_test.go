@@ -0,0 +1,200 @@
+package deduplication
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNearDuplicate(t *testing.T) {
+	ctx := context.Background()
+	idx := NewSimIndex()
+
+	if _, dup, _ := idx.FindNearDuplicate(ctx, 0xF0F0_0000_0000_0000, "https://go.dev/doc/", MaxHammingDist); dup {
+		t.Fatal("first fingerprint reported as duplicate")
+	}
+	match, dup, _ := idx.FindNearDuplicate(ctx, 0xF0F0_0000_0000_0007, "https://mirror.example/doc/", MaxHammingDist)
+	if !dup {
+		t.Error("3 bits away should be a duplicate")
+	}
+	if match.Fingerprint != 0xF0F0_0000_0000_0000 || match.Doc != "https://go.dev/doc/" {
+		t.Errorf("match = %+v, want the first page", match)
+	}
+	if _, dup, _ := idx.FindNearDuplicate(ctx, 0xF0F0_0000_0000_000F, "", MaxHammingDist); dup {
+		t.Error("4 bits away should not be a duplicate")
+	}
+}
+
+func TestSizeBound(t *testing.T) {
+	ctx := context.Background()
+	idx := NewBoundedSimIndex(3)
+
+	for _, h := range []uint64{1 << 60, 1 << 40, 1 << 20, 0xFFFF} {
+		idx.Add(h)
+	}
+
+	stats, _ := idx.Stats(ctx)
+	if stats.Fingerprints != 3 || stats.Evicted != 1 {
+		t.Fatalf("stats = %+v, want 3 fingerprints and 1 evicted", stats)
+	}
+	if stats.ApproxBytes <= 0 {
+		t.Errorf("approx bytes = %d", stats.ApproxBytes)
+	}
+
+	// the oldest one is gone from every bucket
+	if _, dup, _ := idx.FindNearDuplicate(ctx, 1<<60, "", 0); dup {
+		t.Error("evicted fingerprint still matches")
+	}
+	if _, dup, _ := idx.FindNearDuplicate(ctx, 0xFFFF, "", 0); !dup {
+		t.Error("newest fingerprint lost")
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	step := uint64(0x9E37_79B9_7F4A_7C15)
+	idx := NewSimIndex()
+	for i := range uint64(100) {
+		idx.Add(i * step)
+	}
+
+	var buf bytes.Buffer
+	if err := idx.WriteSnapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// a smaller bound keeps the newest fingerprints
+	restored := NewBoundedSimIndex(10)
+	if err := restored.ReadSnapshot(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if _, dup, _ := restored.FindNearDuplicate(ctx, 99*step, "", 0); !dup {
+		t.Error("newest fingerprint missing after restore")
+	}
+	if stats, _ := restored.Stats(ctx); stats.Fingerprints != 10 {
+		t.Errorf("restored %d fingerprints, want 10", stats.Fingerprints)
+	}
+
+	if err := restored.ReadSnapshot(bytes.NewReader([]byte("nope"))); err == nil {
+		t.Error("garbage should not load")
+	}
+}
+
+func TestSnapshotFile(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig()
+	cfg.SnapshotObject = ""
+	cfg.SnapshotFile = filepath.Join(t.TempDir(), "simhash.snapshot")
+
+	idx := NewSimIndex()
+	snap, err := NewSnapshotter(idx, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing saved yet, start empty
+	if err := snap.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	idx.FindNearDuplicate(ctx, 42, "https://go.dev/", 0)
+	if err := snap.Save(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewSimIndex()
+	snap, err = NewSnapshotter(restored, cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := snap.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if match, dup, _ := restored.FindNearDuplicate(ctx, 42, "", 0); !dup || match.Doc != "https://go.dev/" {
+		t.Errorf("fingerprint lost across restart: %+v", match)
+	}
+}
+
+func TestObjectName(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Instance = "scraper-2"
+	if name, err := cfg.ObjectName(); err != nil || name != "dedup/scraper-2/simhash.snapshot" {
+		t.Errorf("object name = %q, %v", name, err)
+	}
+
+	// without an instance every process on its own host gets its own object
+	cfg.Instance = ""
+	host, _ := os.Hostname()
+	if name, _ := cfg.ObjectName(); name != "dedup/"+host+"/simhash.snapshot" {
+		t.Errorf("object name = %q, want the hostname in it", name)
+	}
+
+	// switching algorithm must not load or overwrite the other's snapshot
+	cfg.Algorithm = ALGORITHM_MINHASH
+	cfg.Instance = "scraper-2"
+	if name, _ := cfg.ObjectName(); name != "dedup/scraper-2/minhash.snapshot" {
+		t.Errorf("minhash object name = %q", name)
+	}
+	cfg.SnapshotFile = "{algorithm}.snapshot"
+	if name := cfg.FileName(); name != "minhash.snapshot" {
+		t.Errorf("minhash file name = %q", name)
+	}
+
+	cfg.SnapshotObject = "dedup/shared.snapshot"
+	if name, _ := cfg.ObjectName(); name != cfg.SnapshotObject {
+		t.Errorf("a name without {instance} should be kept, got %q", name)
+	}
+}
+
+func TestRedisKeysShareSlot(t *testing.T) {
+	keys := append([]string{SimhashOrderKey, SimhashMetaKey, SimhashDocsKey}, bucketKeys(fingerprintHex(0x0123456789abcdef))...)
+	for _, key := range keys {
+		if !strings.Contains(key, "{idx}") {
+			t.Errorf("%s is outside the {idx} hash tag", key)
+		}
+	}
+	if keys[3] != SimhashBucketKey+"1:0123" || keys[6] != SimhashBucketKey+"4:cdef" {
+		t.Errorf("bucket keys = %v", keys[3:])
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.SnapshotFile = "simhash.snapshot"
+	if err := cfg.Validate(); err == nil {
+		t.Error("file and object together should fail")
+	}
+
+	cfg = DefaultConfig()
+	cfg.MaxHammingDist = 4
+	if err := cfg.Validate(); err == nil {
+		t.Error("a distance the block tables can't find should fail")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Backend = "disk"
+	if err := cfg.Validate(); err == nil {
+		t.Error("unknown backend should fail")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Algorithm = ALGORITHM_MINHASH
+	cfg.Backend = BACKEND_REDIS
+	if err := cfg.Validate(); err == nil {
+		t.Error("minhash in redis should fail")
+	}
+
+	cfg = DefaultConfig()
+	cfg.Algorithm = ALGORITHM_MINHASH
+	cfg.MinHash.Rows = 0
+	if err := cfg.Validate(); err == nil {
+		t.Error("zero rows should fail")
+	}
+
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Error(err)
+	}
+}
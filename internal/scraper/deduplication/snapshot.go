@@ -0,0 +1,299 @@
+package deduplication
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/storage"
+)
+
+// snapshot layout: magic, version, count, then the fingerprints oldest first
+// so eviction order survives a restart, all little-endian. Version 2 follows
+// each fingerprint with the length and bytes of its document, version 1
+// snapshots load with no documents.
+const (
+	snapshotMagic   = "SIMH"
+	snapshotVersion = 2
+
+	maxSnapshotDoc = 64 << 10
+)
+
+// WriteSnapshot writes the indexed fingerprints to w.
+func (idx *SimhashIndex) WriteSnapshot(w io.Writer) error {
+	entries := idx.entries()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(snapshotMagic)
+	binary.Write(bw, binary.LittleEndian, uint32(snapshotVersion))
+	binary.Write(bw, binary.LittleEndian, uint64(len(entries)))
+	for _, e := range entries {
+		binary.Write(bw, binary.LittleEndian, e.Fingerprint)
+		binary.Write(bw, binary.LittleEndian, uint32(len(e.Doc)))
+		if _, err := bw.WriteString(e.Doc); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadSnapshot replaces the index with the fingerprints in r. The size
+// bound still applies, so a smaller bound keeps the newest ones.
+func (idx *SimhashIndex) ReadSnapshot(r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(snapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != snapshotMagic {
+		return errors.New("not a simhash snapshot")
+	}
+
+	var version uint32
+	var count uint64
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return err
+	}
+	if version < 1 || version > snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	if err := binary.Read(br, binary.LittleEndian, &count); err != nil {
+		return err
+	}
+
+	entries := make([]Match, 0, min(count, 1<<24))
+	for range count {
+		var e Match
+		if err := binary.Read(br, binary.LittleEndian, &e.Fingerprint); err != nil {
+			return fmt.Errorf("truncated snapshot: %w", err)
+		}
+		if version >= 2 {
+			var n uint32
+			if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+				return fmt.Errorf("truncated snapshot: %w", err)
+			}
+			if n > maxSnapshotDoc {
+				return fmt.Errorf("corrupt snapshot: document of %d bytes", n)
+			}
+			doc := make([]byte, n)
+			if _, err := io.ReadFull(br, doc); err != nil {
+				return fmt.Errorf("truncated snapshot: %w", err)
+			}
+			e.Doc = string(doc)
+		}
+		entries = append(entries, e)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.reset()
+	for _, e := range entries {
+		idx.insert(e.Fingerprint, e.Doc)
+	}
+
+	return nil
+}
+
+// MinHash snapshot layout: magic, version, bands, rows, count, then each
+// signature oldest first followed by the length and bytes of its document,
+// all little-endian. Signatures only compare under the same banding.
+const (
+	minHashSnapshotMagic   = "MINH"
+	minHashSnapshotVersion = 1
+)
+
+// WriteSnapshot writes the indexed signatures to w.
+func (idx *MinHashIndex) WriteSnapshot(w io.Writer) error {
+	idx.mu.RLock()
+	entries := make([]minHashEntry, 0, idx.len())
+	for _, id := range idx.order[idx.head:] {
+		entries = append(entries, idx.entries[id])
+	}
+	idx.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(minHashSnapshotMagic)
+	binary.Write(bw, binary.LittleEndian, uint32(minHashSnapshotVersion))
+	binary.Write(bw, binary.LittleEndian, uint32(idx.cfg.Bands))
+	binary.Write(bw, binary.LittleEndian, uint32(idx.cfg.Rows))
+	binary.Write(bw, binary.LittleEndian, uint64(len(entries)))
+	for _, e := range entries {
+		binary.Write(bw, binary.LittleEndian, e.sig)
+		binary.Write(bw, binary.LittleEndian, uint32(len(e.doc)))
+		if _, err := bw.WriteString(e.doc); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ReadSnapshot replaces the index with the signatures in r, which must have
+// been written with the same bands and rows.
+func (idx *MinHashIndex) ReadSnapshot(r io.Reader) error {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(minHashSnapshotMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != minHashSnapshotMagic {
+		return errors.New("not a minhash snapshot")
+	}
+
+	var version, bands, rows uint32
+	var count uint64
+	for _, v := range []any{&version, &bands, &rows, &count} {
+		if err := binary.Read(br, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	if version != minHashSnapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	if int(bands) != idx.cfg.Bands || int(rows) != idx.cfg.Rows {
+		return fmt.Errorf("snapshot has %d bands of %d rows, configured %d of %d", bands, rows, idx.cfg.Bands, idx.cfg.Rows)
+	}
+
+	entries := make([]minHashEntry, 0, min(count, 1<<20))
+	for range count {
+		e := minHashEntry{sig: make([]uint32, bands*rows)}
+		if err := binary.Read(br, binary.LittleEndian, e.sig); err != nil {
+			return fmt.Errorf("truncated snapshot: %w", err)
+		}
+		var n uint32
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return fmt.Errorf("truncated snapshot: %w", err)
+		}
+		if n > maxSnapshotDoc {
+			return fmt.Errorf("corrupt snapshot: document of %d bytes", n)
+		}
+		doc := make([]byte, n)
+		if _, err := io.ReadFull(br, doc); err != nil {
+			return fmt.Errorf("truncated snapshot: %w", err)
+		}
+		e.doc = string(doc)
+		entries = append(entries, e)
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.reset()
+	for _, e := range entries {
+		idx.insert(e.sig, e.doc, idx.bandKeys(e.sig))
+	}
+
+	return nil
+}
+
+// Snapshottable is an index that can be written out and read back.
+type Snapshottable interface {
+	WriteSnapshot(w io.Writer) error
+	ReadSnapshot(r io.Reader) error
+	Stats(ctx context.Context) (IndexStats, error)
+}
+
+// Snapshotter persists a memory index to a file or a MinIO object.
+type Snapshotter struct {
+	idx    Snapshottable
+	cfg    Config
+	file   string
+	object string
+	store  *storage.MinioStore
+}
+
+func NewSnapshotter(idx Snapshottable, cfg Config, store *storage.MinioStore) (*Snapshotter, error) {
+	object, err := cfg.ObjectName()
+	if err != nil {
+		return nil, err
+	}
+	return &Snapshotter{idx: idx, cfg: cfg, file: cfg.FileName(), object: object, store: store}, nil
+}
+
+func (s *Snapshotter) target() string {
+	if s.file != "" {
+		return s.file
+	}
+	return "minio:" + s.object
+}
+
+// Load restores the last snapshot. A missing snapshot is not an error, the
+// index then starts empty.
+func (s *Snapshotter) Load(ctx context.Context) error {
+	var data []byte
+	var err error
+
+	if s.file != "" {
+		data, err = os.ReadFile(s.file)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	} else {
+		data, err = s.store.GetObject(ctx, s.object)
+		if storage.IsNotExist(err) {
+			return nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("Can't read dedup snapshot %s: %w", s.target(), err)
+	}
+
+	if err := s.idx.ReadSnapshot(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("Can't load dedup snapshot %s: %w", s.target(), err)
+	}
+
+	stats, _ := s.idx.Stats(ctx)
+	log.Printf("Loaded %d %s fingerprints from %s", stats.Fingerprints, stats.Algorithm, s.target())
+	return nil
+}
+
+// Save writes a snapshot. A file is replaced atomically.
+func (s *Snapshotter) Save(ctx context.Context) error {
+	var buf bytes.Buffer
+	if err := s.idx.WriteSnapshot(&buf); err != nil {
+		return err
+	}
+
+	if s.object != "" {
+		return s.store.PutObject(ctx, s.object, buf.Bytes(), "application/octet-stream")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.file), ".simhash-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.file)
+}
+
+// Run saves a snapshot every interval until ctx is done.
+func (s *Snapshotter) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.SnapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Save(ctx); err != nil {
+				log.Printf("Dedup snapshot to %s failed: %v", s.target(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package focus
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/KingrogKDR/Dev-Search/internal/indexer"
+)
+
+// Config enables focused crawling. Without a model file every outlink keeps
+// its URL-only score. The weights blend the three relevance estimates of an
+// outlink and are normalised, so only their ratio matters.
+type Config struct {
+	ModelFile     string  `json:"model_file" yaml:"model_file"`
+	ParentWeight  float64 `json:"parent_weight" yaml:"parent_weight"`
+	AnchorWeight  float64 `json:"anchor_weight" yaml:"anchor_weight"`
+	ContextWeight float64 `json:"context_weight" yaml:"context_weight"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		ParentWeight:  0.4,
+		AnchorWeight:  0.35,
+		ContextWeight: 0.25,
+	}
+}
+
+// Predictor estimates how likely a page is to be developer content before
+// it is fetched.
+type Predictor struct {
+	model *Model
+	cfg   Config
+}
+
+// NewPredictor loads the model, or returns nil when focused crawling is off.
+// A nil *Predictor is safe to use and predicts nothing.
+func NewPredictor(cfg Config) (*Predictor, error) {
+	if cfg.ModelFile == "" {
+		return nil, nil
+	}
+	if cfg.ParentWeight < 0 || cfg.AnchorWeight < 0 || cfg.ContextWeight < 0 ||
+		cfg.ParentWeight+cfg.AnchorWeight+cfg.ContextWeight == 0 {
+		return nil, fmt.Errorf("focus weights must be non-negative and not all zero")
+	}
+
+	model, err := LoadModel(cfg.ModelFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Predictor{model: model, cfg: cfg}, nil
+}
+
+func (p *Predictor) Enabled() bool {
+	return p != nil
+}
+
+// PageRelevance scores a fetched page from its title and text.
+func (p *Predictor) PageRelevance(title, text string) float64 {
+	if p == nil {
+		return 0
+	}
+	return p.model.Relevance(indexer.Terms(title + "\n" + text))
+}
+
+// Outlink blends the parent page's relevance with the relevance of the link's
+// anchor text and the text around it. Empty anchor or context text carries no
+// weight.
+func (p *Predictor) Outlink(parent float64, anchor, context string) float64 {
+	if p == nil {
+		return 0
+	}
+
+	sum := p.cfg.ParentWeight * parent
+	total := p.cfg.ParentWeight
+
+	if terms := indexer.Terms(anchor); len(terms) > 0 {
+		sum += p.cfg.AnchorWeight * p.model.Relevance(terms)
+		total += p.cfg.AnchorWeight
+	}
+	if terms := indexer.Terms(context); len(terms) > 0 {
+		sum += p.cfg.ContextWeight * p.model.Relevance(terms)
+		total += p.cfg.ContextWeight
+	}
+
+	if total == 0 {
+		return parent
+	}
+	return sum / total
+}
+
+// Labeler picks training labels for indexed documents by URL. Rules are
+// checked in order and the first match wins.
+type Labeler struct {
+	rules []labelRule
+}
+
+type labelRule struct {
+	relevant bool
+	host     string // host pattern, "" for any host
+	path     string // path fragment, "" for any path
+}
+
+// DefaultLabeler is a weak labeler: reference material is relevant, company
+// and marketing pages are not, everything else is left out.
+func DefaultLabeler() *Labeler {
+	l := &Labeler{}
+	for _, p := range []string{"/pricing", "/careers", "/jobs/", "/press", "/customers", "/legal", "/privacy", "/terms", "/contact", "/events", "/company", "/about", "/partners", "/webinar"} {
+		l.rules = append(l.rules, labelRule{relevant: false, path: p})
+	}
+	for _, p := range []string{"/docs/", "/doc/", "/api/", "/reference/", "/guide/", "/tutorial/", "/manual/", "/learn/"} {
+		l.rules = append(l.rules, labelRule{relevant: true, path: p})
+	}
+	return l
+}
+
+// LoadLabeler reads label rules, one per line:
+//
+//	relevant go.dev
+//	irrelevant *.example.com/pricing
+//	relevant /docs/
+//
+// A rule is a host pattern, a path fragment starting with "/", or both.
+func LoadLabeler(file string) (*Labeler, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read labels: %w", err)
+	}
+	defer f.Close()
+
+	l := &Labeler{}
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		label, target, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want \"relevant|irrelevant <host>/<path>\"", file, lineNo)
+		}
+
+		rule := labelRule{}
+		switch label {
+		case "relevant":
+			rule.relevant = true
+		case "irrelevant":
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown label %q", file, lineNo, label)
+		}
+
+		target = strings.ToLower(strings.TrimSpace(target))
+		if i := strings.Index(target, "/"); i >= 0 {
+			rule.host, rule.path = target[:i], target[i:]
+		} else {
+			rule.host = target
+		}
+		if _, err := path.Match(rule.host, ""); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid host pattern %q", file, lineNo, rule.host)
+		}
+
+		l.rules = append(l.rules, rule)
+	}
+
+	return l, scanner.Err()
+}
+
+// Label reports whether the document at rawUrl is relevant, and false for ok
+// when no rule applies.
+func (l *Labeler) Label(rawUrl string) (relevant bool, ok bool) {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return false, false
+	}
+	host := strings.ToLower(u.Hostname())
+	p := strings.ToLower(u.Path)
+
+	for _, r := range l.rules {
+		if r.host != "" && !matchHost(r.host, host) {
+			continue
+		}
+		if r.path != "" && !strings.Contains(p, r.path) {
+			continue
+		}
+		return r.relevant, true
+	}
+
+	return false, false
+}
+
+// "*.example.com" matches example.com itself as well as every subdomain.
+func matchHost(pattern string, host string) bool {
+	if apex, ok := strings.CutPrefix(pattern, "*."); ok && host == apex {
+		return true
+	}
+
+	matched, err := path.Match(pattern, host)
+	return err == nil && matched
+}
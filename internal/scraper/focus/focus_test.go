@@ -0,0 +1,127 @@
+package focus
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KingrogKDR/Dev-Search/internal/indexer"
+)
+
+func examples() []Example {
+	relevant := []string{
+		"install the package and call the function from your code",
+		"the api reference lists every function parameter and return value",
+		"compile the package with the go command and run the tests",
+		"this guide shows how to call the api from a function",
+	}
+	irrelevant := []string{
+		"request a demo and talk to our sales team about pricing",
+		"join our team, see open careers and benefits",
+		"our customers love the enterprise pricing plans",
+		"contact sales for a demo of the enterprise plan",
+	}
+
+	var ex []Example
+	for _, t := range relevant {
+		ex = append(ex, Example{Terms: indexer.Terms(t), Relevant: true})
+	}
+	for _, t := range irrelevant {
+		ex = append(ex, Example{Terms: indexer.Terms(t), Relevant: false})
+	}
+	return ex
+}
+
+func TestTrain(t *testing.T) {
+	m, err := Train(examples(), DefaultTrainOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := m.Relevance(indexer.Terms("how to call a function in the package")); r <= 0.5 {
+		t.Errorf("developer text relevance = %.2f, want > 0.5", r)
+	}
+	if r := m.Relevance(indexer.Terms("enterprise pricing, contact sales for a demo")); r >= 0.5 {
+		t.Errorf("marketing text relevance = %.2f, want < 0.5", r)
+	}
+	if r := m.Relevance(nil); r != 0.5 {
+		t.Errorf("prior = %.2f, want 0.5 for balanced classes", r)
+	}
+
+	if _, err := Train(examples()[:4], DefaultTrainOptions()); err == nil {
+		t.Error("training with a single class should fail")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	m, _ := Train(examples(), DefaultTrainOptions())
+	file := filepath.Join(t.TempDir(), "model.json")
+
+	if err := m.Save(file); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPredictor(Config{ModelFile: file, ParentWeight: 1, AnchorWeight: 1, ContextWeight: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := p.PageRelevance("API reference", "call the function from your code")
+	if parent <= 0.5 {
+		t.Errorf("page relevance = %.2f, want > 0.5", parent)
+	}
+
+	// an irrelevant anchor pulls a link below its parent, an empty one
+	// leaves it alone
+	if r := p.Outlink(parent, "pricing plans", ""); r >= parent {
+		t.Errorf("pricing outlink = %.2f, want below parent %.2f", r, parent)
+	}
+	if r := p.Outlink(parent, "", ""); r != parent {
+		t.Errorf("bare outlink = %.2f, want parent %.2f", r, parent)
+	}
+
+	var off *Predictor
+	if off.Enabled() || off.Outlink(0.9, "api", "") != 0 {
+		t.Error("nil predictor should predict nothing")
+	}
+}
+
+func TestLabeler(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "labels.txt")
+	rules := "# team labels\nirrelevant *.example.com/pricing\nrelevant *.example.com\nirrelevant /blog/\n"
+	if err := os.WriteFile(file, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	custom, err := LoadLabeler(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		labeler  *Labeler
+		url      string
+		relevant bool
+		ok       bool
+	}{
+		{DefaultLabeler(), "https://go.dev/doc/install", true, true},
+		{DefaultLabeler(), "https://example.com/docs/pricing", false, true},
+		{DefaultLabeler(), "https://example.com/blog/x", false, false},
+		{custom, "https://www.example.com/pricing/teams", false, true},
+		{custom, "https://example.com/anything", true, true},
+		{custom, "https://other.org/blog/x", false, true},
+		{custom, "https://other.org/x", false, false},
+	}
+
+	for _, c := range cases {
+		relevant, ok := c.labeler.Label(c.url)
+		if relevant != c.relevant || ok != c.ok {
+			t.Errorf("%s: got (%v, %v), want (%v, %v)", c.url, relevant, ok, c.relevant, c.ok)
+		}
+	}
+
+	os.WriteFile(file, []byte("maybe go.dev\n"), 0o644)
+	if _, err := LoadLabeler(file); err == nil {
+		t.Error("unknown label should fail")
+	}
+}
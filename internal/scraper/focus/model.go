@@ -0,0 +1,147 @@
+package focus
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+)
+
+// Model is a binarized multinomial naive Bayes classifier over index terms.
+// Only the log-odds are kept: Bias for the class priors and one weight per
+// term, log P(term|relevant) - log P(term|irrelevant). Unknown terms carry no
+// evidence.
+type Model struct {
+	Bias    float64            `json:"bias"`
+	Weights map[string]float64 `json:"weights"`
+
+	Relevant   int `json:"relevant"` // training documents per class
+	Irrelevant int `json:"irrelevant"`
+}
+
+type Example struct {
+	Terms    map[string]int
+	Relevant bool
+}
+
+type TrainOptions struct {
+	Alpha    float64 // Laplace smoothing
+	MinDocs  int     // terms in fewer documents are dropped
+	MaxTerms int     // most frequent terms kept, 0 keeps all
+}
+
+func DefaultTrainOptions() TrainOptions {
+	return TrainOptions{
+		Alpha:    1,
+		MinDocs:  2,
+		MaxTerms: 50_000,
+	}
+}
+
+// Train fits a model. A term counts once per document, so long pages do not
+// drown out short ones.
+func Train(examples []Example, opts TrainOptions) (*Model, error) {
+	var docs [2]int
+	counts := map[string]*[2]int{}
+
+	for _, ex := range examples {
+		class := 0
+		if ex.Relevant {
+			class = 1
+		}
+		docs[class]++
+
+		for term := range ex.Terms {
+			c, ok := counts[term]
+			if !ok {
+				c = &[2]int{}
+				counts[term] = c
+			}
+			c[class]++
+		}
+	}
+
+	if docs[0] == 0 || docs[1] == 0 {
+		return nil, fmt.Errorf("need examples of both classes, got %d relevant and %d irrelevant", docs[1], docs[0])
+	}
+
+	vocab := make([]string, 0, len(counts))
+	for term, c := range counts {
+		if c[0]+c[1] >= opts.MinDocs {
+			vocab = append(vocab, term)
+		}
+	}
+
+	slices.SortFunc(vocab, func(a, b string) int {
+		ca, cb := counts[a], counts[b]
+		if d := (cb[0] + cb[1]) - (ca[0] + ca[1]); d != 0 {
+			return d
+		}
+		if a < b {
+			return -1
+		}
+		return 1
+	})
+	if opts.MaxTerms > 0 && len(vocab) > opts.MaxTerms {
+		vocab = vocab[:opts.MaxTerms]
+	}
+
+	var totals [2]float64
+	for _, term := range vocab {
+		totals[0] += float64(counts[term][0])
+		totals[1] += float64(counts[term][1])
+	}
+
+	v := float64(len(vocab))
+	m := &Model{
+		Bias:       math.Log(float64(docs[1])) - math.Log(float64(docs[0])),
+		Weights:    make(map[string]float64, len(vocab)),
+		Relevant:   docs[1],
+		Irrelevant: docs[0],
+	}
+
+	for _, term := range vocab {
+		c := counts[term]
+		p1 := (float64(c[1]) + opts.Alpha) / (totals[1] + opts.Alpha*v)
+		p0 := (float64(c[0]) + opts.Alpha) / (totals[0] + opts.Alpha*v)
+		m.Weights[term] = math.Log(p1) - math.Log(p0)
+	}
+
+	return m, nil
+}
+
+// Relevance is the probability that a text with these terms is relevant.
+// With no known terms it is the prior.
+func (m *Model) Relevance(terms map[string]int) float64 {
+	logOdds := m.Bias
+	for term := range terms {
+		logOdds += m.Weights[term]
+	}
+	return 1 / (1 + math.Exp(-logOdds))
+}
+
+func (m *Model) Save(file string) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o644)
+}
+
+func LoadModel(file string) (*Model, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read focus model: %w", err)
+	}
+
+	var m Model
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("Can't parse focus model %s: %w", file, err)
+	}
+	if len(m.Weights) == 0 {
+		return nil, fmt.Errorf("focus model %s has no terms", file)
+	}
+
+	return &m, nil
+}
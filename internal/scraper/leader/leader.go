@@ -0,0 +1,89 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scripts"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const LeaseKey = "leader:%s"
+
+// Elector holds a Redis lease so periodic maintenance runs in one process
+// of the fleet. The lease is renewed at a third of its TTL; when the leader
+// dies another process takes over once the TTL runs out.
+type Elector struct {
+	rdb    *redis.Client
+	key    string
+	id     string
+	ttl    time.Duration
+	leader atomic.Bool
+}
+
+func NewElector(rdb *redis.Client, name string, ttl time.Duration) *Elector {
+	return &Elector{
+		rdb: rdb,
+		key: fmt.Sprintf(LeaseKey, name),
+		id:  uuid.NewString(),
+		ttl: ttl,
+	}
+}
+
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns until ctx is done, then gives the lease up.
+func (e *Elector) Run(ctx context.Context) {
+	e.Campaign(ctx)
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.release()
+			return
+		case <-ticker.C:
+			e.Campaign(ctx)
+		}
+	}
+}
+
+// Campaign takes or renews the lease once and reports whether we lead.
+func (e *Elector) Campaign(ctx context.Context) bool {
+	held, err := scripts.LeaseAcquireScript.Run(ctx, e.rdb, []string{e.key}, e.id, e.ttl.Milliseconds()).Int()
+	if err != nil {
+		// without Redis we can't prove we still hold the lease
+		log.Printf("[Leader] %s: %v", e.key, err)
+		held = 0
+	}
+
+	was := e.leader.Swap(held == 1)
+	if held == 1 && !was {
+		log.Printf("[Leader] %s acquired by %s", e.key, e.id)
+	} else if held != 1 && was {
+		log.Printf("[Leader] %s lost by %s", e.key, e.id)
+	}
+
+	return held == 1
+}
+
+func (e *Elector) release() {
+	if !e.leader.Swap(false) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := scripts.LeaseReleaseScript.Run(ctx, e.rdb, []string{e.key}, e.id).Err(); err != nil {
+		log.Printf("[Leader] Failed to release %s: %v", e.key, err)
+	}
+}
@@ -0,0 +1,97 @@
+package parsing
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Directives are the page-level robots rules from <meta name="robots">,
+// bot-specific meta tags and the X-Robots-Tag header.
+type Directives struct {
+	NoIndex   bool `json:"noindex,omitempty"`
+	NoFollow  bool `json:"nofollow,omitempty"`
+	NoArchive bool `json:"noarchive,omitempty"`
+}
+
+func (d Directives) Empty() bool {
+	return !d.NoIndex && !d.NoFollow && !d.NoArchive
+}
+
+func (d Directives) String() string {
+	var applied []string
+	if d.NoIndex {
+		applied = append(applied, "noindex")
+	}
+	if d.NoFollow {
+		applied = append(applied, "nofollow")
+	}
+	if d.NoArchive {
+		applied = append(applied, "noarchive")
+	}
+	return strings.Join(applied, ",")
+}
+
+// ParseDirectives merges the X-Robots-Tag header values and the robots meta
+// tags of the page. token is our product token, e.g. "dev_search"; rules
+// aimed at other bots are ignored.
+func ParseDirectives(headers []string, rawHtml string, token string) Directives {
+	var d Directives
+
+	for _, value := range headers {
+		// "otherbot: noindex" only applies to otherbot
+		if agent, rest, found := strings.Cut(value, ":"); found && !strings.Contains(agent, ",") {
+			agent = strings.ToLower(strings.TrimSpace(agent))
+			if agent != "unavailable_after" {
+				if agent != token {
+					continue
+				}
+				value = rest
+			}
+		}
+		d.apply(value)
+	}
+
+	if rawHtml == "" {
+		return d
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawHtml))
+	if err != nil {
+		return d
+	}
+
+	doc.Find("meta[name][content]").Each(func(i int, s *goquery.Selection) {
+		name := strings.ToLower(strings.TrimSpace(s.AttrOr("name", "")))
+		if name == "robots" || name == token {
+			d.apply(s.AttrOr("content", ""))
+		}
+	})
+
+	return d
+}
+
+func (d *Directives) apply(value string) {
+	for directive := range strings.SplitSeq(value, ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "noindex":
+			d.NoIndex = true
+		case "nofollow":
+			d.NoFollow = true
+		case "noarchive", "nocache":
+			d.NoArchive = true
+		case "none":
+			d.NoIndex = true
+			d.NoFollow = true
+		}
+	}
+}
+
+func isNofollowLink(s *goquery.Selection) bool {
+	for rel := range strings.FieldsSeq(strings.ToLower(s.AttrOr("rel", ""))) {
+		if rel == "nofollow" {
+			return true
+		}
+	}
+	return false
+}
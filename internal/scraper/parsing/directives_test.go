@@ -0,0 +1,52 @@
+package parsing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestParseDirectives(t *testing.T) {
+	cases := []struct {
+		name    string
+		headers []string
+		html    string
+		want    string
+	}{
+		{"none", nil, `<html><head></head></html>`, ""},
+		{"meta robots", nil, `<meta name="robots" content="NoIndex, nofollow">`, "noindex,nofollow"},
+		{"bot meta", nil, `<meta name="dev_search" content="noarchive"><meta name="googlebot" content="noindex">`, "noarchive"},
+		{"meta none", nil, `<meta name="robots" content="none">`, "noindex,nofollow"},
+		{"header", []string{"noindex", "noarchive"}, "", "noindex,noarchive"},
+		{"header for us", []string{"dev_search: nofollow"}, "", "nofollow"},
+		{"header for others", []string{"otherbot: noindex"}, "", ""},
+		{"unavailable_after", []string{"unavailable_after: 25 Jun 2010 15:00:00 PST"}, "", ""},
+		{"merged", []string{"noarchive"}, `<meta name="robots" content="noindex">`, "noindex,noarchive"},
+	}
+
+	for _, c := range cases {
+		if got := ParseDirectives(c.headers, c.html, "dev_search").String(); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestNofollowLink(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(
+		`<a href="/a">a</a><a href="/b" rel="ugc NoFollow">b</a><a href="/c" rel="noopener">c</a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var followed []string
+	doc.Find("a").Each(func(i int, s *goquery.Selection) {
+		if !isNofollowLink(s) {
+			followed = append(followed, s.AttrOr("href", ""))
+		}
+	})
+
+	if strings.Join(followed, " ") != "/a /c" {
+		t.Errorf("followed %v, want [/a /c]", followed)
+	}
+}
@@ -9,8 +9,17 @@ import (
 	"strings"
 
 	"github.com/KingrogKDR/Dev-Search/internal/indexer"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/deduplication"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/focus"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/normalizer"
 	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scope"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scoring"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/seeds"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/stats"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/templates"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/traps"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/urlseen"
 	"github.com/KingrogKDR/Dev-Search/internal/storage"
 	"github.com/KingrogKDR/Dev-Search/internal/streams"
 	"github.com/redis/go-redis/v9"
@@ -23,17 +32,35 @@ import (
 )
 
 type ParsePayload struct {
-	ObjectKey string `json:"object_key"`
-	Hash      uint64 `json:"hash"`
-	Type      string `json:"type"`
+	ObjectKey  string     `json:"object_key"`
+	Hash       uint64     `json:"hash"`
+	Type       string     `json:"type"`
+	Directives Directives `json:"directives,omitzero"`
+	Cluster    string     `json:"cluster,omitempty"` // duplicate cluster the page is canonical for
 }
+
+// PAYLOAD_ALIAS jobs carry no page. The crawler dropped a duplicate and only
+// its cluster's aliases need publishing.
+const PAYLOAD_ALIAS = "alias"
+
 type ParsedPage struct {
 	Text          string
 	Title         string
 	Links         []string
+	Anchors       map[string]Anchor // by link, for the focus classifier
 	HasCodeBlocks bool
 }
 
+// Anchor is the text a page links with and the text of the block around the
+// link.
+type Anchor struct {
+	Text    string
+	Context string
+}
+
+// anchorContextLen bounds the surrounding text kept per link.
+const anchorContextLen = 300
+
 func NewParsePayload(objectKey string, hash uint64, typ string) *ParsePayload {
 	return &ParsePayload{
 		ObjectKey: objectKey,
@@ -42,12 +69,19 @@ func NewParsePayload(objectKey string, hash uint64, typ string) *ParsePayload {
 	}
 }
 
+func NewAliasPayload(cluster string) *ParsePayload {
+	return &ParsePayload{
+		Type:    PAYLOAD_ALIAS,
+		Cluster: cluster,
+	}
+}
+
 const (
 	UrlMetaKey = "urlmeta:%s"
 	Streamer   = "parser"
 )
 
-func ExtractTextAndStore(ctx context.Context, job *queues.Job, store *storage.MinioStore, frontier *queues.Queue, parseStream *streams.MsgStream) error {
+func ExtractTextAndStore(ctx context.Context, job *queues.Job, store *storage.MinioStore, frontier *queues.Queue, parseStream *streams.MsgStream, scopeEnforcer *scope.Enforcer, trapDetector *traps.Detector, seenFilter *urlseen.Filter, scorer scoring.Scorer, predictor *focus.Predictor, clusters *deduplication.Clusters, learner *templates.Learner) error {
 	if job.Type != string(queues.JOB_PARSE) {
 		return nil
 	}
@@ -66,6 +100,9 @@ func ExtractTextAndStore(ctx context.Context, job *queues.Job, store *storage.Mi
 	if err := json.Unmarshal(job.Payload, &payload); err != nil {
 		return fmt.Errorf("failed unmarshaling parse payload: %w", err)
 	}
+	if payload.Type == PAYLOAD_ALIAS {
+		return publishAliases(ctx, job, parseStream, clusters, payload.Cluster)
+	}
 	if payload.ObjectKey == "" {
 		return fmt.Errorf("invalid payload: missing object key")
 	}
@@ -76,17 +113,32 @@ func ExtractTextAndStore(ctx context.Context, job *queues.Job, store *storage.Mi
 		return fmt.Errorf("failed getting object from s3: %w", err)
 	}
 
-	parsedPage, err := extractAccordingToType(string(rawData), payload.Type, parsed)
+	// the text comes without the host's template, links from the whole page
+	textSource := string(rawData)
+	if payload.Type == "html" {
+		stripped, removed, err := learner.StripHTML(ctx, parsed.Hostname(), textSource)
+		if err != nil {
+			log.Printf("[Parser] %v", err)
+		} else if removed > 0 {
+			log.Printf("[Parser] Stripped %d template blocks from %s", removed, job.URL)
+			textSource = stripped
+		}
+	}
+
+	parsedPage, err := extractAccordingToType(textSource, payload.Type, parsed)
 
 	if err != nil {
 		return fmt.Errorf("failed extracting text from raw data: %w", err)
 	}
 
-	if err = store.StoreTextData(ctx, parsedPage.Text, payload.Hash); err != nil {
-		return fmt.Errorf("failed storing text data: %w", err)
+	if textSource != string(rawData) {
+		parsedPage.Links, parsedPage.Anchors, err = extractLinks(string(rawData), parsed)
+		if err != nil {
+			return fmt.Errorf("failed extracting links: %w", err)
+		}
 	}
 
-	currentMeta, err := getUrlMeta(ctx, job.URL)
+	currentMeta, err := GetUrlMeta(ctx, job.URL)
 	if err != nil {
 		return fmt.Errorf("failed fetching metadata for current url: %w", err)
 	}
@@ -94,23 +146,28 @@ func ExtractTextAndStore(ctx context.Context, job *queues.Job, store *storage.Mi
 	if currentMeta == nil {
 		log.Printf("[Parser] Warning: no existing metadata found for %s, creating fresh...", job.URL)
 		currentMeta = queues.NewUrlMeta(0)
-		queues.ClassifyURL(parsed, currentMeta)
+		currentMeta.Seed = job.URL
+		scorer.Classify(parsed, currentMeta)
 	}
 	currentMeta.HasCodeBlocks = parsedPage.HasCodeBlocks
-	snippet := generateSnippet(parsedPage.Text)
 
-	record := indexer.NewRecord(payload.Hash, job.URL, parsedPage.Title, snippet, payload.ObjectKey, currentMeta.InboundLinks)
+	pageRelevance := predictor.PageRelevance(parsedPage.Title, parsedPage.Text)
 
-	recordBytes, err := json.Marshal(record)
-	if err != nil {
-		return fmt.Errorf("Can't marshal record: %w", err)
+	directives := payload.Directives
+	if !directives.Empty() {
+		log.Printf("[Parser] Robots directives for %s: %s", job.URL, directives)
+		stats.IncrementDirectives(directives.NoIndex, directives.NoFollow, directives.NoArchive)
+
+		currentMeta.Directives = directives.String()
+		if metaBytes, err := json.Marshal(currentMeta); err == nil {
+			frontier.Redis.Set(ctx, fmt.Sprintf(UrlMetaKey, job.URL), metaBytes, 0)
+		}
 	}
 
-	msg := streams.NewMsg(recordBytes, Streamer)
-	log.Printf("[Parser] Publishing message for %s", job.URL)
-	err = parseStream.AddMsg(msg)
-	if err != nil {
-		log.Printf("[Parser] Failed to publish: %v", err)
+	if !directives.NoIndex {
+		if err := publishRecord(ctx, job, store, parseStream, clusters, parsedPage, &payload, currentMeta); err != nil {
+			return err
+		}
 	}
 
 	nextDepth := currentMeta.Depth + 1
@@ -120,7 +177,14 @@ func ExtractTextAndStore(ctx context.Context, job *queues.Job, store *storage.Mi
 		log.Printf("[Parser] canonical extraction failed: %v", err)
 	}
 
-	for _, u := range parsedPage.Links {
+	enqueued := 0
+
+	links := parsedPage.Links
+	if directives.NoFollow {
+		links = nil
+	}
+
+	for _, u := range links {
 
 		normalizedUrl, err := normalizePageURL(u, canonical)
 		if err != nil {
@@ -139,52 +203,199 @@ func ExtractTextAndStore(ctx context.Context, job *queues.Job, store *storage.Mi
 			continue
 		}
 
-		// create metadata for the discovered URL
-		newUrlMeta := queues.NewUrlMeta(nextDepth)
-		queues.ClassifyURL(urlParsed, newUrlMeta)
+		rawParsed, err := url.Parse(u)
+		if err != nil {
+			continue
+		}
 
-		metaBytes, err := json.Marshal(newUrlMeta)
+		trap, err := trapDetector.Check(ctx, rawParsed, urlParsed)
 		if err != nil {
-			log.Printf("[Parser] metadata marshal failed: %v", err)
+			log.Printf("[Parser] %v", err)
+		}
+
+		if trap != traps.OK {
+			log.Printf("[Parser] Crawler trap (%s): %s", trap, normalizedUrl)
+			stats.IncrementTrapSkip()
 			continue
 		}
 
-		// atomic deduplication
-		added, err := frontier.Redis.SetNX(ctx, metaKey, metaBytes, 0).Result()
+		// url-seen check, a false positive only costs us a new url
+		seen, err := seenFilter.Contains(ctx, normalizedUrl)
 		if err != nil {
-			log.Printf("[Parser] metadata store failed: %v", err)
+			log.Printf("[Parser] %v", err)
 			continue
 		}
 
 		// URL already discovered
-		if !added {
-
-			existingMeta, err := getUrlMeta(ctx, normalizedUrl)
-			if err != nil || existingMeta == nil {
-				continue
-			}
+		if seen {
+			addInboundLink(ctx, frontier, scorer, normalizedUrl, urlParsed)
+			continue
+		}
 
-			existingMeta.InboundLinks++
+		reason := scopeEnforcer.Check(urlParsed, nextDepth, currentMeta.Seed)
 
-			updatedBytes, err := json.Marshal(existingMeta)
+		if reason == scope.ALLOWED {
+			reason, err = scopeEnforcer.Admit(ctx, urlParsed)
 			if err != nil {
+				log.Printf("[Parser] %v", err)
 				continue
 			}
+		}
 
-			frontier.Redis.Set(ctx, metaKey, updatedBytes, 0)
+		if reason != scope.ALLOWED {
+			log.Printf("[Parser] Out of scope (%s): %s", reason, normalizedUrl)
+			scopeEnforcer.RecordSkip(ctx, normalizedUrl, reason)
+			continue
+		}
 
+		// only urls that get enqueued are marked seen, a rejected one is
+		// looked at again when it turns up elsewhere
+		added, err := seenFilter.Add(ctx, normalizedUrl)
+		if err != nil {
+			log.Printf("[Parser] %v", err)
+			continue
+		}
+
+		// another parser got there first
+		if !added {
+			addInboundLink(ctx, frontier, scorer, normalizedUrl, urlParsed)
+			continue
+		}
+
+		// full metadata is only kept for urls that get crawled
+		newUrlMeta := queues.NewUrlMeta(nextDepth)
+		newUrlMeta.Seed = currentMeta.Seed
+		scorer.Classify(urlParsed, newUrlMeta)
+
+		if predictor.Enabled() {
+			anchor := parsedPage.Anchors[u]
+			newUrlMeta.Relevance = predictor.Outlink(pageRelevance, anchor.Text, anchor.Context)
+			newUrlMeta.HasRelevance = true
+		}
+
+		metaBytes, err := json.Marshal(newUrlMeta)
+		if err != nil {
+			log.Printf("[Parser] metadata marshal failed: %v", err)
+			continue
+		}
+
+		if err := frontier.Redis.Set(ctx, metaKey, metaBytes, 0).Err(); err != nil {
+			log.Printf("[Parser] metadata store failed: %v", err)
 			continue
 		}
 
 		// new URL → enqueue
 		crawlJob := queues.NewJob(normalizedUrl)
 		crawlJob.Type = string(queues.JOB_CRAWL)
-		crawlJob.BaseScore = queues.ScoreDevURL(newUrlMeta)
+		crawlJob.BaseScore = scorer.Score(urlParsed, newUrlMeta).Score
 
-		if err := frontier.Enqueue(crawlJob); err != nil {
+		queued, err := frontier.Enqueue(crawlJob)
+		if err != nil {
 			log.Printf("[Parser] Failed to enqueue job: %v", err)
+			continue
+		}
+
+		if queued {
+			enqueued++
+		}
+	}
+
+	if err := seeds.NewRegistry(storage.GetRedisClient()).AddDiscovered(ctx, currentMeta.Seed, enqueued); err != nil {
+		log.Printf("[Parser] Failed to update seed stats: %v", err)
+	}
+
+	// the raw html is only kept until the page has been parsed
+	if directives.NoArchive {
+		if err := store.DeleteObject(ctx, payload.ObjectKey); err != nil {
+			log.Printf("[Parser] Failed to drop noarchive object %s: %v", payload.ObjectKey, err)
 		}
 	}
+
+	return nil
+}
+
+// addInboundLink counts a link to an already discovered url and rescores it.
+func addInboundLink(ctx context.Context, frontier *queues.Queue, scorer scoring.Scorer, normalizedUrl string, urlParsed *url.URL) {
+	existingMeta, err := GetUrlMeta(ctx, normalizedUrl)
+	if err != nil || existingMeta == nil {
+		return
+	}
+
+	existingMeta.InboundLinks++
+
+	updatedBytes, err := json.Marshal(existingMeta)
+	if err != nil {
+		return
+	}
+
+	frontier.Redis.Set(ctx, fmt.Sprintf(UrlMetaKey, normalizedUrl), updatedBytes, 0)
+
+	// a zset frontier moves the waiting job up in place
+	if _, err := frontier.Rescore(normalizedUrl, scorer.Score(urlParsed, existingMeta).Score); err != nil {
+		log.Printf("[Parser] Rescore of %s failed: %v", normalizedUrl, err)
+	}
+}
+
+func publishRecord(ctx context.Context, job *queues.Job, store *storage.MinioStore, parseStream *streams.MsgStream, clusters *deduplication.Clusters, parsedPage *ParsedPage, payload *ParsePayload, currentMeta *queues.UrlMeta) error {
+	var cluster *deduplication.Cluster
+	if payload.Cluster != "" {
+		var err error
+		if cluster, err = clusters.Get(ctx, payload.Cluster); err != nil {
+			return fmt.Errorf("failed loading duplicate cluster: %w", err)
+		}
+
+		// a better duplicate took over since the page was fetched
+		if cluster != nil && cluster.Canonical != job.URL {
+			log.Printf("[Parser] %s is no longer canonical for cluster %s, publishing aliases only", job.URL, cluster.ID)
+			return publish(job, parseStream, indexer.NewClusterRecord(cluster.ID, cluster.Version, cluster.Canonical, cluster.Aliases()))
+		}
+	}
+
+	// the indexer reads the extracted text, the raw page may be dropped
+	textKey, err := store.StoreTextData(ctx, parsedPage.Text, payload.Hash)
+	if err != nil {
+		return fmt.Errorf("failed storing text data: %w", err)
+	}
+
+	snippet := generateSnippet(parsedPage.Text)
+
+	record := indexer.NewRecord(payload.Hash, job.URL, parsedPage.Title, snippet, textKey, currentMeta.InboundLinks)
+	if cluster != nil {
+		record.Cluster = cluster.ID
+		record.ClusterVersion = cluster.Version
+		record.Aliases = cluster.Aliases()
+	}
+
+	return publish(job, parseStream, record)
+}
+
+// publishAliases sends the indexer the aliases of a cluster whose canonical
+// page is unchanged.
+func publishAliases(ctx context.Context, job *queues.Job, parseStream *streams.MsgStream, clusters *deduplication.Clusters, id string) error {
+	cluster, err := clusters.Get(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed loading duplicate cluster: %w", err)
+	}
+	if cluster == nil {
+		log.Printf("[Parser] Duplicate cluster %s for %s is gone, skipping", id, job.URL)
+		return nil
+	}
+
+	return publish(job, parseStream, indexer.NewClusterRecord(cluster.ID, cluster.Version, cluster.Canonical, cluster.Aliases()))
+}
+
+func publish(job *queues.Job, parseStream *streams.MsgStream, record *indexer.Record) error {
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("Can't marshal record: %w", err)
+	}
+
+	msg := streams.NewMsg(recordBytes, Streamer)
+	log.Printf("[Parser] Publishing message for %s", job.URL)
+	if err := parseStream.AddMsg(msg); err != nil {
+		log.Printf("[Parser] Failed to publish: %v", err)
+	}
+
 	return nil
 }
 
@@ -214,7 +425,7 @@ func extractHtml(rawHtml string, baseUrl *url.URL) (*ParsedPage, error) {
 
 	mainText := article.TextContent
 
-	links, err := extractLinks(rawHtml, baseUrl)
+	links, anchors, err := extractLinks(rawHtml, baseUrl)
 
 	if err != nil {
 		return nil, err
@@ -227,6 +438,7 @@ func extractHtml(rawHtml string, baseUrl *url.URL) (*ParsedPage, error) {
 	}
 	parsedPage.Text = strings.TrimSpace(mainText)
 	parsedPage.Links = links
+	parsedPage.Anchors = anchors
 
 	return parsedPage, nil
 }
@@ -245,6 +457,7 @@ func extractMd(rawMd string, baseUrl *url.URL) (*ParsedPage, error) {
 	var urls []string
 	var codeBlocks []string
 	seenUrls := make(map[string]struct{})
+	anchors := make(map[string]Anchor)
 
 	var skipSection = false
 
@@ -318,6 +531,10 @@ func extractMd(rawMd string, baseUrl *url.URL) (*ParsedPage, error) {
 			if _, exists := seenUrls[dest]; !exists {
 				urls = append(urls, dest)
 				seenUrls[dest] = struct{}{}
+				anchors[dest] = Anchor{
+					Text:    mdText(node, source),
+					Context: truncateContext(mdText(node.Parent(), source)),
+				}
 			}
 
 		case *ast.AutoLink:
@@ -369,6 +586,7 @@ func extractMd(rawMd string, baseUrl *url.URL) (*ParsedPage, error) {
 
 	parsedPage.Text = textBuilder.String()
 	parsedPage.Links = urls
+	parsedPage.Anchors = anchors
 
 	return parsedPage, nil
 }
@@ -393,18 +611,19 @@ func generateSnippet(text string) string {
 	return snippet + "..."
 }
 
-func extractLinks(rawHtml string, baseUrl *url.URL) ([]string, error) {
+func extractLinks(rawHtml string, baseUrl *url.URL) ([]string, map[string]Anchor, error) {
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawHtml))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var urls []string
+	anchors := make(map[string]Anchor)
 
 	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
 		href, exists := s.Attr("href")
 
-		if !exists {
+		if !exists || isNofollowLink(s) {
 			return
 		}
 
@@ -427,11 +646,52 @@ func extractLinks(rawHtml string, baseUrl *url.URL) ([]string, error) {
 		}
 
 		resolved := baseUrl.ResolveReference(parsed) // converts relative urls to absolute urls
+		dest := resolved.String()
+
+		urls = append(urls, dest)
 
-		urls = append(urls, resolved.String())
+		// the first anchor of a link describes it best, later ones are
+		// usually navigation repeats
+		if _, exists := anchors[dest]; !exists {
+			anchors[dest] = Anchor{
+				Text:    collapseSpace(s.Text()),
+				Context: truncateContext(collapseSpace(s.Closest("p, li, td, dd, dt, blockquote, section").Text())),
+			}
+		}
 	})
 
-	return urls, nil
+	return urls, anchors, nil
+}
+
+// mdText is the plain text below a markdown node.
+func mdText(n ast.Node, source []byte) string {
+	var b strings.Builder
+	_ = ast.Walk(n, func(c ast.Node, entering bool) (ast.WalkStatus, error) {
+		if t, ok := c.(*ast.Text); entering && ok {
+			b.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				b.WriteByte(' ')
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return collapseSpace(b.String())
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func truncateContext(s string) string {
+	if len(s) <= anchorContextLen {
+		return s
+	}
+
+	s = s[:anchorContextLen]
+	if lastSpace := strings.LastIndex(s, " "); lastSpace > 0 {
+		s = s[:lastSpace]
+	}
+	return s
 }
 
 func extractCanonicalURL(rawHTML string) (string, error) {
@@ -448,6 +708,26 @@ func extractCanonicalURL(rawHTML string) (string, error) {
 	return strings.TrimSpace(canonical), nil
 }
 
+// CanonicalTarget is the normalized rel=canonical URL an HTML page declares,
+// or "" when it declares none.
+func CanonicalTarget(rawHTML string, pageUrl *url.URL) string {
+	canonical, err := extractCanonicalURL(rawHTML)
+	if err != nil || canonical == "" {
+		return ""
+	}
+
+	ref, err := url.Parse(canonical)
+	if err != nil {
+		return ""
+	}
+
+	normalized, err := normalizer.RunNormalizationPipeline(pageUrl.ResolveReference(ref).String())
+	if err != nil {
+		return ""
+	}
+	return normalized
+}
+
 func normalizePageURL(rawURL string, canonical string) (string, error) {
 	if canonical != "" {
 		base, _ := url.Parse(rawURL)
@@ -460,7 +740,7 @@ func normalizePageURL(rawURL string, canonical string) (string, error) {
 	return normalizer.RunNormalizationPipeline(rawURL)
 }
 
-func getUrlMeta(ctx context.Context, currentUrl string) (*queues.UrlMeta, error) {
+func GetUrlMeta(ctx context.Context, currentUrl string) (*queues.UrlMeta, error) {
 	rdb := storage.GetRedisClient()
 	key := fmt.Sprintf(UrlMetaKey, currentUrl)
 
@@ -0,0 +1,35 @@
+package parsing
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestAnchors(t *testing.T) {
+	base, _ := url.Parse("https://go.dev/doc/")
+
+	_, anchors, err := extractLinks(`<ul>
+		<li>See <a href="install">the  install guide</a> before you start.</li>
+		<li><a href="/pricing">Pricing</a></li>
+	</ul><a href="install">again</a>`, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	install := anchors["https://go.dev/doc/install"]
+	if install.Text != "the install guide" || install.Context != "See the install guide before you start." {
+		t.Errorf("install anchor = %+v", install)
+	}
+	if pricing := anchors["https://go.dev/pricing"]; pricing.Text != "Pricing" {
+		t.Errorf("pricing anchor = %+v", pricing)
+	}
+
+	page, err := extractMd("# Intro\n\nRead the [API reference](/ref) for details.\n", base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := page.Anchors["https://go.dev/ref"]
+	if ref.Text != "API reference" || ref.Context != "Read the API reference for details." {
+		t.Errorf("markdown anchor = %+v", ref)
+	}
+}
@@ -0,0 +1,253 @@
+package queues
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DLQFilter selects entries of the dead-letter list <ns>:failed. Zero fields
+// match everything.
+type DLQFilter struct {
+	IDs    []string
+	Host   string
+	Error  string        // case-insensitive substring of the last error
+	MinAge time.Duration // failed at least this long ago
+	MaxAge time.Duration // failed at most this long ago
+}
+
+func (f DLQFilter) Empty() bool {
+	return len(f.IDs) == 0 && f.Host == "" && f.Error == "" && f.MinAge == 0 && f.MaxAge == 0
+}
+
+func (f DLQFilter) matches(job *Job, now time.Time) bool {
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, job.ID) {
+		return false
+	}
+	if f.Host != "" && !strings.EqualFold(job.Host, f.Host) {
+		return false
+	}
+	if f.Error != "" && !strings.Contains(strings.ToLower(job.ErrorMsg), strings.ToLower(f.Error)) {
+		return false
+	}
+
+	age := now.Sub(job.FailedAt)
+	if f.MinAge > 0 && age < f.MinAge {
+		return false
+	}
+	if f.MaxAge > 0 && age > f.MaxAge {
+		return false
+	}
+
+	return true
+}
+
+type DeadJob struct {
+	Job        *Job    `json:"job"`
+	ErrorClass string  `json:"error_class"`
+	Result     *Result `json:"result,omitempty"`
+
+	raw string
+}
+
+type FailureReason struct {
+	ErrorClass string `json:"error_class"`
+	Count      int    `json:"count"`
+	Example    string `json:"example"`
+}
+
+// DeadJobs lists the dead-letter entries matching the filter, newest first.
+func (q *Queue) DeadJobs(ctx context.Context, filter DLQFilter) ([]DeadJob, error) {
+	entries, err := q.Redis.LRange(ctx, fmt.Sprintf(FailedKey, q.namespace), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var dead []DeadJob
+
+	for _, raw := range entries {
+		var job Job
+		if err := json.Unmarshal([]byte(raw), &job); err != nil {
+			continue
+		}
+
+		if !filter.matches(&job, now) {
+			continue
+		}
+
+		dead = append(dead, DeadJob{
+			Job:        &job,
+			ErrorClass: ErrorClass(job.ErrorMsg),
+			raw:        raw,
+		})
+	}
+
+	return dead, nil
+}
+
+// DeadJob returns one entry with its last result.
+func (q *Queue) DeadJob(ctx context.Context, id string) (*DeadJob, error) {
+	dead, err := q.DeadJobs(ctx, DLQFilter{IDs: []string{id}})
+	if err != nil {
+		return nil, err
+	}
+	if len(dead) == 0 {
+		return nil, nil
+	}
+
+	entry := dead[0]
+
+	data, err := q.Redis.Get(ctx, fmt.Sprintf(ResultsKey, id)).Bytes()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+	if err == nil {
+		var result Result
+		if json.Unmarshal(data, &result) == nil {
+			entry.Result = &result
+		}
+	}
+
+	return &entry, nil
+}
+
+// RequeueDead puts matching entries back on the ready queues with a fresh
+// retry budget. An entry stays in the list until it is back on a queue, so a
+// failed enqueue never loses it; two concurrent requeues of one entry may
+// both enqueue it. An entry merged into a queued copy of its URL is removed
+// but not counted.
+func (q *Queue) RequeueDead(ctx context.Context, filter DLQFilter) (int, error) {
+	dead, err := q.DeadJobs(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	requeued := 0
+
+	for _, entry := range dead {
+		job := entry.Job
+		job.RetryCount = 0
+		job.Status = JOB_READY
+		job.ErrorMsg = ""
+		job.FailedAt = time.Time{}
+		job.LastEnqueuedAt = time.Time{}
+
+		added, err := q.Enqueue(job)
+		if err != nil {
+			return requeued, err
+		}
+
+		removed, err := q.Redis.LRem(ctx, fmt.Sprintf(FailedKey, q.namespace), 1, entry.raw).Result()
+		if err != nil {
+			return requeued, err
+		}
+		if removed == 0 {
+			continue
+		}
+		q.Redis.Del(ctx, fmt.Sprintf(ResultsKey, job.ID))
+
+		// merged into a copy of its URL that is already queued
+		if added {
+			requeued++
+		}
+	}
+
+	return requeued, nil
+}
+
+// PurgeDead drops matching entries and their results for good.
+func (q *Queue) PurgeDead(ctx context.Context, filter DLQFilter) (int, error) {
+	dead, err := q.DeadJobs(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+
+	for _, entry := range dead {
+		removed, err := q.Redis.LRem(ctx, fmt.Sprintf(FailedKey, q.namespace), 1, entry.raw).Result()
+		if err != nil {
+			return purged, err
+		}
+		if removed == 0 {
+			continue
+		}
+
+		q.Redis.Del(ctx, fmt.Sprintf(ResultsKey, entry.Job.ID))
+		purged++
+	}
+
+	return purged, nil
+}
+
+// FailureReasons groups dead jobs by error class, most frequent first.
+func FailureReasons(dead []DeadJob) []FailureReason {
+	byClass := map[string]*FailureReason{}
+
+	for _, entry := range dead {
+		reason, ok := byClass[entry.ErrorClass]
+		if !ok {
+			reason = &FailureReason{ErrorClass: entry.ErrorClass, Example: entry.Job.ErrorMsg}
+			byClass[entry.ErrorClass] = reason
+		}
+		reason.Count++
+	}
+
+	reasons := make([]FailureReason, 0, len(byClass))
+	for _, r := range byClass {
+		reasons = append(reasons, *r)
+	}
+
+	slices.SortFunc(reasons, func(a, b FailureReason) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return strings.Compare(a.ErrorClass, b.ErrorClass)
+	})
+
+	return reasons
+}
+
+var statusCodePattern = regexp.MustCompile(`status (\d{3})`)
+
+// ErrorClass buckets an error message so failures can be counted by cause.
+func ErrorClass(msg string) string {
+	lower := strings.ToLower(msg)
+
+	if m := statusCodePattern.FindStringSubmatch(lower); m != nil {
+		if m[1] == "429" {
+			return "http_429"
+		}
+		return "http_" + m[1][:1] + "xx"
+	}
+
+	switch {
+	case msg == "":
+		return "unknown"
+	case strings.Contains(lower, "rate limited"):
+		return "rate_limited"
+	case strings.Contains(lower, "timed out"), strings.Contains(lower, "timeout"),
+		strings.Contains(lower, "deadline exceeded"):
+		return "timeout"
+	case strings.Contains(lower, "no such host"):
+		return "dns"
+	case strings.Contains(lower, "connection refused"), strings.Contains(lower, "connection reset"),
+		strings.Contains(lower, "eof"):
+		return "connection"
+	case strings.Contains(lower, "tls"), strings.Contains(lower, "x509"), strings.Contains(lower, "certificate"):
+		return "tls"
+	case strings.Contains(lower, "s3"), strings.Contains(lower, "store"), strings.Contains(lower, "bucket"):
+		return "storage"
+	case strings.Contains(lower, "pars"), strings.Contains(lower, "unmarshal"), strings.Contains(lower, "extract"):
+		return "parse"
+	}
+
+	return "other"
+}
@@ -0,0 +1,68 @@
+package queues
+
+import (
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	cases := map[string]string{
+		"": "unknown",
+		"https://a.dev responded with status 503":                                        "http_5xx",
+		"https://a.dev responded with status 404":                                        "http_4xx",
+		"https://a.dev responded with status 429":                                        "http_429",
+		"Job processing timed out after 5m0s":                                            "timeout",
+		"Error fetching request for https://a.dev: dial tcp: lookup a.dev: no such host": "dns",
+		"Error fetching request for https://a.dev: read: connection reset by peer":       "connection",
+		"x509: certificate signed by unknown authority":                                  "tls",
+		"Can't store html: bucket missing":                                               "storage",
+		"failed parsing url: bad":                                                        "parse",
+		"something odd":                                                                  "other",
+	}
+
+	for msg, want := range cases {
+		if got := ErrorClass(msg); got != want {
+			t.Errorf("ErrorClass(%q) = %q, want %q", msg, got, want)
+		}
+	}
+}
+
+func TestDLQFilter(t *testing.T) {
+	now := time.Now()
+	job := &Job{ID: "a", Host: "go.dev", ErrorMsg: "Job processing timed out", FailedAt: now.Add(-2 * time.Hour)}
+
+	cases := []struct {
+		filter DLQFilter
+		want   bool
+	}{
+		{DLQFilter{}, true},
+		{DLQFilter{IDs: []string{"b", "a"}}, true},
+		{DLQFilter{IDs: []string{"b"}}, false},
+		{DLQFilter{Host: "GO.dev"}, true},
+		{DLQFilter{Host: "docs.rs"}, false},
+		{DLQFilter{Error: "TIMED OUT"}, true},
+		{DLQFilter{Error: "status 500"}, false},
+		{DLQFilter{MinAge: time.Hour}, true},
+		{DLQFilter{MinAge: 3 * time.Hour}, false},
+		{DLQFilter{MaxAge: time.Hour}, false},
+	}
+
+	for i, c := range cases {
+		if got := c.filter.matches(job, now); got != c.want {
+			t.Errorf("case %d: matches = %v, want %v", i, got, c.want)
+		}
+	}
+}
+
+func TestFailureReasons(t *testing.T) {
+	dead := []DeadJob{
+		{Job: &Job{ErrorMsg: "a"}, ErrorClass: "timeout"},
+		{Job: &Job{ErrorMsg: "b"}, ErrorClass: "dns"},
+		{Job: &Job{ErrorMsg: "c"}, ErrorClass: "timeout"},
+	}
+
+	reasons := FailureReasons(dead)
+	if len(reasons) != 2 || reasons[0].ErrorClass != "timeout" || reasons[0].Count != 2 || reasons[0].Example != "a" {
+		t.Errorf("unexpected reasons %+v", reasons)
+	}
+}
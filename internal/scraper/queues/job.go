@@ -35,6 +35,7 @@ const DEFAULT_RETRY_DELAY = 10 * time.Second
 type Job struct {
 	ID              string          `json:"id"`
 	URL             string          `json:"url"`
+	Host            string          `json:"host,omitempty"`
 	Type            string          `json:"type"`
 	Payload         json.RawMessage `json:"payload"`
 	Status          JobStatus       `json:"status"`
@@ -45,6 +46,18 @@ type Job struct {
 	CreatedAt       time.Time       `json:"created_at"`
 	LastEnqueuedAt  time.Time       `json:"last_enqueued_at"`
 	ErrorMsg        string          `json:"err_msg"`
+	FailedAt        time.Time       `json:"failed_at,omitzero"`
+	Seed            bool            `json:"seed,omitempty"`
+	// IndexKey is what the queued-URL index knows the job by when it is not
+	// its URL, e.g. the job id on a queue whose jobs must never be merged.
+	IndexKey string `json:"index_key,omitempty"`
+}
+
+func (j *Job) indexKey() string {
+	if j.IndexKey != "" {
+		return j.IndexKey
+	}
+	return j.URL
 }
 
 func NewJob(rawUrl string) *Job {
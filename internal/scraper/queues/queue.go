@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"net/url"
 	"time"
 
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scripts"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -16,25 +18,101 @@ const (
 	ResultsKey    = "results:%s"
 	FailedKey     = "%s:failed"
 	RetryKey      = "%s:retry"
+	HostsKey      = "%s:hosts"
+	BackQueueKey  = "%s:back:%s"
+	WorkersKey    = "%s:workers"
+	HeartbeatKey  = "heartbeat:%s"
+	URLsKey       = "%s:urls"
+	RankedKey     = "%s:ranked"
+	JobsKey       = "%s:jobs"
 
 	ProcessingTimeout = 5 * time.Minute
+
+	hostRefillBatch  = 64
+	hostReadyTarget  = 32
+	hostScan         = 32
+	hostPollInterval = 250 * time.Millisecond
+
+	retryMoveBatch = 500
+
+	rankedPollInterval = 250 * time.Millisecond
 )
 
+const (
+	// BACKEND_LISTS keeps one list per priority bucket.
+	BACKEND_LISTS = "lists"
+	// BACKEND_ZSET keeps the continuous score in a sorted set.
+	BACKEND_ZSET = "zset"
+)
+
+// NextFetchFunc tells a host-aware queue when a host may be fetched again.
+type NextFetchFunc func(ctx context.Context, host string) time.Time
+
+type Options struct {
+	ProcessingTimeout time.Duration
+	MaxRetries        int
+	// NextFetch makes the queue host-aware: the priority lists become front
+	// queues feeding one back queue per host, and a host only hands out its
+	// next job once its previous one is done and NextFetch has passed.
+	NextFetch NextFetchFunc
+	// Scheduler shares dequeues between priorities, nil drains them in
+	// strict order. A host-aware queue applies it to the job each ready host
+	// would hand out next, a plain zset queue serves by score alone.
+	Scheduler *Scheduler
+	// Backend is BACKEND_LISTS or BACKEND_ZSET, empty means lists.
+	Backend string
+	// MergeURLs queues a URL only once: a job for a URL already waiting or in
+	// flight is merged into that copy. Without it every job is queued, which
+	// suits jobs whose payload matters, like parse jobs.
+	MergeURLs bool
+}
+
+func DefaultOptions() Options {
+	return Options{
+		ProcessingTimeout: ProcessingTimeout,
+		MaxRetries:        MAX_RETRIES,
+		Backend:           BACKEND_LISTS,
+		MergeURLs:         true,
+	}
+}
+
 type Queue struct {
 	Redis     *redis.Client
 	ctx       context.Context
 	namespace string
+	opts      Options
 }
 
-func NewQueue(redisClient *redis.Client, namespace string) *Queue {
+func NewQueue(redisClient *redis.Client, namespace string, opts Options) *Queue {
 	return &Queue{
 		Redis:     redisClient,
 		ctx:       context.Background(),
 		namespace: namespace,
+		opts:      opts,
 	}
 }
 
-func (q *Queue) Enqueue(job *Job) error {
+func (q *Queue) MaxRetries() int {
+	return q.opts.MaxRetries
+}
+
+func (q *Queue) hostAware() bool {
+	return q.opts.NextFetch != nil
+}
+
+// ranked reports whether jobs wait in a sorted set by continuous score
+// instead of in priority lists.
+func (q *Queue) ranked() bool {
+	return q.opts.Backend == BACKEND_ZSET
+}
+
+func (q *Queue) rankedKeys() []string {
+	return []string{fmt.Sprintf(RankedKey, q.namespace), fmt.Sprintf(JobsKey, q.namespace)}
+}
+
+// Enqueue queues the job and reports whether it was added, false when it was
+// merged into a copy of its URL that is already queued.
+func (q *Queue) Enqueue(job *Job) (bool, error) {
 	var effectiveScore int
 
 	if job.LastEnqueuedAt.IsZero() {
@@ -47,69 +125,266 @@ func (q *Queue) Enqueue(job *Job) error {
 	job.Priority = ScoreToPriority(effectiveScore)
 	job.LastEnqueuedAt = time.Now()
 
+	if job.Host == "" {
+		if u, err := url.Parse(job.URL); err == nil {
+			job.Host = u.Hostname()
+		}
+	}
+
+	if !q.opts.MergeURLs && job.IndexKey == "" {
+		job.IndexKey = "job:" + job.ID
+	}
+
 	jobData, err := json.Marshal(job)
 	if err != nil {
-		return fmt.Errorf("Failed to marshal job: %w", err)
+		return false, fmt.Errorf("Failed to marshal job: %w", err)
 	}
 
-	queueKey := fmt.Sprintf(ReadyKey, q.namespace, string(job.Priority))
-
-	err = q.Redis.RPush(q.ctx, queueKey, jobData).Err()
+	var added bool
+	if q.ranked() {
+		// the set ages jobs itself, so the score goes in unaged
+		added, err = q.addRanked(job, string(jobData))
+	} else {
+		queueKey := fmt.Sprintf(ReadyKey, q.namespace, string(job.Priority))
+		added, err = q.addUnique(queueKey, job, effectiveScore, string(jobData), URL_READY, 0)
+	}
 	if err != nil {
-		return fmt.Errorf("Failed to enqueue task: %w", err)
+		return false, fmt.Errorf("Failed to enqueue task: %w", err)
 	}
 
-	log.Printf("Job %s enqueued to '%s' queue", job.ID, job.Priority)
+	if added && q.ranked() {
+		log.Printf("Job %s enqueued with score %d", job.ID, job.BaseScore)
+	} else if added {
+		log.Printf("Job %s enqueued to '%s' queue", job.ID, job.Priority)
+	} else {
+		log.Printf("Job %s for %s merged into the copy already queued", job.ID, job.URL)
+	}
 
-	return nil
+	return added, nil
 }
 
 func (q *Queue) RequeueWithDelay(job *Job, delay time.Duration) error {
+	due := time.Now().Add(delay)
+	member, err := retryMember(job, due)
+	if err != nil {
+		return err
+	}
+
+	_, err = q.addUnique(fmt.Sprintf(RetryKey, q.namespace), job, job.BaseScore, member.Member.(string), URL_RETRY, due.UnixMilli())
+	return err
+}
+
+// addUnique queues the job through the URL index so a URL is only queued
+// once. It reports false when the job was merged into an existing copy.
+func (q *Queue) addUnique(key string, job *Job, score int, data string, state URLState, dueMs int64) (bool, error) {
+	n, err := scripts.FrontierEnqueueScript.Run(
+		q.ctx,
+		q.Redis,
+		[]string{fmt.Sprintf(URLsKey, q.namespace), key},
+		job.indexKey(),
+		job.ID,
+		score,
+		data,
+		string(state),
+		dueMs,
+	).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func (q *Queue) addRanked(job *Job, data string) (bool, error) {
+	n, err := scripts.FrontierEnqueueScript.Run(
+		q.ctx,
+		q.Redis,
+		append([]string{fmt.Sprintf(URLsKey, q.namespace)}, q.rankedKeys()...),
+		job.indexKey(),
+		job.ID,
+		job.BaseScore,
+		data,
+		"ranked",
+		time.Now().UnixMilli(),
+		agingInterval.Milliseconds(),
+	).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// Rescore changes the score of a URL waiting in a zset queue without losing
+// the aging it has built up, e.g. when new inbound links are found. It
+// reports false when the URL is not waiting there, which is always the case
+// for a list queue.
+func (q *Queue) Rescore(rawUrl string, score int) (bool, error) {
+	if !q.ranked() {
+		return false, nil
+	}
+
+	n, err := scripts.RankedRescoreScript.Run(
+		q.ctx,
+		q.Redis,
+		[]string{fmt.Sprintf(URLsKey, q.namespace), fmt.Sprintf(RankedKey, q.namespace)},
+		rawUrl,
+		score,
+	).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// retryMember scores a job by its due time in ms. Aging for the wait is
+// applied up front because the mover script pushes the job as stored.
+func retryMember(job *Job, due time.Time) (redis.Z, error) {
+	job.BaseScore = ApplyAging(job.BaseScore, due.Sub(job.LastEnqueuedAt))
+	job.Priority = ScoreToPriority(job.BaseScore)
+	job.LastEnqueuedAt = due
+	job.Status = JOB_READY
 
 	data, err := json.Marshal(job)
 	if err != nil {
-		return err
+		return redis.Z{}, err
 	}
 
-	retryTime := time.Now().Add(delay).Unix()
-	retryKey := fmt.Sprintf(RetryKey, q.namespace)
-	return q.Redis.ZAdd(q.ctx, retryKey, redis.Z{
-		Score:  float64(retryTime),
+	return redis.Z{
+		Score:  float64(due.UnixMilli()),
 		Member: string(data),
-	}).Err()
+	}, nil
 }
 
-func (q *Queue) Dequeue(queues []string, workerID string, timeout time.Duration) (*Job, error) {
-	queueKeys := make([]string, len(queues))
-	for i, queue := range queues {
-		queueKeys[i] = fmt.Sprintf(ReadyKey, q.namespace, queue)
+// Defer puts an in-flight job back without counting it as a failed attempt.
+// On a host-aware queue it goes to the front of its host's back queue and
+// the whole host waits for the delay.
+func (q *Queue) Defer(job *Job, delay time.Duration, workerID string) error {
+	processingKey := fmt.Sprintf(ProcessingKey, workerID)
+
+	if !q.hostAware() {
+		if err := q.Redis.LRem(q.ctx, processingKey, 1, job.ID).Err(); err != nil {
+			return err
+		}
+		if err := q.Redis.Del(q.ctx, "job:"+job.ID).Err(); err != nil {
+			return err
+		}
+		return q.RequeueWithDelay(job, delay)
 	}
 
-	result, err := q.Redis.BLPop(q.ctx, timeout, queueKeys...).Result()
+	job.Status = JOB_READY
+
+	data, err := json.Marshal(job)
 	if err != nil {
-		if err == redis.Nil {
-			return nil, nil // no available jobs
+		return err
+	}
+
+	pipe := q.Redis.Pipeline()
+	pipe.LRem(q.ctx, processingKey, 1, job.ID)
+	pipe.Del(q.ctx, "job:"+job.ID)
+	pipe.LPush(q.ctx, fmt.Sprintf(BackQueueKey, q.namespace, job.Host), data)
+	pipe.HSet(q.ctx, fmt.Sprintf(URLsKey, q.namespace), job.indexKey(), indexEntry(URL_READY, job.BaseScore, job.ID))
+	pipe.ZAdd(q.ctx, fmt.Sprintf(HostsKey, q.namespace), redis.Z{
+		Score:  float64(time.Now().Add(delay).UnixMilli()),
+		Member: job.Host,
+	})
+
+	_, err = pipe.Exec(q.ctx)
+	return err
+}
+
+func (q *Queue) Dequeue(queues []string, workerID string, timeout time.Duration) (*Job, error) {
+	// a plain ranked queue serves by score, everything else by schedule
+	if !q.ranked() || q.hostAware() {
+		queues = q.opts.Scheduler.Order(queues)
+	}
+
+	var queueKeys []string
+	if q.ranked() {
+		queueKeys = []string{fmt.Sprintf(RankedKey, q.namespace)}
+	} else {
+		for _, queue := range queues {
+			queueKeys = append(queueKeys, fmt.Sprintf(ReadyKey, q.namespace, queue))
+		}
+	}
+
+	var data string
+	indexScore := 0
+
+	if q.hostAware() {
+		var err error
+		data, err = q.dequeueReadyHost(queueKeys, queues, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to dequeue task: %w", err)
+		}
+		if data == "" {
+			return nil, nil
+		}
+	} else if q.ranked() {
+		var err error
+		data, indexScore, err = q.dequeueRanked(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to dequeue task: %w", err)
+		}
+		if data == "" {
+			return nil, nil
+		}
+	} else {
+		result, err := q.Redis.BLPop(q.ctx, timeout, queueKeys...).Result()
+		if err != nil {
+			if err == redis.Nil {
+				return nil, nil // no available jobs
+			}
+			return nil, fmt.Errorf("Failed to dequeue task: %w", err)
 		}
-		return nil, fmt.Errorf("Failed to dequeue task: %w", err)
+		data = result[1]
 	}
 
 	var job Job
 
-	if err := json.Unmarshal([]byte(result[1]), &job); err != nil {
+	if err := json.Unmarshal([]byte(data), &job); err != nil {
 		return nil, fmt.Errorf("Failed to unmarshal task: %w", err)
 	}
 
+	// a rescored job carries its old score, the index has the current one
+	if q.ranked() && !q.hostAware() {
+		job.BaseScore = indexScore
+	}
+
+	// the host-aware and ranked scripts already claimed the job in the URL
+	// index
+	if !q.hostAware() && !q.ranked() {
+		claimed, err := scripts.FrontierClaimScript.Run(
+			q.ctx,
+			q.Redis,
+			[]string{fmt.Sprintf(URLsKey, q.namespace)},
+			job.indexKey(),
+			job.ID,
+			job.BaseScore,
+		).Int()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to claim task: %w", err)
+		}
+		if claimed == 0 {
+			log.Printf("Dropped stale copy %s of %s", job.ID, job.URL)
+			return nil, nil
+		}
+	}
+
+	q.opts.Scheduler.Record(string(job.Priority), time.Since(job.LastEnqueuedAt))
+
 	processingKey := fmt.Sprintf(ProcessingKey, workerID)
 
 	job.Status = JOB_INFLIGHT
 	job.VisibilityStart = time.Now()
 	updatedJobData, _ := json.Marshal(job)
+	// no TTL, the job stays recoverable until it completes or is reclaimed
 	pipe := q.Redis.Pipeline()
-	pipe.Set(q.ctx, "job:"+job.ID, updatedJobData, ProcessingTimeout)
+	pipe.Set(q.ctx, "job:"+job.ID, updatedJobData, 0)
 	pipe.LPush(q.ctx, processingKey, job.ID)
-	pipe.Expire(q.ctx, processingKey, ProcessingTimeout)
 
-	_, err = pipe.Exec(q.ctx)
+	_, err := pipe.Exec(q.ctx)
 
 	if err != nil {
 		log.Printf("Warning: failed to move task to processing: %v", err)
@@ -120,6 +395,96 @@ func (q *Queue) Dequeue(queues []string, workerID string, timeout time.Duration)
 	return &job, nil
 }
 
+// polls the ranked set until a job comes up or timeout passes
+func (q *Queue) dequeueRanked(timeout time.Duration) (string, int, error) {
+	deadline := time.Now().Add(timeout)
+	keys := append(q.rankedKeys(), fmt.Sprintf(URLsKey, q.namespace))
+
+	for {
+		res, err := scripts.RankedDequeueScript.Run(q.ctx, q.Redis, keys).Slice()
+		if err != nil {
+			return "", 0, err
+		}
+
+		if len(res) == 2 {
+			data, _ := res[0].(string)
+			score, _ := res[1].(int64)
+			return data, int(score), nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return "", 0, nil
+		}
+		time.Sleep(min(rankedPollInterval, remaining))
+	}
+}
+
+// polls until a host is ready, sleeping until the earliest host's next fetch
+// time but never longer than hostPollInterval so new hosts are picked up
+func (q *Queue) dequeueReadyHost(frontKeys, priorities []string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	keys := append([]string{fmt.Sprintf(HostsKey, q.namespace), fmt.Sprintf(URLsKey, q.namespace), fmt.Sprintf(JobsKey, q.namespace)}, frontKeys...)
+	prefix := fmt.Sprintf(BackQueueKey, q.namespace, "")
+
+	mode := ""
+	if q.ranked() {
+		mode = "ranked"
+	}
+
+	for {
+		args := []any{
+			time.Now().UnixMilli(),
+			q.opts.ProcessingTimeout.Milliseconds(),
+			hostRefillBatch,
+			hostReadyTarget,
+			prefix,
+			mode,
+			hostScan,
+		}
+		for _, priority := range priorities {
+			args = append(args, priority)
+		}
+
+		res, err := scripts.HostDequeueScript.Run(q.ctx, q.Redis, keys, args...).Slice()
+		if err != nil {
+			return "", err
+		}
+
+		if data, _ := res[0].(string); data != "" {
+			return data, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return "", nil
+		}
+
+		sleep := min(hostPollInterval, remaining)
+		if wait, _ := res[1].(int64); wait >= 0 {
+			sleep = min(sleep, max(time.Duration(wait)*time.Millisecond, 10*time.Millisecond))
+		}
+
+		time.Sleep(sleep)
+	}
+}
+
+// releaseHost returns a leased host to the heap at its next fetch time.
+func (q *Queue) releaseHost(job *Job) error {
+	next := q.opts.NextFetch(q.ctx, job.Host)
+	if next.Before(time.Now()) {
+		next = time.Now()
+	}
+
+	return scripts.HostReleaseScript.Run(
+		q.ctx,
+		q.Redis,
+		[]string{fmt.Sprintf(HostsKey, q.namespace), fmt.Sprintf(BackQueueKey, q.namespace, job.Host)},
+		job.Host,
+		next.UnixMilli(),
+	).Err()
+}
+
 func (q *Queue) CompleteJob(job *Job, result *Result, workerID string) error {
 	processingKey := fmt.Sprintf(ProcessingKey, workerID)
 	resultKey := fmt.Sprintf(ResultsKey, job.ID)
@@ -133,14 +498,21 @@ func (q *Queue) CompleteJob(job *Job, result *Result, workerID string) error {
 
 	pipe.Set(q.ctx, resultKey, resultData, 24*time.Hour)
 
+	urlsKey := fmt.Sprintf(URLsKey, q.namespace)
+
 	if !result.Success {
 		job.RetryCount++
 
-		if job.RetryCount >= MAX_RETRIES {
+		if job.RetryCount >= q.opts.MaxRetries {
 			job.Status = JOB_DEAD
+			job.ErrorMsg = result.Error
+			job.FailedAt = result.FinishedAt
 			jobData, _ := json.Marshal(job)
 			failedKey := fmt.Sprintf(FailedKey, q.namespace)
 			pipe.LPush(q.ctx, failedKey, jobData)
+			pipe.HDel(q.ctx, urlsKey, job.indexKey())
+			// the last result lives as long as the dead-letter entry
+			pipe.Persist(q.ctx, resultKey)
 		} else {
 
 			job.BaseScore -= max(10, job.RetryCount*10)
@@ -149,77 +521,71 @@ func (q *Queue) CompleteJob(job *Job, result *Result, workerID string) error {
 				job.BaseScore = 10
 			}
 
-			job.Priority = ScoreToPriority(job.BaseScore)
-
 			delay := time.Second * time.Duration(1<<job.RetryCount)
-			retryTime := time.Now().Add(delay).Unix()
-
-			jobData, _ := json.Marshal(job)
-			retryKey := fmt.Sprintf(RetryKey, q.namespace)
 
-			pipe.ZAdd(q.ctx, retryKey, redis.Z{
-				Score:  float64(retryTime),
-				Member: string(jobData),
-			})
+			member, err := retryMember(job, time.Now().Add(delay))
+			if err != nil {
+				return err
+			}
 
+			pipe.ZAdd(q.ctx, fmt.Sprintf(RetryKey, q.namespace), member)
+			pipe.HSet(q.ctx, urlsKey, job.indexKey(), indexEntry(URL_RETRY, job.BaseScore, job.ID))
 		}
 	} else {
 		job.Status = JOB_DONE
+		pipe.HDel(q.ctx, urlsKey, job.indexKey())
 		log.Printf("Job %s for %s successfully completed!", job.ID, job.URL)
 	}
 
-	_, err := pipe.Exec(q.ctx)
-	return err
-}
-
-func (q *Queue) ProcessRetryJobs() error {
-	now := float64(time.Now().Unix())
-	retryKey := fmt.Sprintf(RetryKey, q.namespace)
-	results, err := q.Redis.ZRangeByScoreWithScores(q.ctx, retryKey, &redis.ZRangeBy{
-		Min: "0",
-		Max: fmt.Sprintf("%f", now),
-	}).Result()
-
-	if err != nil {
+	if _, err := pipe.Exec(q.ctx); err != nil {
 		return err
 	}
 
-	if len(results) == 0 {
-		return nil
+	if q.hostAware() {
+		return q.releaseHost(job)
 	}
 
-	pipe := q.Redis.Pipeline()
-
-	for _, result := range results {
-		jobData := result.Member.(string)
-
-		var job Job
-		if err = json.Unmarshal([]byte(jobData), &job); err != nil {
-			continue
-		}
-		waited := time.Since(job.LastEnqueuedAt)
-		job.BaseScore = ApplyAging(job.BaseScore, waited)
-		job.Priority = ScoreToPriority(job.BaseScore)
-		job.LastEnqueuedAt = time.Now()
-		job.Status = JOB_READY
-
-		newJobData, _ := json.Marshal(job)
+	return nil
+}
 
-		queueKey := fmt.Sprintf(ReadyKey, q.namespace, string(job.Priority))
+// ProcessRetryJobs moves due retries to the ready queues. Safe to run from
+// any number of processes at once.
+func (q *Queue) ProcessRetryJobs() error {
+	retryKey := fmt.Sprintf(RetryKey, q.namespace)
+	moved := 0
 
-		pipe.RPush(q.ctx, queueKey, newJobData)
-		pipe.ZRem(q.ctx, retryKey, jobData)
+	keys := []string{retryKey, fmt.Sprintf(URLsKey, q.namespace)}
+	if q.ranked() {
+		keys = append(keys, q.rankedKeys()...)
 	}
 
-	_, err = pipe.Exec(q.ctx)
+	for {
+		n, err := scripts.RetryMoverScript.Run(
+			q.ctx,
+			q.Redis,
+			keys,
+			time.Now().UnixMilli(),
+			fmt.Sprintf(ReadyKey, q.namespace, ""),
+			retryMoveBatch,
+			string(P2_NORMAL),
+			agingInterval.Milliseconds(),
+		).Int()
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
-		return err
+		moved += n
+		if n < retryMoveBatch {
+			break
+		}
 	}
 
-	log.Printf("Moved %d retry jobs for %s to queues", len(results), q.namespace)
+	if moved > 0 {
+		log.Printf("Moved %d retry jobs for %s to queues", moved, q.namespace)
+	}
 	return nil
 }
+
 func (q *Queue) ReapStaleProcessingJobs(workerID string) error {
 	processingKey := fmt.Sprintf(ProcessingKey, workerID)
 
@@ -241,7 +607,7 @@ func (q *Queue) ReapStaleProcessingJobs(workerID string) error {
 			continue
 		}
 
-		if now.Sub(job.VisibilityStart) < ProcessingTimeout {
+		if now.Sub(job.VisibilityStart) < q.opts.ProcessingTimeout {
 			continue
 		}
 
@@ -252,13 +618,20 @@ func (q *Queue) ReapStaleProcessingJobs(workerID string) error {
 
 		newJobData, _ := json.Marshal(job)
 
-		queueKey := fmt.Sprintf(ReadyKey, q.namespace, string(job.Priority))
-
 		pipe := q.Redis.Pipeline()
 
 		pipe.LRem(q.ctx, processingKey, 1, jobID)
 		pipe.Del(q.ctx, "job:"+jobID)
-		pipe.RPush(q.ctx, queueKey, newJobData)
+		if q.ranked() {
+			pipe.ZAdd(q.ctx, fmt.Sprintf(RankedKey, q.namespace), redis.Z{
+				Score:  rank(job.BaseScore, job.LastEnqueuedAt),
+				Member: job.ID,
+			})
+			pipe.HSet(q.ctx, fmt.Sprintf(JobsKey, q.namespace), job.ID, newJobData)
+		} else {
+			pipe.RPush(q.ctx, fmt.Sprintf(ReadyKey, q.namespace, string(job.Priority)), newJobData)
+		}
+		pipe.HSet(q.ctx, fmt.Sprintf(URLsKey, q.namespace), job.indexKey(), indexEntry(URL_READY, job.BaseScore, job.ID))
 
 		_, err = pipe.Exec(q.ctx)
 		if err != nil {
@@ -268,3 +641,107 @@ func (q *Queue) ReapStaleProcessingJobs(workerID string) error {
 
 	return nil
 }
+
+// Heartbeat registers the worker and keeps it alive for ttl.
+func (q *Queue) Heartbeat(workerID string, ttl time.Duration) error {
+	pipe := q.Redis.Pipeline()
+	pipe.SAdd(q.ctx, fmt.Sprintf(WorkersKey, q.namespace), workerID)
+	pipe.Set(q.ctx, fmt.Sprintf(HeartbeatKey, workerID), time.Now().Format(time.RFC3339), ttl)
+
+	_, err := pipe.Exec(q.ctx)
+	return err
+}
+
+// Deregister removes a stopping worker and requeues anything it still holds.
+func (q *Queue) Deregister(workerID string) error {
+	if err := q.Redis.Del(q.ctx, fmt.Sprintf(HeartbeatKey, workerID)).Err(); err != nil {
+		return err
+	}
+
+	_, err := q.reclaim(workerID)
+	return err
+}
+
+// ReclaimOrphans requeues the in-flight jobs of registered workers whose
+// heartbeat has expired, e.g. after a crash.
+func (q *Queue) ReclaimOrphans() (int, error) {
+	workerIDs, err := q.Redis.SMembers(q.ctx, fmt.Sprintf(WorkersKey, q.namespace)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+
+	for _, workerID := range workerIDs {
+		n, err := q.reclaim(workerID)
+		if err != nil {
+			return total, err
+		}
+
+		if n >= 0 {
+			log.Printf("Reclaimed %d orphaned jobs from dead worker %s", n, workerID)
+			total += n
+		}
+	}
+
+	return total, nil
+}
+
+func (q *Queue) reclaim(workerID string) (int, error) {
+	keys := []string{
+		fmt.Sprintf(WorkersKey, q.namespace),
+		fmt.Sprintf(HeartbeatKey, workerID),
+		fmt.Sprintf(ProcessingKey, workerID),
+		fmt.Sprintf(URLsKey, q.namespace),
+	}
+	if q.ranked() {
+		keys = append(keys, q.rankedKeys()...)
+	}
+
+	n, err := scripts.ReclaimOrphansScript.Run(
+		q.ctx,
+		q.Redis,
+		keys,
+		workerID,
+		fmt.Sprintf(ReadyKey, q.namespace, ""),
+		string(P2_NORMAL),
+		time.Now().UnixMilli(),
+		agingInterval.Milliseconds(),
+	).Int()
+
+	return n, err
+}
+
+type HostBacklog struct {
+	Host      string    `json:"host"`
+	Queued    int64     `json:"queued"`
+	NextFetch time.Time `json:"next_fetch"`
+}
+
+// Hosts lists the back queues of a host-aware queue, soonest host first.
+func (q *Queue) Hosts(ctx context.Context) ([]HostBacklog, error) {
+	entries, err := q.Redis.ZRangeWithScores(ctx, fmt.Sprintf(HostsKey, q.namespace), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := q.Redis.Pipeline()
+	lens := make([]*redis.IntCmd, len(entries))
+	for i, e := range entries {
+		lens[i] = pipe.LLen(ctx, fmt.Sprintf(BackQueueKey, q.namespace, e.Member.(string)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	backlog := make([]HostBacklog, len(entries))
+	for i, e := range entries {
+		backlog[i] = HostBacklog{
+			Host:      e.Member.(string),
+			Queued:    lens[i].Val(),
+			NextFetch: time.UnixMilli(int64(e.Score)),
+		}
+	}
+
+	return backlog, nil
+}
@@ -0,0 +1,91 @@
+package queues
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// testRedis connects to the Redis at TEST_REDIS_ADDR, the test is skipped
+// when it is not set. Keys are cleaned up under the returned namespace.
+func testRedis(t *testing.T) (*redis.Client, string) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR not set")
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	ctx := context.Background()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Fatalf("redis at %s: %v", addr, err)
+	}
+
+	namespace := "test:" + uuid.NewString()
+	t.Cleanup(func() {
+		iter := rdb.Scan(ctx, 0, namespace+":*", 0).Iterator()
+		for iter.Next(ctx) {
+			rdb.Del(ctx, iter.Val())
+		}
+		rdb.Close()
+	})
+
+	return rdb, namespace
+}
+
+func TestHostAwareSchedule(t *testing.T) {
+	rdb, namespace := testRedis(t)
+
+	cfg := DefaultScheduleConfig()
+	cfg.Weights = map[string]int{"critical": 3, "high": 0, "normal": 0, "low": 1}
+	cfg.MinShare = nil
+	scheduler, err := NewScheduler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.Scheduler = scheduler
+	opts.NextFetch = func(context.Context, string) time.Time { return time.Now() }
+	q := NewQueue(rdb, namespace, opts)
+
+	// critical goes in first, so it fills the host back queues on its own
+	for i := range 200 {
+		job := NewJob(fmt.Sprintf("https://c%d.example.com/", i))
+		job.BaseScore = 95
+		if _, err := q.Enqueue(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := range 200 {
+		job := NewJob(fmt.Sprintf("https://l%d.example.com/", i))
+		job.BaseScore = 0
+		if _, err := q.Enqueue(job); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts := map[PriorityStatus]int{}
+	for range 80 {
+		job, err := q.Dequeue(priorities, "worker", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job == nil {
+			t.Fatal("frontier ran dry")
+		}
+		counts[job.Priority]++
+
+		if err := q.CompleteJob(job, &Result{JobID: job.ID, Success: true}, "worker"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if counts[P0_CRITICAL] != 60 || counts[P3_LOW] != 20 {
+		t.Errorf("served critical %d and low %d times, want 60 and 20", counts[P0_CRITICAL], counts[P3_LOW])
+	}
+}
@@ -0,0 +1,78 @@
+package queues
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// RankedJob is a job waiting in a zset queue.
+type RankedJob struct {
+	URL       string  `json:"url"`
+	JobID     string  `json:"job_id"`
+	Score     int     `json:"score"`      // as enqueued or last rescored
+	AgedScore float64 `json:"aged_score"` // what it is ranked by now
+}
+
+// Top lists the n best jobs of a zset queue, best first. A list queue has
+// none.
+func (q *Queue) Top(ctx context.Context, n int64) ([]RankedJob, error) {
+	entries, err := q.Redis.ZRevRangeWithScores(ctx, fmt.Sprintf(RankedKey, q.namespace), 0, n-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.Member.(string)
+	}
+
+	jobs, err := q.Redis.HMGet(ctx, fmt.Sprintf(JobsKey, q.namespace), ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	top := make([]RankedJob, 0, len(entries))
+	var keys []string
+
+	for i, e := range entries {
+		data, ok := jobs[i].(string)
+		if !ok {
+			continue
+		}
+
+		var job Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			continue
+		}
+
+		keys = append(keys, job.indexKey())
+		top = append(top, RankedJob{
+			URL:       job.URL,
+			JobID:     job.ID,
+			Score:     job.BaseScore,
+			AgedScore: RankedScore(e.Score, now),
+		})
+	}
+
+	// a rescored job keeps its old score in its data, the index is current
+	if len(top) > 0 {
+		entries, err := q.Redis.HMGet(ctx, fmt.Sprintf(URLsKey, q.namespace), keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, raw := range entries {
+			entry, _ := raw.(string)
+			if queued, ok := parseIndexEntry(entry); ok && queued.JobID == top[i].JobID {
+				top[i].Score = queued.Score
+			}
+		}
+	}
+
+	return top, nil
+}
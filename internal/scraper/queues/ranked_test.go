@@ -0,0 +1,33 @@
+package queues
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRankAgesContinuously(t *testing.T) {
+	now := time.Now()
+
+	// a job that waited 10 minutes has gained 20 points
+	older := rank(40, now.Add(-10*time.Minute))
+	newer := rank(55, now)
+	if older <= newer {
+		t.Errorf("40 after 10m (%v) should outrank a fresh 55 (%v)", older, newer)
+	}
+	if newer >= rank(65, now.Add(-10*time.Minute)) {
+		t.Error("a fresh 55 should not outrank 65 after 10m")
+	}
+
+	if got := RankedScore(older, now); math.Abs(got-60) > 1e-6 {
+		t.Errorf("aged score = %v, want 60", got)
+	}
+
+	// the bonus keeps growing where ApplyAging stops at 30
+	if got := RankedScore(rank(0, now.Add(-time.Hour)), now); math.Abs(got-120) > 1e-6 {
+		t.Errorf("aged score after an hour = %v, want 120", got)
+	}
+	if got := ApplyAging(0, time.Hour); got != 30 {
+		t.Errorf("ApplyAging after an hour = %d, want 30", got)
+	}
+}
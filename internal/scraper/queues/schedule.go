@@ -0,0 +1,304 @@
+package queues
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	SCHEDULE_STRICT   = "strict"
+	SCHEDULE_WEIGHTED = "weighted"
+)
+
+// ScheduleConfig decides how dequeues are shared between priority levels.
+// Strict always drains the highest priority first. Weighted shares dequeues
+// by Weights with smooth weighted round-robin, and a priority that got less
+// than its MinShare of the last Window dequeues is served first. Either way
+// an empty priority never holds up the others.
+type ScheduleConfig struct {
+	Policy   string             `json:"policy" yaml:"policy"`
+	Weights  map[string]int     `json:"weights" yaml:"weights"`     // by priority, 0 only serves it when the others are empty
+	MinShare map[string]float64 `json:"min_share" yaml:"min_share"` // by priority, fraction of recent dequeues
+	Window   int                `json:"window" yaml:"window"`       // recent dequeues shares, rates and waits are measured over
+}
+
+func DefaultScheduleConfig() ScheduleConfig {
+	return ScheduleConfig{
+		Policy: SCHEDULE_WEIGHTED,
+		Weights: map[string]int{
+			string(P0_CRITICAL): 8,
+			string(P1_HIGH):     4,
+			string(P2_NORMAL):   2,
+			string(P3_LOW):      1,
+		},
+		MinShare: map[string]float64{
+			string(P3_LOW): 0.05,
+		},
+		Window: 1000,
+	}
+}
+
+func isPriority(name string) bool {
+	switch PriorityStatus(name) {
+	case P0_CRITICAL, P1_HIGH, P2_NORMAL, P3_LOW:
+		return true
+	}
+	return false
+}
+
+func (c ScheduleConfig) Validate() error {
+	switch c.Policy {
+	case SCHEDULE_STRICT, SCHEDULE_WEIGHTED:
+	default:
+		return fmt.Errorf("unknown policy %q", c.Policy)
+	}
+
+	if c.Window <= 0 {
+		return fmt.Errorf("window must be positive, got %d", c.Window)
+	}
+
+	total := 0
+	for name, w := range c.Weights {
+		if !isPriority(name) {
+			return fmt.Errorf("weight for unknown priority %q", name)
+		}
+		if w < 0 {
+			return fmt.Errorf("weight for %s must not be negative", name)
+		}
+		total += w
+	}
+	if c.Policy == SCHEDULE_WEIGHTED && total == 0 {
+		return fmt.Errorf("weighted policy needs a positive weight")
+	}
+
+	shares := 0.0
+	for name, share := range c.MinShare {
+		if !isPriority(name) {
+			return fmt.Errorf("min_share for unknown priority %q", name)
+		}
+		if share < 0 || share >= 1 {
+			return fmt.Errorf("min_share for %s must be in [0, 1), got %v", name, share)
+		}
+		shares += share
+	}
+	if shares >= 1 {
+		return fmt.Errorf("min_share adds up to %v, must be below 1", shares)
+	}
+
+	return nil
+}
+
+type dequeueRecord struct {
+	priority string
+	at       time.Time
+	wait     time.Duration
+}
+
+// Scheduler orders the priority levels for each dequeue of one queue. It
+// only sees the dequeues of its own process, so with several scrapers every
+// process shares fairly and so does the fleet as a whole. A nil *Scheduler
+// keeps the strict order.
+type Scheduler struct {
+	mu  sync.Mutex
+	cfg ScheduleConfig
+
+	current map[string]int // smooth weighted round-robin state
+	recent  []dequeueRecord
+	next    int
+	totals  map[string]int64
+}
+
+func NewScheduler(cfg ScheduleConfig) (*Scheduler, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Scheduler{
+		cfg:     cfg,
+		current: map[string]int{},
+		recent:  make([]dequeueRecord, 0, cfg.Window),
+		totals:  map[string]int64{},
+	}, nil
+}
+
+// Order returns the priorities in the order the next dequeue should try
+// them. Priorities come in from highest to lowest.
+func (s *Scheduler) Order(priorities []string) []string {
+	if s == nil || s.cfg.Policy == SCHEDULE_STRICT {
+		return priorities
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order := make([]string, 0, len(priorities))
+
+	order = append(order, s.starved(priorities)...)
+
+	if pick := s.pick(priorities); pick != "" && !slices.Contains(order, pick) {
+		order = append(order, pick)
+	}
+
+	for _, p := range priorities {
+		if !slices.Contains(order, p) {
+			order = append(order, p)
+		}
+	}
+
+	return order
+}
+
+// starved lists the priorities owed at least one dequeue to reach their
+// minimum share of the window, most owed first.
+func (s *Scheduler) starved(priorities []string) []string {
+	n := len(s.recent)
+	counts := s.windowCounts()
+
+	type owed struct {
+		priority string
+		deficit  float64
+	}
+	var owing []owed
+
+	for _, p := range priorities {
+		if d := s.cfg.MinShare[p]*float64(n) - float64(counts[p]); d >= 1 {
+			owing = append(owing, owed{p, d})
+		}
+	}
+
+	slices.SortStableFunc(owing, func(a, b owed) int {
+		switch {
+		case a.deficit > b.deficit:
+			return -1
+		case a.deficit < b.deficit:
+			return 1
+		}
+		return 0
+	})
+
+	names := make([]string, len(owing))
+	for i, o := range owing {
+		names[i] = o.priority
+	}
+	return names
+}
+
+// pick is one step of smooth weighted round-robin: every priority gains its
+// weight, the richest is picked and pays the total. Over any total dequeues
+// each priority is picked exactly weight times, interleaved.
+func (s *Scheduler) pick(priorities []string) string {
+	total := 0
+	best := ""
+
+	for _, p := range priorities {
+		w := s.cfg.Weights[p]
+		if w <= 0 {
+			continue
+		}
+		total += w
+		s.current[p] += w
+		if best == "" || s.current[p] > s.current[best] {
+			best = p
+		}
+	}
+
+	if best != "" {
+		s.current[best] -= total
+	}
+	return best
+}
+
+// Record notes a dequeue from priority after the job waited for wait.
+func (s *Scheduler) Record(priority string, wait time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := dequeueRecord{priority: priority, at: time.Now(), wait: max(wait, 0)}
+	if len(s.recent) < s.cfg.Window {
+		s.recent = append(s.recent, rec)
+	} else {
+		s.recent[s.next] = rec
+		s.next = (s.next + 1) % s.cfg.Window
+	}
+	s.totals[priority]++
+}
+
+func (s *Scheduler) windowCounts() map[string]int {
+	counts := map[string]int{}
+	for _, r := range s.recent {
+		counts[r.priority]++
+	}
+	return counts
+}
+
+type PriorityStats struct {
+	Priority string        `json:"priority"`
+	Weight   int           `json:"weight"`
+	MinShare float64       `json:"min_share,omitempty"`
+	Dequeued int64         `json:"dequeued"`
+	Share    float64       `json:"share"`    // of the window
+	Rate     float64       `json:"rate"`     // dequeues per second over the window
+	AvgWait  time.Duration `json:"avg_wait"` // from enqueue to dequeue, over the window
+	MaxWait  time.Duration `json:"max_wait"`
+}
+
+// Stats reports dequeue rates and wait times per priority, highest first.
+func (s *Scheduler) Stats() []PriorityStats {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var oldest time.Time
+	waits := map[string]time.Duration{}
+	maxWaits := map[string]time.Duration{}
+	for _, r := range s.recent {
+		if oldest.IsZero() || r.at.Before(oldest) {
+			oldest = r.at
+		}
+		waits[r.priority] += r.wait
+		maxWaits[r.priority] = max(maxWaits[r.priority], r.wait)
+	}
+	span := time.Since(oldest).Seconds()
+	counts := s.windowCounts()
+
+	var out []PriorityStats
+	for _, p := range []PriorityStatus{P0_CRITICAL, P1_HIGH, P2_NORMAL, P3_LOW} {
+		name := string(p)
+		st := PriorityStats{
+			Priority: name,
+			Weight:   s.cfg.Weights[name],
+			MinShare: s.cfg.MinShare[name],
+			Dequeued: s.totals[name],
+			MaxWait:  maxWaits[name],
+		}
+		if n := counts[name]; n > 0 {
+			st.Share = float64(n) / float64(len(s.recent))
+			st.AvgWait = waits[name] / time.Duration(n)
+			if span > 0 {
+				st.Rate = float64(n) / span
+			}
+		}
+		out = append(out, st)
+	}
+
+	return out
+}
+
+// LogReport writes the per-priority stats to the log, e.g. at shutdown.
+func (s *Scheduler) LogReport(name string) {
+	for _, st := range s.Stats() {
+		log.Printf("%s %-8s %6d dequeued, %5.1f%% share, %.2f/s, avg wait %v, max wait %v",
+			name, st.Priority, st.Dequeued, 100*st.Share, st.Rate,
+			st.AvgWait.Round(time.Millisecond), st.MaxWait.Round(time.Millisecond))
+	}
+}
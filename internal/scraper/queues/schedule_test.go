@@ -0,0 +1,115 @@
+package queues
+
+import (
+	"testing"
+	"time"
+)
+
+var priorities = []string{"critical", "high", "normal", "low"}
+
+func TestWeightedShares(t *testing.T) {
+	cfg := DefaultScheduleConfig()
+	cfg.MinShare = nil
+	s, err := NewScheduler(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// with every priority backlogged the first in the order is served
+	counts := map[string]int{}
+	for range 150 {
+		p := s.Order(priorities)[0]
+		counts[p]++
+		s.Record(p, 0)
+	}
+
+	want := map[string]int{"critical": 80, "high": 40, "normal": 20, "low": 10}
+	for p, n := range want {
+		if counts[p] != n {
+			t.Errorf("%s served %d times, want %d", p, counts[p], n)
+		}
+	}
+
+	// the rest of the order falls back to strict priority
+	order := s.Order(priorities)
+	if len(order) != 4 {
+		t.Fatalf("order %v should hold every priority once", order)
+	}
+}
+
+func TestMinShare(t *testing.T) {
+	cfg := DefaultScheduleConfig()
+	cfg.Weights = map[string]int{"critical": 1}
+	cfg.MinShare = map[string]float64{"low": 0.1}
+	cfg.Window = 100
+	s, _ := NewScheduler(cfg)
+
+	counts := map[string]int{}
+	for range 1000 {
+		p := s.Order(priorities)[0]
+		counts[p]++
+		s.Record(p, 0)
+	}
+
+	if counts["low"] < 90 || counts["low"] > 110 {
+		t.Errorf("low served %d of 1000 times, want about 100", counts["low"])
+	}
+}
+
+func TestStrictOrder(t *testing.T) {
+	cfg := DefaultScheduleConfig()
+	cfg.Policy = SCHEDULE_STRICT
+	s, _ := NewScheduler(cfg)
+
+	for range 10 {
+		if order := s.Order(priorities); order[0] != "critical" || order[3] != "low" {
+			t.Fatalf("strict order = %v", order)
+		}
+	}
+
+	var off *Scheduler
+	if order := off.Order(priorities); order[0] != "critical" {
+		t.Errorf("nil scheduler order = %v", order)
+	}
+}
+
+func TestScheduleStats(t *testing.T) {
+	s, _ := NewScheduler(DefaultScheduleConfig())
+	s.Record("high", 2*time.Second)
+	s.Record("high", 4*time.Second)
+	s.Record("low", time.Minute)
+
+	stats := s.Stats()
+	if len(stats) != 4 || stats[1].Priority != "high" {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	high, low := stats[1], stats[3]
+	if high.Dequeued != 2 || high.AvgWait != 3*time.Second || high.MaxWait != 4*time.Second {
+		t.Errorf("high stats %+v", high)
+	}
+	if low.Share < 0.33 || low.Share > 0.34 {
+		t.Errorf("low share = %v, want 1/3", low.Share)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	cases := []func(c *ScheduleConfig){
+		func(c *ScheduleConfig) { c.Policy = "fifo" },
+		func(c *ScheduleConfig) { c.Window = 0 },
+		func(c *ScheduleConfig) { c.Weights = map[string]int{"urgent": 1} },
+		func(c *ScheduleConfig) { c.Weights = map[string]int{"low": 0} },
+		func(c *ScheduleConfig) { c.MinShare = map[string]float64{"low": 0.6, "normal": 0.5} },
+	}
+
+	for i, mutate := range cases {
+		cfg := DefaultScheduleConfig()
+		mutate(&cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+
+	if err := DefaultScheduleConfig().Validate(); err != nil {
+		t.Errorf("default config: %v", err)
+	}
+}
@@ -1,8 +1,6 @@
 package queues
 
 import (
-	"net/url"
-	"strings"
 	"time"
 )
 
@@ -15,6 +13,10 @@ type UrlMeta struct {
 	InboundLinks   int       `json:"inbound_links"`
 	IsBlog         bool      `json:"is_blog"`
 	FirstSeenAt    time.Time `json:"first_seen_at"`
+	Seed           string    `json:"seed,omitempty"`
+	Directives     string    `json:"directives,omitempty"`
+	Relevance      float64   `json:"relevance,omitempty"`     // predicted by the focus classifier
+	HasRelevance   bool      `json:"has_relevance,omitempty"` // whether Relevance was predicted at all
 }
 
 func NewUrlMeta(depth int) *UrlMeta {
@@ -30,65 +32,6 @@ func NewUrlMeta(depth int) *UrlMeta {
 	}
 }
 
-func ClassifyURL(u *url.URL, meta *UrlMeta) {
-
-	path := strings.ToLower(u.Path)
-
-	if strings.Contains(path, "/docs/") ||
-		strings.Contains(path, "/guide/") ||
-		strings.Contains(path, "/tutorial/") {
-		meta.IsDocs = true
-	}
-
-	if strings.Contains(path, "/api/") ||
-		strings.Contains(path, "/reference/") {
-		meta.IsApi = true
-	}
-
-	if strings.Contains(path, "/blog/") ||
-		strings.Contains(path, "/post/") {
-		meta.IsBlog = true
-	}
-
-	if u.RawQuery != "" {
-		meta.HasQueryParams = true
-	}
-}
-
-func ScoreDevURL(u *UrlMeta) int {
-	var score int
-	score = 0
-
-	age := min(int(time.Since(u.FirstSeenAt).Minutes()), 30)
-	score += (30 - age) / 2
-
-	if u.Depth <= 2 {
-		score += 30
-	}
-
-	if !u.HasQueryParams {
-		score += 10
-	}
-
-	if u.IsDocs {
-		score += 40
-	}
-	if u.IsApi {
-		score += 35
-	}
-	if u.HasCodeBlocks {
-		score += 30
-	}
-
-	score += min(u.InboundLinks*3, 30)
-
-	if u.IsBlog {
-		score -= 15
-	}
-
-	return score
-}
-
 func ScoreToPriority(score int) PriorityStatus {
 	switch {
 	case score >= 90:
@@ -102,7 +45,23 @@ func ScoreToPriority(score int) PriorityStatus {
 	}
 }
 
+// a waiting job gains a point per agingInterval
+const agingInterval = 30 * time.Second
+
 func ApplyAging(baseScore int, waited time.Duration) int {
-	agingFactor := min(int(waited.Seconds()/30), 30)
+	agingFactor := min(int(waited/agingInterval), 30)
 	return baseScore + agingFactor
 }
+
+// rank orders a zset queue. Ranking by the score less one point per aging
+// interval since the epoch is the same as ranking by the score plus one point
+// per interval waited, so jobs age continuously without being rewritten.
+// Unlike ApplyAging the bonus has no cap.
+func rank(score int, enqueued time.Time) float64 {
+	return float64(score) - float64(enqueued.UnixMilli())/float64(agingInterval.Milliseconds())
+}
+
+// RankedScore is the aged score of a job with the given rank at now.
+func RankedScore(rank float64, now time.Time) float64 {
+	return rank + float64(now.UnixMilli())/float64(agingInterval.Milliseconds())
+}
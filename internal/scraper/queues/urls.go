@@ -0,0 +1,75 @@
+package queues
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// URLState is where a queued URL currently sits in a queue.
+type URLState string
+
+const (
+	URL_READY      URLState = "ready"
+	URL_RETRY      URLState = "retry"
+	URL_PROCESSING URLState = "processing"
+)
+
+// QueuedURL is the entry of a URL in the queued-URL index <ns>:urls. Only the
+// job named here is live; other copies of the URL are dropped when dequeued.
+type QueuedURL struct {
+	URL   string   `json:"url"`
+	State URLState `json:"state"`
+	Score int      `json:"score"`
+	JobID string   `json:"job_id"`
+}
+
+// LookupURL says which state a URL is in, or nil when it is not queued.
+func (q *Queue) LookupURL(ctx context.Context, rawUrl string) (*QueuedURL, error) {
+	entry, err := q.Redis.HGet(ctx, fmt.Sprintf(URLsKey, q.namespace), rawUrl).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	queued, ok := parseIndexEntry(entry)
+	if !ok {
+		return nil, fmt.Errorf("malformed url index entry %q for %s", entry, rawUrl)
+	}
+	queued.URL = rawUrl
+
+	return queued, nil
+}
+
+// indexEntry encodes an entry the way the queue scripts read it.
+func indexEntry(state URLState, score int, jobID string) string {
+	return fmt.Sprintf("%s|%d|%s", state, score, jobID)
+}
+
+func parseIndexEntry(entry string) (*QueuedURL, bool) {
+	state, rest, ok := strings.Cut(entry, "|")
+	if !ok {
+		return nil, false
+	}
+	rawScore, jobID, ok := strings.Cut(rest, "|")
+	if !ok {
+		return nil, false
+	}
+	score, err := strconv.Atoi(rawScore)
+	if err != nil {
+		return nil, false
+	}
+
+	switch URLState(state) {
+	case URL_READY, URL_RETRY, URL_PROCESSING:
+	default:
+		return nil, false
+	}
+
+	return &QueuedURL{State: URLState(state), Score: score, JobID: jobID}, true
+}
@@ -0,0 +1,39 @@
+package queues
+
+import "testing"
+
+func TestIndexEntryRoundTrip(t *testing.T) {
+	entry := indexEntry(URL_RETRY, -20, "6f1c2a0e-job")
+	if entry != "retry|-20|6f1c2a0e-job" {
+		t.Fatalf("indexEntry = %q", entry)
+	}
+
+	queued, ok := parseIndexEntry(entry)
+	if !ok {
+		t.Fatal("expected entry to parse")
+	}
+	if queued.State != URL_RETRY || queued.Score != -20 || queued.JobID != "6f1c2a0e-job" {
+		t.Errorf("unexpected entry %+v", queued)
+	}
+}
+
+func TestParseIndexEntryRejectsMalformed(t *testing.T) {
+	for _, entry := range []string{"", "ready", "ready|x|id", "queued|1|id", "ready|1"} {
+		if _, ok := parseIndexEntry(entry); ok {
+			t.Errorf("parseIndexEntry(%q) should fail", entry)
+		}
+	}
+}
+
+func TestIndexKey(t *testing.T) {
+	job := NewJob("https://go.dev/doc")
+	if got := job.indexKey(); got != "https://go.dev/doc" {
+		t.Errorf("indexKey = %q, want the url", got)
+	}
+
+	// parse jobs are never merged, each is indexed by its id
+	job.IndexKey = "job:" + job.ID
+	if got := job.indexKey(); got != "job:"+job.ID {
+		t.Errorf("indexKey = %q, want the job id", got)
+	}
+}
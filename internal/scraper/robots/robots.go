@@ -0,0 +1,217 @@
+package robots
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxSize is how much of a robots.txt is parsed, RFC 9309 asks for at least 500 KiB.
+const MaxSize = 500 * 1024
+
+type Rule struct {
+	Pattern string `json:"pattern"`
+	Allow   bool   `json:"allow"`
+}
+
+type Group struct {
+	Agents     []string      `json:"agents"`
+	Rules      []Rule        `json:"rules"`
+	CrawlDelay time.Duration `json:"crawl_delay"`
+}
+
+type Robots struct {
+	Groups []*Group
+}
+
+// ProductToken turns a user agent like "Dev_Search/1.0" into the token that
+// robots.txt groups are matched against.
+func ProductToken(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	return strings.ToLower(strings.TrimSpace(token))
+}
+
+func Parse(body []byte) *Robots {
+	if len(body) > MaxSize {
+		body = body[:MaxSize]
+	}
+
+	r := &Robots{}
+
+	var current *Group
+	inAgentLines := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxSize)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// consecutive user-agent lines share one group
+			if !inAgentLines {
+				current = &Group{}
+				r.Groups = append(r.Groups, current)
+			}
+			current.Agents = append(current.Agents, ProductToken(value))
+			inAgentLines = true
+
+		case "allow", "disallow":
+			inAgentLines = false
+			if current == nil {
+				continue
+			}
+			// an empty disallow matches nothing
+			if value == "" {
+				continue
+			}
+			current.Rules = append(current.Rules, Rule{Pattern: value, Allow: key == "allow"})
+
+		case "crawl-delay":
+			inAgentLines = false
+			if current == nil {
+				continue
+			}
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				current.CrawlDelay = time.Duration(secs * float64(time.Second))
+			}
+
+		default:
+			// sitemap and unknown records don't end the user-agent block
+		}
+	}
+
+	return r
+}
+
+// Match merges every group naming the product token, falling back to the
+// groups for "*". An empty group allows everything.
+func (r *Robots) Match(token string) *Group {
+	token = strings.ToLower(token)
+
+	merged := &Group{}
+	found := false
+
+	collect := func(want string) {
+		for _, g := range r.Groups {
+			for _, agent := range g.Agents {
+				if agent == want {
+					merged.Agents = append(merged.Agents, agent)
+					merged.Rules = append(merged.Rules, g.Rules...)
+					merged.CrawlDelay = max(merged.CrawlDelay, g.CrawlDelay)
+					found = true
+					break
+				}
+			}
+		}
+	}
+
+	collect(token)
+	if !found {
+		collect("*")
+	}
+
+	return merged
+}
+
+// Test reports whether the path (with query) may be crawled. The longest
+// matching rule wins and allow wins ties. The matched rule is nil when no rule applied.
+func (g *Group) Test(u *url.URL) (bool, *Rule) {
+	target := u.EscapedPath()
+	if target == "" {
+		target = "/"
+	}
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+
+	if target == "/robots.txt" {
+		return true, nil
+	}
+
+	var best *Rule
+	bestLen := -1
+
+	for i := range g.Rules {
+		rule := &g.Rules[i]
+		if !matchPattern(normalizePattern(rule.Pattern), target) {
+			continue
+		}
+
+		l := len(rule.Pattern)
+		if l > bestLen || (l == bestLen && rule.Allow && !best.Allow) {
+			best = rule
+			bestLen = l
+		}
+	}
+
+	if best == nil {
+		return true, nil
+	}
+
+	return best.Allow, best
+}
+
+// patterns are compared in their percent-encoded form, like the url path
+func normalizePattern(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		if r > 127 {
+			b.WriteString(url.PathEscape(string(r)))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// matchPattern supports the "*" wildcard and a trailing "$" anchor.
+func matchPattern(pattern, target string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+
+	if !strings.HasPrefix(target, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+
+	if len(parts) == 1 {
+		return !anchored || pos == len(target)
+	}
+
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		last := i == len(parts)-1
+
+		if last && anchored {
+			return strings.HasSuffix(target[pos:], part)
+		}
+
+		idx := strings.Index(target[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+
+	return true
+}
@@ -0,0 +1,140 @@
+package robots
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestProductToken(t *testing.T) {
+	cases := map[string]string{
+		"Dev_Search/1.0":   "dev_search",
+		"  Googlebot ":     "googlebot",
+		"*":                "*",
+		"ExampleBot/2 (x)": "examplebot",
+	}
+
+	for in, want := range cases {
+		if got := ProductToken(in); got != want {
+			t.Errorf("ProductToken(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMatchGroups(t *testing.T) {
+	r := Parse([]byte(`
+# comment
+User-agent: dev
+Disallow: /
+
+User-agent: Dev_Search
+User-agent: other
+Disallow: /private
+Crawl-delay: 2
+
+Sitemap: https://example.com/sitemap.xml
+
+User-agent: dev_search/1.0
+Allow: /private/ok
+
+User-agent: *
+Disallow: /everything
+`))
+
+	g := r.Match("dev_search")
+
+	if len(g.Rules) != 2 {
+		t.Fatalf("expected merged rules from both groups, got %+v", g.Rules)
+	}
+	if g.CrawlDelay != 2*time.Second {
+		t.Errorf("crawl delay = %v, want 2s", g.CrawlDelay)
+	}
+
+	// "dev" is a different product token, it must not match by prefix
+	if allowed, _ := g.Test(mustURL(t, "https://example.com/docs")); !allowed {
+		t.Errorf("/docs should be allowed for dev_search")
+	}
+
+	fallback := r.Match("unknown")
+	if allowed, _ := fallback.Test(mustURL(t, "https://example.com/everything/x")); allowed {
+		t.Errorf("unknown agents should use the * group")
+	}
+}
+
+func TestLongestMatchWins(t *testing.T) {
+	g := Parse([]byte(`
+User-agent: *
+Disallow: /docs
+Allow: /docs/public
+Disallow: /*.pdf$
+Allow: /page
+Disallow: /page
+Disallow: /search?q=
+`)).Match("dev_search")
+
+	cases := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/docs/private", false},
+		{"/docs/public/intro", true},
+		{"/docs/public/file.pdf", true},
+		{"/files/file.pdf", false},
+		{"/files/file.pdf?x=1", true},
+		{"/page", true},
+		{"/search?q=go", false},
+		{"/search", true},
+		{"/robots.txt", true},
+		{"/", true},
+	}
+
+	for _, c := range cases {
+		if allowed, _ := g.Test(mustURL(t, "https://example.com"+c.path)); allowed != c.allowed {
+			t.Errorf("%s: allowed = %v, want %v", c.path, allowed, c.allowed)
+		}
+	}
+}
+
+func TestEmptyDisallowAndNoGroup(t *testing.T) {
+	g := Parse([]byte("User-agent: *\nDisallow:\n")).Match("dev_search")
+	if allowed, rule := g.Test(mustURL(t, "https://example.com/a")); !allowed || rule != nil {
+		t.Errorf("empty disallow must allow everything")
+	}
+
+	g = Parse([]byte("Disallow: /\n")).Match("dev_search")
+	if allowed, _ := g.Test(mustURL(t, "https://example.com/a")); !allowed {
+		t.Errorf("rules outside a group must be ignored")
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern, target string
+		want            bool
+	}{
+		{"/a", "/abc", true},
+		{"/a$", "/a", true},
+		{"/a$", "/ab", false},
+		{"/*/b", "/x/y/b", true},
+		{"/*b$", "/x/bb", true},
+		{"/*b$", "/x/bc", false},
+		{"*", "/anything", true},
+		{"/a*c*e", "/abcde", true},
+		{"/a*c*e", "/abde", false},
+	}
+
+	for _, c := range cases {
+		if got := matchPattern(c.pattern, c.target); got != c.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", c.pattern, c.target, got, c.want)
+		}
+	}
+}
+
+func mustURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
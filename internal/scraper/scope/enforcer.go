@@ -0,0 +1,116 @@
+package scope
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scripts"
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/stats"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	HostPagesKey = "scope:hostpages"
+	SeedHostsKey = "scope:seedhosts"
+	NewHostsKey  = "scope:run:%s:newhosts"
+	SkippedKey   = "scope:skipped"
+	SkipLogKey   = "scope:skiplog"
+
+	skipLogSize = 1000
+)
+
+type Enforcer struct {
+	policy *Policy
+	rdb    *redis.Client
+	runID  string
+}
+
+type SkipEntry struct {
+	URL    string    `json:"url"`
+	Reason Reason    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+func NewEnforcer(rdb *redis.Client, policy *Policy, runID string) (*Enforcer, error) {
+	if policy == nil {
+		policy = &Policy{}
+	}
+
+	if err := policy.Compile(); err != nil {
+		return nil, err
+	}
+
+	return &Enforcer{
+		policy: policy,
+		rdb:    rdb,
+		runID:  runID,
+	}, nil
+}
+
+// seed hosts never count towards the new host quota
+func (e *Enforcer) RegisterSeeds(ctx context.Context, seeds []string) error {
+	hosts := make([]any, 0, len(seeds))
+
+	for _, s := range seeds {
+		parsed, err := url.Parse(s)
+		if err != nil || parsed.Hostname() == "" {
+			continue
+		}
+		hosts = append(hosts, strings.ToLower(parsed.Hostname()))
+	}
+
+	if len(hosts) == 0 {
+		return nil
+	}
+
+	return e.rdb.SAdd(ctx, SeedHostsKey, hosts...).Err()
+}
+
+func (e *Enforcer) Check(u *url.URL, depth int, seed string) Reason {
+	return e.policy.Check(u, depth, seed)
+}
+
+// Admit applies the shared per-host and per-run quotas and reserves a slot for the url.
+func (e *Enforcer) Admit(ctx context.Context, u *url.URL) (Reason, error) {
+	if e.policy.MaxPagesPerHost <= 0 && e.policy.MaxNewHosts <= 0 {
+		return ALLOWED, nil
+	}
+
+	res, err := scripts.ScopeAdmissionScript.Run(
+		ctx,
+		e.rdb,
+		[]string{HostPagesKey, SeedHostsKey, fmt.Sprintf(NewHostsKey, e.runID)},
+		strings.ToLower(u.Hostname()),
+		e.policy.MaxPagesPerHost,
+		e.policy.MaxNewHosts,
+	).Text()
+
+	if err != nil {
+		return ALLOWED, fmt.Errorf("scope admission failed: %w", err)
+	}
+
+	return Reason(res), nil
+}
+
+func (e *Enforcer) RecordSkip(ctx context.Context, rawUrl string, reason Reason) {
+	stats.IncrementScopeSkip()
+
+	entry, err := json.Marshal(SkipEntry{URL: rawUrl, Reason: reason, At: time.Now()})
+	if err != nil {
+		return
+	}
+
+	pipe := e.rdb.Pipeline()
+	pipe.HIncrBy(ctx, SkippedKey, string(reason), 1)
+	pipe.LPush(ctx, SkipLogKey, entry)
+	pipe.LTrim(ctx, SkipLogKey, 0, skipLogSize-1)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Printf("[Scope] Failed to record skip for %s: %v", rawUrl, err)
+	}
+}
@@ -0,0 +1,161 @@
+package scope
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+type Reason string
+
+const (
+	ALLOWED           Reason = ""
+	HOST_DENIED       Reason = "host_denied"
+	HOST_NOT_ALLOWED  Reason = "host_not_allowed"
+	PATH_EXCLUDED     Reason = "path_excluded"
+	PATH_NOT_INCLUDED Reason = "path_not_included"
+	MAX_DEPTH         Reason = "max_depth"
+	HOST_PAGE_CAP     Reason = "host_page_cap"
+	NEW_HOST_CAP      Reason = "new_host_cap"
+)
+
+// Policy describes which discovered URLs may enter the frontier.
+// Zero values mean "no restriction".
+type Policy struct {
+	AllowHosts      []string       `json:"allow_hosts" yaml:"allow_hosts"`
+	DenyHosts       []string       `json:"deny_hosts" yaml:"deny_hosts"`
+	IncludePaths    []string       `json:"include_paths" yaml:"include_paths"`
+	ExcludePaths    []string       `json:"exclude_paths" yaml:"exclude_paths"`
+	MaxDepth        int            `json:"max_depth" yaml:"max_depth"`
+	SeedMaxDepth    map[string]int `json:"seed_max_depth" yaml:"seed_max_depth"`
+	MaxPagesPerHost int            `json:"max_pages_per_host" yaml:"max_pages_per_host"`
+	MaxNewHosts     int            `json:"max_new_hosts" yaml:"max_new_hosts"`
+
+	includeRx []*regexp.Regexp
+	excludeRx []*regexp.Regexp
+}
+
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("Can't read scope policy: %w", err)
+	}
+
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("Can't parse scope policy: %w", err)
+	}
+
+	if err := p.Compile(); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+func (p *Policy) Compile() error {
+	p.includeRx = p.includeRx[:0]
+	p.excludeRx = p.excludeRx[:0]
+
+	for _, expr := range p.IncludePaths {
+		rx, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid include path %q: %w", expr, err)
+		}
+		p.includeRx = append(p.includeRx, rx)
+	}
+
+	for _, expr := range p.ExcludePaths {
+		rx, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid exclude path %q: %w", expr, err)
+		}
+		p.excludeRx = append(p.excludeRx, rx)
+	}
+
+	for _, pattern := range append(p.AllowHosts, p.DenyHosts...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid host pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// Check runs the static rules of the policy: host lists, path regexes and depth.
+// Quotas that need shared state are handled by the Enforcer.
+func (p *Policy) Check(u *url.URL, depth int, seed string) Reason {
+	host := strings.ToLower(u.Hostname())
+
+	if matchAnyHost(p.DenyHosts, host) {
+		return HOST_DENIED
+	}
+
+	if len(p.AllowHosts) > 0 && !matchAnyHost(p.AllowHosts, host) {
+		return HOST_NOT_ALLOWED
+	}
+
+	for _, rx := range p.excludeRx {
+		if rx.MatchString(u.Path) {
+			return PATH_EXCLUDED
+		}
+	}
+
+	if len(p.includeRx) > 0 {
+		included := false
+		for _, rx := range p.includeRx {
+			if rx.MatchString(u.Path) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return PATH_NOT_INCLUDED
+		}
+	}
+
+	if maxDepth := p.maxDepthFor(seed); maxDepth > 0 && depth > maxDepth {
+		return MAX_DEPTH
+	}
+
+	return ALLOWED
+}
+
+func (p *Policy) maxDepthFor(seed string) int {
+	if seed != "" {
+		if d, ok := p.SeedMaxDepth[seed]; ok {
+			return d
+		}
+		if parsed, err := url.Parse(seed); err == nil {
+			if d, ok := p.SeedMaxDepth[parsed.Hostname()]; ok {
+				return d
+			}
+		}
+	}
+	return p.MaxDepth
+}
+
+// "*.example.com" matches example.com itself as well as every subdomain.
+func matchHost(pattern string, host string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	if apex, ok := strings.CutPrefix(pattern, "*."); ok && host == apex {
+		return true
+	}
+
+	matched, err := path.Match(pattern, host)
+	return err == nil && matched
+}
+
+func matchAnyHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if matchHost(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package scope
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	p := &Policy{
+		AllowHosts:   []string{"*.python.org", "go.dev"},
+		DenyHosts:    []string{"bugs.python.org"},
+		ExcludePaths: []string{`^/blog/`},
+		MaxDepth:     3,
+		SeedMaxDepth: map[string]int{"go.dev": 1},
+	}
+
+	if err := p.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		url   string
+		depth int
+		seed  string
+		want  Reason
+	}{
+		{"https://docs.python.org/3/", 1, "", ALLOWED},
+		{"https://python.org/", 1, "", ALLOWED},
+		{"https://bugs.python.org/issue1", 1, "", HOST_DENIED},
+		{"https://twitter.com/golang", 1, "", HOST_NOT_ALLOWED},
+		{"https://go.dev/blog/intro", 1, "", PATH_EXCLUDED},
+		{"https://docs.python.org/3/library/", 4, "", MAX_DEPTH},
+		{"https://go.dev/doc/", 2, "https://go.dev/doc/", MAX_DEPTH},
+		{"https://go.dev/doc/", 1, "https://go.dev/doc/", ALLOWED},
+	}
+
+	for _, c := range cases {
+		u, _ := url.Parse(c.url)
+		if got := p.Check(u, c.depth, c.seed); got != c.want {
+			t.Errorf("Check(%s, depth=%d) = %q, want %q", c.url, c.depth, got, c.want)
+		}
+	}
+}
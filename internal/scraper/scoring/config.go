@@ -0,0 +1,177 @@
+package scoring
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Rule adds Weight to URLs whose host matches one of Hosts and whose path
+// contains one of Paths. An empty list matches everything. Class marks
+// matching URLs as docs, api or blog in their metadata.
+type Rule struct {
+	Name   string   `json:"name" yaml:"name"`
+	Class  string   `json:"class,omitempty" yaml:"class"`
+	Hosts  []string `json:"hosts,omitempty" yaml:"hosts"`
+	Paths  []string `json:"paths,omitempty" yaml:"paths"`
+	Weight int      `json:"weight" yaml:"weight"`
+}
+
+// Signals weigh what is known about a URL besides its address.
+type Signals struct {
+	ShallowDepth    int           `json:"shallow_depth" yaml:"shallow_depth"` // depth up to which ShallowBonus applies
+	ShallowBonus    int           `json:"shallow_bonus" yaml:"shallow_bonus"`
+	NoQuery         int           `json:"no_query" yaml:"no_query"`
+	CodeBlocks      int           `json:"code_blocks" yaml:"code_blocks"`
+	InboundLink     int           `json:"inbound_link" yaml:"inbound_link"`
+	InboundLinkCap  int           `json:"inbound_link_cap" yaml:"inbound_link_cap"`
+	FreshnessBonus  int           `json:"freshness_bonus" yaml:"freshness_bonus"` // for a URL seen just now, fading to 0
+	FreshnessWindow time.Duration `json:"freshness_window" yaml:"freshness_window"`
+	Focus           int           `json:"focus" yaml:"focus"` // for a predicted relevant outlink, as much off for an irrelevant one
+}
+
+// HostOverride changes weights for matching hosts. Weights are keyed by rule
+// name or signal name (shallow_bonus, no_query, code_blocks, inbound_link,
+// freshness_bonus, focus). Bias is added to every URL of the host.
+type HostOverride struct {
+	Hosts   []string       `json:"hosts" yaml:"hosts"`
+	Weights map[string]int `json:"weights,omitempty" yaml:"weights"`
+	Bias    int            `json:"bias,omitempty" yaml:"bias"`
+}
+
+// Config is the rule set of the default scorer. When File is set the rules
+// are read from that YAML or JSON file instead, at start and on every reload.
+type Config struct {
+	File    string         `json:"file,omitempty" yaml:"file"`
+	Rules   []Rule         `json:"rules" yaml:"rules"`
+	Signals Signals        `json:"signals" yaml:"signals"`
+	Hosts   []HostOverride `json:"hosts,omitempty" yaml:"hosts"`
+}
+
+// DefaultConfig favours documentation and API references over blogs.
+func DefaultConfig() Config {
+	return Config{
+		Rules: []Rule{
+			{Name: "docs", Class: CLASS_DOCS, Paths: []string{"/docs/", "/guide/", "/tutorial/"}, Weight: 40},
+			{Name: "api", Class: CLASS_API, Paths: []string{"/api/", "/reference/"}, Weight: 35},
+			{Name: "blog", Class: CLASS_BLOG, Paths: []string{"/blog/", "/post/"}, Weight: -15},
+		},
+		Signals: Signals{
+			ShallowDepth:    2,
+			ShallowBonus:    30,
+			NoQuery:         10,
+			CodeBlocks:      30,
+			InboundLink:     3,
+			InboundLinkCap:  30,
+			FreshnessBonus:  15,
+			FreshnessWindow: 30 * time.Minute,
+			Focus:           20,
+		},
+	}
+}
+
+// LoadFile reads a rule set. The file replaces rules, signals and host
+// overrides as a whole.
+func LoadFile(file string) (Config, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return Config{}, fmt.Errorf("Can't read scoring rules: %w", err)
+	}
+
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("Can't parse scoring rules %s: %w", file, err)
+	}
+	cfg.File = file
+
+	return cfg, cfg.Validate()
+}
+
+// Resolve returns the rules to use, reading File when it is set.
+func (c Config) Resolve() (Config, error) {
+	if c.File == "" {
+		return c, c.Validate()
+	}
+	return LoadFile(c.File)
+}
+
+func (c Config) Validate() error {
+	names := map[string]bool{}
+
+	for i, r := range c.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("rule %d has no name", i)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("duplicate rule %q", r.Name)
+		}
+		names[r.Name] = true
+
+		switch r.Class {
+		case "", CLASS_DOCS, CLASS_API, CLASS_BLOG:
+		default:
+			return fmt.Errorf("rule %q: unknown class %q", r.Name, r.Class)
+		}
+
+		if err := checkHostPatterns(r.Hosts); err != nil {
+			return fmt.Errorf("rule %q: %w", r.Name, err)
+		}
+	}
+
+	if c.Signals.FreshnessWindow < 0 {
+		return fmt.Errorf("signals.freshness_window must not be negative")
+	}
+
+	for i, o := range c.Hosts {
+		if len(o.Hosts) == 0 {
+			return fmt.Errorf("host override %d has no hosts", i)
+		}
+		if err := checkHostPatterns(o.Hosts); err != nil {
+			return fmt.Errorf("host override %d: %w", i, err)
+		}
+		for name := range o.Weights {
+			if !names[name] && !isSignal(name) {
+				return fmt.Errorf("host override %d: unknown weight %q", i, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkHostPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid host pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// "*.example.com" matches example.com itself as well as every subdomain.
+func matchHost(pattern string, host string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	if apex, ok := strings.CutPrefix(pattern, "*."); ok && host == apex {
+		return true
+	}
+
+	matched, err := path.Match(pattern, host)
+	return err == nil && matched
+}
+
+func matchAnyHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if matchHost(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package scoring
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+)
+
+const (
+	CLASS_DOCS = "docs"
+	CLASS_API  = "api"
+	CLASS_BLOG = "blog"
+)
+
+const (
+	SIGNAL_SHALLOW   = "shallow_bonus"
+	SIGNAL_NO_QUERY  = "no_query"
+	SIGNAL_CODE      = "code_blocks"
+	SIGNAL_INBOUND   = "inbound_link"
+	SIGNAL_FRESHNESS = "freshness_bonus"
+	SIGNAL_FOCUS     = "focus"
+	SIGNAL_HOST_BIAS = "host_bias"
+)
+
+func isSignal(name string) bool {
+	switch name {
+	case SIGNAL_SHALLOW, SIGNAL_NO_QUERY, SIGNAL_CODE, SIGNAL_INBOUND, SIGNAL_FRESHNESS, SIGNAL_FOCUS:
+		return true
+	}
+	return false
+}
+
+// Scorer decides how urgently a discovered URL should be crawled.
+type Scorer interface {
+	// Classify records what kind of page the URL points to in meta.
+	Classify(u *url.URL, meta *queues.UrlMeta)
+	// Score is the base score of the URL with the contribution of every
+	// signal that applied.
+	Score(u *url.URL, meta *queues.UrlMeta) Explanation
+}
+
+type Contribution struct {
+	Signal string `json:"signal"`
+	Points int    `json:"points"`
+}
+
+type Explanation struct {
+	URL      string         `json:"url"`
+	Score    int            `json:"score"`
+	Priority string         `json:"priority"`
+	Parts    []Contribution `json:"parts"`
+}
+
+func (e Explanation) String() string {
+	var parts []string
+	for _, p := range e.Parts {
+		parts = append(parts, fmt.Sprintf("%s %+d", p.Signal, p.Points))
+	}
+	return fmt.Sprintf("%d (%s): %s", e.Score, e.Priority, strings.Join(parts, ", "))
+}
+
+// RuleScorer scores URLs with a config-driven rule set. Reload swaps the
+// rules atomically, so scoring never sees a half-applied config.
+type RuleScorer struct {
+	cfg atomic.Pointer[Config]
+}
+
+func NewRuleScorer(cfg Config) (*RuleScorer, error) {
+	s := &RuleScorer{}
+	if err := s.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload validates and applies a new rule set. A config naming a file is
+// read again from disk.
+func (s *RuleScorer) Reload(cfg Config) error {
+	resolved, err := cfg.Resolve()
+	if err != nil {
+		return err
+	}
+	s.cfg.Store(&resolved)
+	return nil
+}
+
+// Config is the rule set in use.
+func (s *RuleScorer) Config() Config {
+	return *s.cfg.Load()
+}
+
+func (s *RuleScorer) Classify(u *url.URL, meta *queues.UrlMeta) {
+	cfg := s.cfg.Load()
+	host := strings.ToLower(u.Hostname())
+	path := strings.ToLower(u.Path)
+
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if rule.Class == "" || !rule.matches(host, path) {
+			continue
+		}
+
+		switch rule.Class {
+		case CLASS_DOCS:
+			meta.IsDocs = true
+		case CLASS_API:
+			meta.IsApi = true
+		case CLASS_BLOG:
+			meta.IsBlog = true
+		}
+	}
+
+	if u.RawQuery != "" {
+		meta.HasQueryParams = true
+	}
+}
+
+func (s *RuleScorer) Score(u *url.URL, meta *queues.UrlMeta) Explanation {
+	cfg := s.cfg.Load()
+	host := strings.ToLower(u.Hostname())
+	path := strings.ToLower(u.Path)
+
+	weights := map[string]int{}
+	bias := 0
+	for _, o := range cfg.Hosts {
+		if !matchAnyHost(o.Hosts, host) {
+			continue
+		}
+		for name, w := range o.Weights {
+			weights[name] = w
+		}
+		bias += o.Bias
+	}
+
+	weight := func(name string, def int) int {
+		if w, ok := weights[name]; ok {
+			return w
+		}
+		return def
+	}
+
+	e := Explanation{URL: u.String()}
+	add := func(signal string, points int) {
+		if points == 0 {
+			return
+		}
+		e.Parts = append(e.Parts, Contribution{Signal: signal, Points: points})
+		e.Score += points
+	}
+
+	sig := cfg.Signals
+
+	if window := sig.FreshnessWindow; window > 0 {
+		age := min(time.Since(meta.FirstSeenAt).Truncate(time.Minute), window)
+		add(SIGNAL_FRESHNESS, int(int64(weight(SIGNAL_FRESHNESS, sig.FreshnessBonus))*int64(window-age)/int64(window)))
+	}
+
+	if meta.Depth <= sig.ShallowDepth {
+		add(SIGNAL_SHALLOW, weight(SIGNAL_SHALLOW, sig.ShallowBonus))
+	}
+
+	if u.RawQuery == "" && !meta.HasQueryParams {
+		add(SIGNAL_NO_QUERY, weight(SIGNAL_NO_QUERY, sig.NoQuery))
+	}
+
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		if rule.matches(host, path) {
+			add(rule.Name, weight(rule.Name, rule.Weight))
+		}
+	}
+
+	if meta.HasCodeBlocks {
+		add(SIGNAL_CODE, weight(SIGNAL_CODE, sig.CodeBlocks))
+	}
+
+	inbound := meta.InboundLinks * weight(SIGNAL_INBOUND, sig.InboundLink)
+	if sig.InboundLinkCap > 0 {
+		inbound = min(inbound, sig.InboundLinkCap)
+	}
+	add(SIGNAL_INBOUND, inbound)
+
+	// a predicted relevance of 0.5 is neutral, certainty either way is worth
+	// the full weight
+	if meta.HasRelevance {
+		add(SIGNAL_FOCUS, int(math.Round(float64(weight(SIGNAL_FOCUS, sig.Focus))*(2*meta.Relevance-1))))
+	}
+
+	add(SIGNAL_HOST_BIAS, bias)
+
+	e.Priority = string(queues.ScoreToPriority(e.Score))
+
+	return e
+}
+
+func (r *Rule) matches(host, path string) bool {
+	if len(r.Hosts) > 0 && !matchAnyHost(r.Hosts, host) {
+		return false
+	}
+
+	if len(r.Paths) == 0 {
+		return true
+	}
+	for _, p := range r.Paths {
+		if strings.Contains(path, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
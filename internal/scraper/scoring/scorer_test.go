@@ -0,0 +1,169 @@
+package scoring
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/queues"
+)
+
+func score(t *testing.T, s *RuleScorer, raw string, depth int) (Explanation, *queues.UrlMeta) {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := queues.NewUrlMeta(depth)
+	meta.FirstSeenAt = time.Now().Add(-time.Hour) // no freshness bonus
+	s.Classify(u, meta)
+	return s.Score(u, meta), meta
+}
+
+func TestDefaultWeights(t *testing.T) {
+	s, err := NewRuleScorer(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		url   string
+		depth int
+		want  int
+	}{
+		{"https://go.dev/docs/intro", 1, 30 + 10 + 40},
+		{"https://go.dev/docs/api/x", 3, 10 + 40 + 35},
+		{"https://go.dev/blog/post/x?page=2", 5, -15},
+		{"https://go.dev/about", 2, 30 + 10},
+	}
+
+	for _, c := range cases {
+		if e, _ := score(t, s, c.url, c.depth); e.Score != c.want {
+			t.Errorf("%s: score = %d (%s), want %d", c.url, e.Score, e, c.want)
+		}
+	}
+
+	_, meta := score(t, s, "https://go.dev/docs/api/x?q=1", 1)
+	if !meta.IsDocs || !meta.IsApi || meta.IsBlog || !meta.HasQueryParams {
+		t.Errorf("unexpected classification %+v", meta)
+	}
+}
+
+func TestHostOverrides(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Rules = append(cfg.Rules, Rule{Name: "k8s", Hosts: []string{"*.kubernetes.io"}, Weight: 20})
+	cfg.Hosts = []HostOverride{
+		{Hosts: []string{"react.dev"}, Weights: map[string]int{"docs": 0}, Bias: -25},
+	}
+
+	s, err := NewRuleScorer(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e, _ := score(t, s, "https://kubernetes.io/docs/x", 3); e.Score != 10+40+20 {
+		t.Errorf("kubernetes docs = %d (%s)", e.Score, e)
+	}
+	if e, _ := score(t, s, "https://react.dev/docs/x", 3); e.Score != 10-25 {
+		t.Errorf("react docs = %d (%s)", e.Score, e)
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	s, _ := NewRuleScorer(DefaultConfig())
+	u, _ := url.Parse("https://go.dev/x?y=1")
+
+	meta := queues.NewUrlMeta(5)
+	if e := s.Score(u, meta); e.Score != 15 {
+		t.Errorf("fresh url = %d (%s), want 15", e.Score, e)
+	}
+
+	meta.FirstSeenAt = time.Now().Add(-15 * time.Minute)
+	if e := s.Score(u, meta); e.Score < 7 || e.Score > 8 {
+		t.Errorf("half-aged url = %d, want about 7", e.Score)
+	}
+}
+
+func TestReloadFromFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	write := func(weight string) {
+		rules := "rules:\n  - name: infra\n    paths: [\"/infra/\"]\n    weight: " + weight + "\n"
+		if err := os.WriteFile(file, []byte(rules), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("50")
+	s, err := NewRuleScorer(Config{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := score(t, s, "https://x.dev/infra/a", 5); e.Score != 50 {
+		t.Errorf("score = %d, want 50", e.Score)
+	}
+
+	write("5")
+	if err := s.Reload(Config{File: file}); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := score(t, s, "https://x.dev/infra/a", 5); e.Score != 5 {
+		t.Errorf("score after reload = %d, want 5", e.Score)
+	}
+
+	write("nope")
+	if err := s.Reload(Config{File: file}); err == nil {
+		t.Error("expected a bad file to fail")
+	}
+	if e, _ := score(t, s, "https://x.dev/infra/a", 5); e.Score != 5 {
+		t.Errorf("a failed reload must keep the old rules, score = %d", e.Score)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	bad := []Config{
+		{Rules: []Rule{{Weight: 1}}},
+		{Rules: []Rule{{Name: "a"}, {Name: "a"}}},
+		{Rules: []Rule{{Name: "a", Class: "news"}}},
+		{Rules: []Rule{{Name: "a", Hosts: []string{"[x"}}}},
+		{Hosts: []HostOverride{{Hosts: []string{"x.dev"}, Weights: map[string]int{"nope": 1}}}},
+	}
+
+	for i, cfg := range bad {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("config %d should be invalid", i)
+		}
+	}
+
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("default config invalid: %v", err)
+	}
+}
+
+func TestFocus(t *testing.T) {
+	s, _ := NewRuleScorer(DefaultConfig())
+	u, _ := url.Parse("https://go.dev/x?y=1")
+
+	cases := []struct {
+		relevance float64
+		known     bool
+		want      int
+	}{
+		{0, false, 0}, // no prediction
+		{0, true, -20},
+		{0.5, true, 0},
+		{1, true, 20},
+		{0.1, true, -16},
+	}
+
+	for _, c := range cases {
+		meta := queues.NewUrlMeta(5)
+		meta.FirstSeenAt = time.Now().Add(-time.Hour)
+		meta.Relevance = c.relevance
+		meta.HasRelevance = c.known
+		if e := s.Score(u, meta); e.Score != c.want {
+			t.Errorf("relevance %.1f: score = %d (%s), want %d", c.relevance, e.Score, e, c.want)
+		}
+	}
+}
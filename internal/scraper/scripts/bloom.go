@@ -0,0 +1,66 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// Test-and-add for the scalable Bloom filter. Every layer is a bit string at
+// prefix..i and the layer count and fill lives in the meta hash. The caller
+// computes bit positions for every existing layer plus the next one.
+// KEYS: meta hash
+// ARGV: bit key prefix, expected layers L, then capacity, k and k positions
+// for each of the layers 0..L.
+// Returns 0 when the item was (probably) seen, 1 when added, 2 when added to
+// a freshly opened layer, -1 when the layer count changed and the caller
+// must recompute positions.
+var BloomAddScript = redis.NewScript(`
+local metaKey = KEYS[1]
+local prefix = ARGV[1]
+local expected = tonumber(ARGV[2])
+
+local layers = tonumber(redis.call("HGET", metaKey, "layers") or "1")
+if layers ~= expected then
+    return -1
+end
+
+local parsed = {}
+local idx = 3
+for i = 0, layers do
+    local capacity = tonumber(ARGV[idx])
+    local k = tonumber(ARGV[idx + 1])
+    local positions = {}
+    for j = 1, k do
+        positions[j] = tonumber(ARGV[idx + 1 + j])
+    end
+    parsed[i] = {capacity = capacity, positions = positions}
+    idx = idx + 2 + k
+end
+
+for i = 0, layers - 1 do
+    local key = prefix .. i
+    local all = true
+    for _, pos in ipairs(parsed[i].positions) do
+        if redis.call("GETBIT", key, pos) == 0 then
+            all = false
+            break
+        end
+    end
+    if all then
+        return 0
+    end
+end
+
+local added = 1
+local target = layers - 1
+local count = tonumber(redis.call("HGET", metaKey, "count:" .. target) or "0")
+if count >= parsed[target].capacity then
+    target = layers
+    added = 2
+    redis.call("HSET", metaKey, "layers", layers + 1)
+end
+
+for _, pos in ipairs(parsed[target].positions) do
+    redis.call("SETBIT", prefix .. target, pos, 1)
+end
+redis.call("HINCRBY", metaKey, "count:" .. target, 1)
+
+return added
+`)
@@ -0,0 +1,153 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// Mercator-style dequeue. Priority front queues are drained into per-host back
+// queues while few hosts are ready. Of the ready hosts that waited longest,
+// the one whose next job comes first in the scheduled priority order hands it
+// out and is leased until the worker releases it. When no ready host has a
+// job of a priority the schedule prefers, that priority's front queue is
+// drained into the back queues first, so the schedule holds even while the
+// back queues are full of other priorities.
+// Stale copies of a URL are dropped on the way and the handed out job is
+// marked in flight in the URL index.
+// A ranked queue has its ranked set as the only front queue and refills the
+// back queues best job first.
+// KEYS: host heap, url index, jobs hash, front queues in scheduled order
+// ARGV: now ms, lease ms, refill batch, ready host target, back queue prefix,
+// "ranked" for a ranked queue, ready hosts looked at, priorities in scheduled
+// order (the same order as the front queues of a list queue)
+// Returns {job, 0}, or {"", wait ms} with -1 when the frontier is empty.
+var HostDequeueScript = redis.NewScript(urlIndexLib + `
+local hostsKey = KEYS[1]
+local urlsKey = KEYS[2]
+local jobsKey = KEYS[3]
+local now = tonumber(ARGV[1])
+local lease = tonumber(ARGV[2])
+local batch = tonumber(ARGV[3])
+local readyTarget = tonumber(ARGV[4])
+local prefix = ARGV[5]
+local ranked = ARGV[6] == "ranked"
+local scan = tonumber(ARGV[7])
+
+local rankOf = {}
+for i = 8, #ARGV do
+    rankOf[ARGV[i]] = i - 7
+end
+local unranked = #ARGV - 6
+
+-- moves up to n jobs from a front queue to the back queues of their hosts
+local function refill(key, n)
+    local moved = 0
+    while moved < n do
+        local data
+        if ranked then
+            data = popRanked(key, jobsKey)
+        else
+            data = redis.call("LPOP", key)
+        end
+        if not data then
+            break
+        end
+
+        local host = ""
+        local ok, job = pcall(cjson.decode, data)
+        if ok and type(job) == "table" and type(job.host) == "string" then
+            host = job.host
+        end
+
+        redis.call("RPUSH", prefix .. host, data)
+        redis.call("ZADD", hostsKey, "NX", now, host)
+        moved = moved + 1
+    end
+    return moved
+end
+
+-- the ready host whose live head job ranks best, longest waiting first
+local function pickHost()
+    local bestHost, bestData, bestRank = nil, nil, nil
+    local ready = redis.call("ZRANGEBYSCORE", hostsKey, "-inf", now, "LIMIT", 0, scan)
+    for _, host in ipairs(ready) do
+        local key = prefix .. host
+        local data = redis.call("LINDEX", key, 0)
+        while data do
+            local id, url = jobFields(data)
+            if isLive(urlsKey, url, id) then
+                break
+            end
+            redis.call("LPOP", key)
+            data = redis.call("LINDEX", key, 0)
+        end
+
+        if not data then
+            redis.call("ZREM", hostsKey, host)
+        else
+            local rank = rankOf[jobPriority(data)] or unranked
+            if not bestRank or rank < bestRank then
+                bestHost, bestData, bestRank = host, data, rank
+                if rank == 1 then
+                    break
+                end
+            end
+        end
+    end
+    return bestHost, bestData, bestRank
+end
+
+if redis.call("ZCOUNT", hostsKey, "-inf", now) < readyTarget then
+    local moved = 0
+    for i = 4, #KEYS do
+        moved = moved + refill(KEYS[i], batch - moved)
+        if moved >= batch then
+            break
+        end
+    end
+end
+
+local host, data, rank = pickHost()
+
+-- pull in the priorities the schedule prefers over what is ready
+if not ranked then
+    local better = math.min((rank or unranked) - 1, #KEYS - 3)
+    for i = 1, better do
+        if refill(KEYS[3 + i], batch) > 0 then
+            host, data, rank = pickHost()
+            break
+        end
+    end
+end
+
+if host then
+    redis.call("LPOP", prefix .. host)
+    local id, url, score = jobFields(data)
+    local live, cur = isLive(urlsKey, url, id)
+    if url ~= "" then
+        if cur then
+            score = cur.score
+        end
+        redis.call("HSET", urlsKey, url, "processing|" .. score .. "|" .. id)
+    end
+    redis.call("ZADD", hostsKey, now + lease, host)
+    return {data, 0}
+end
+
+local nextHost = redis.call("ZRANGE", hostsKey, 0, 0, "WITHSCORES")
+if #nextHost == 0 then
+    return {"", -1}
+end
+
+return {"", math.max(tonumber(nextHost[2]) - now, 0)}
+`)
+
+// Hands a leased host back to the heap at its next fetch time, or drops it
+// when its back queue is empty.
+// KEYS: host heap, back queue
+// ARGV: host, next fetch ms
+var HostReleaseScript = redis.NewScript(`
+if redis.call("LLEN", KEYS[2]) > 0 then
+    redis.call("ZADD", KEYS[1], tonumber(ARGV[2]), ARGV[1])
+else
+    redis.call("ZREM", KEYS[1], ARGV[1])
+end
+return 0
+`)
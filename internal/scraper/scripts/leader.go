@@ -0,0 +1,28 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// Takes the lease when it is free and extends it when we already hold it.
+// KEYS: lease key
+// ARGV: holder id, ttl ms
+// Returns 1 when we hold the lease.
+var LeaseAcquireScript = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if holder == ARGV[1] then
+    redis.call("PEXPIRE", KEYS[1], ARGV[2])
+    return 1
+end
+if not holder then
+    redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+    return 1
+end
+return 0
+`)
+
+// Gives the lease up, but only if we still hold it.
+var LeaseReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
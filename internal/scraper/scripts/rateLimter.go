@@ -2,27 +2,113 @@ package scripts
 
 import "github.com/redis/go-redis/v9"
 
+// Per-host pacing state lives in one hash so every worker shares it:
+// next (ms), delay (ms), floor (ms), latency (ewma ms), errors (ewma 0..1).
+// The hash expires idle ms after the host's next allowed fetch and the hosts
+// zset is scored with that expiry, so hosts that are no longer crawled drop
+// out of both.
+// Returns 0 and reserves the slot when the host is ready, otherwise the wait in ms.
 var RateLimitScript = redis.NewScript(`
 local key = KEYS[1]
+local hostsKey = KEYS[2]
 local now = tonumber(ARGV[1])
-local delay = tonumber(ARGV[2])
+local floor = tonumber(ARGV[2])
+local initialDelay = tonumber(ARGV[3])
+local host = ARGV[4]
+local idle = tonumber(ARGV[5])
 
-local nextAllowed = redis.call("GET", key)
+local state = redis.call("HMGET", key, "next", "delay")
+local nextAllowed = tonumber(state[1]) or now
+local delay = tonumber(state[2]) or initialDelay
 
-if not nextAllowed then
-    nextAllowed = now
-else
-    nextAllowed = tonumber(nextAllowed)
+if delay < floor then
+    delay = floor
 end
 
-if nextAllowed < now then
-    nextAllowed = now
+redis.call("ZREMRANGEBYSCORE", hostsKey, "-inf", now)
+
+if nextAllowed > now then
+    return nextAllowed - now
+end
+
+redis.call("HSET", key, "next", now + delay, "delay", delay, "floor", floor)
+redis.call("PEXPIREAT", key, now + delay + idle)
+redis.call("ZADD", hostsKey, "GT", now + delay + idle, host)
+
+return 0
+`)
+
+// AIMD feedback after a fetch: additive speed-up while latency and error rate
+// are healthy, multiplicative back-off on timeouts, 5xx and 429.
+// ARGV: now, outcome (ok|error|throttle), latency ms, floor ms, max delay ms,
+// step ms, backoff factor, latency target ms, error target, ewma alpha, retry-after ms,
+// idle ms, host
+var RateFeedbackScript = redis.NewScript(`
+local key = KEYS[1]
+local hostsKey = KEYS[2]
+local now = tonumber(ARGV[1])
+local outcome = ARGV[2]
+local latency = tonumber(ARGV[3])
+local floor = tonumber(ARGV[4])
+local maxDelay = tonumber(ARGV[5])
+local step = tonumber(ARGV[6])
+local factor = tonumber(ARGV[7])
+local latencyTarget = tonumber(ARGV[8])
+local errorTarget = tonumber(ARGV[9])
+local alpha = tonumber(ARGV[10])
+local retryAfter = tonumber(ARGV[11])
+local idle = tonumber(ARGV[12])
+local host = ARGV[13]
+
+local state = redis.call("HMGET", key, "delay", "latency", "errors", "next")
+local delay = tonumber(state[1]) or floor
+local ewmaLatency = tonumber(state[2]) or latency
+local ewmaErrors = tonumber(state[3]) or 0
+local nextAllowed = tonumber(state[4]) or now
+
+local failed = 0
+if outcome ~= "ok" then
+    failed = 1
+end
+
+ewmaLatency = alpha * latency + (1 - alpha) * ewmaLatency
+ewmaErrors = alpha * failed + (1 - alpha) * ewmaErrors
+
+if failed == 1 then
+    delay = delay * factor
+elseif ewmaLatency <= latencyTarget and ewmaErrors <= errorTarget then
+    delay = delay - step
+end
+
+if delay < floor then
+    delay = floor
+end
+if delay > maxDelay then
+    delay = maxDelay
+end
+
+delay = math.floor(delay)
+
+if failed == 1 and now + delay > nextAllowed then
+    nextAllowed = now + delay
+end
+
+if retryAfter > 0 and now + retryAfter > nextAllowed then
+    nextAllowed = now + retryAfter
 end
 
-local wait = nextAllowed - now
-local newNext = nextAllowed + delay
+redis.call("HSET", key,
+    "delay", delay,
+    "floor", floor,
+    "latency", tostring(ewmaLatency),
+    "errors", tostring(ewmaErrors),
+    "next", nextAllowed,
+    "updated", now)
 
-redis.call("SET", key, newNext)
+-- a long Retry-After keeps the state alive
+local expires = math.max(nextAllowed, now) + idle
+redis.call("PEXPIREAT", key, expires)
+redis.call("ZADD", hostsKey, "GT", expires, host)
 
-return wait
+return delay
 `)
@@ -0,0 +1,65 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// Moves the in-flight jobs of a worker whose heartbeat expired back to the
+// ready queues. Removing the worker from the registry is the claim, so only
+// one live worker ever reclaims a dead worker's jobs.
+// KEYS: worker registry set, heartbeat key, processing list, url index,
+// ranked set and jobs hash for a ranked queue
+// ARGV: worker id, ready queue prefix, fallback priority, now ms, aging ms
+// Returns the number of jobs requeued, -1 when the worker is alive or was
+// already reclaimed.
+var ReclaimOrphansScript = redis.NewScript(urlIndexLib + `
+local workersKey = KEYS[1]
+local heartbeatKey = KEYS[2]
+local processingKey = KEYS[3]
+local urlsKey = KEYS[4]
+local workerID = ARGV[1]
+local readyPrefix = ARGV[2]
+local fallback = ARGV[3]
+local ranked = #KEYS >= 6
+
+if redis.call("EXISTS", heartbeatKey) == 1 then
+    return -1
+end
+
+if redis.call("SREM", workersKey, workerID) == 0 then
+    return -1
+end
+
+local requeued = 0
+for _, id in ipairs(redis.call("LRANGE", processingKey, 0, -1)) do
+    local data = redis.call("GET", "job:" .. id)
+    if data then
+        -- the job is pushed as stored, re-encoding would mangle 64-bit hashes
+        local priority = fallback
+        local ok, job = pcall(cjson.decode, data)
+        if ok and type(job) == "table" and type(job.priority) == "string" and job.priority ~= "" then
+            priority = job.priority
+        end
+
+        local jobID, url, score = jobFields(data)
+        local live, cur = isLive(urlsKey, url, jobID)
+        if live then
+            if cur then
+                score = cur.score
+            end
+            if ranked then
+                pushRanked(KEYS[5], KEYS[6], jobID, score, data, tonumber(ARGV[4]), tonumber(ARGV[5]))
+            else
+                redis.call("RPUSH", readyPrefix .. priority, data)
+            end
+            if url ~= "" then
+                redis.call("HSET", urlsKey, url, "ready|" .. score .. "|" .. jobID)
+            end
+            requeued = requeued + 1
+        end
+        redis.call("DEL", "job:" .. id)
+    end
+end
+
+redis.call("DEL", processingKey)
+
+return requeued
+`)
@@ -0,0 +1,48 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// Moves due jobs from the retry set to their ready queue in one step, so two
+// movers can never push the same job twice. Jobs are stored already aged and
+// are pushed as is. Stale copies of a URL are dropped instead.
+// KEYS: retry set, url index, ranked set and jobs hash for a ranked queue
+// ARGV: now ms, ready queue prefix, batch size, fallback priority, aging ms
+// Returns the number of jobs moved.
+var RetryMoverScript = redis.NewScript(urlIndexLib + `
+local retryKey = KEYS[1]
+local urlsKey = KEYS[2]
+local now = ARGV[1]
+local readyPrefix = ARGV[2]
+local batch = tonumber(ARGV[3])
+local fallback = ARGV[4]
+local ranked = #KEYS >= 4
+
+local due = redis.call("ZRANGEBYSCORE", retryKey, "-inf", now, "LIMIT", 0, batch)
+
+for _, data in ipairs(due) do
+    local priority = fallback
+    local ok, job = pcall(cjson.decode, data)
+    if ok and type(job) == "table" and type(job.priority) == "string" and job.priority ~= "" then
+        priority = job.priority
+    end
+
+    local id, url, score = jobFields(data)
+    local live, cur = isLive(urlsKey, url, id)
+    if live then
+        if cur then
+            score = cur.score
+        end
+        if ranked then
+            pushRanked(KEYS[3], KEYS[4], id, score, data, tonumber(now), tonumber(ARGV[5]))
+        else
+            redis.call("RPUSH", readyPrefix .. priority, data)
+        end
+        if url ~= "" then
+            redis.call("HSET", urlsKey, url, "ready|" .. score .. "|" .. id)
+        end
+    end
+    redis.call("ZREM", retryKey, data)
+end
+
+return #due
+`)
@@ -0,0 +1,35 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// returns "" when the url is admitted, otherwise the quota that rejected it
+var ScopeAdmissionScript = redis.NewScript(`
+local hostPagesKey = KEYS[1]
+local seedHostsKey = KEYS[2]
+local newHostsKey = KEYS[3]
+local host = ARGV[1]
+local maxPages = tonumber(ARGV[2])
+local maxNewHosts = tonumber(ARGV[3])
+
+local isNewHost = redis.call("SISMEMBER", seedHostsKey, host) == 0 and
+    redis.call("SISMEMBER", newHostsKey, host) == 0
+
+if isNewHost and maxNewHosts > 0 and redis.call("SCARD", newHostsKey) >= maxNewHosts then
+    return "new_host_cap"
+end
+
+if maxPages > 0 then
+    local pages = tonumber(redis.call("HGET", hostPagesKey, host) or "0")
+    if pages >= maxPages then
+        return "host_page_cap"
+    end
+end
+
+if isNewHost then
+    redis.call("SADD", newHostsKey, host)
+end
+
+redis.call("HINCRBY", hostPagesKey, host, 1)
+
+return ""
+`)
@@ -0,0 +1,85 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// Near-duplicate check against SimHash fingerprints kept in four bucket
+// sets per 16-bit block, at prefix..table..":"..block. Fingerprints are 16
+// hex digits and compared as two 32-bit halves, Lua numbers can't hold 64
+// bits. A new fingerprint is indexed with its document and the oldest ones
+// are evicted beyond max size. The bucket sets of evicted fingerprints are
+// built from the prefix, so every key must share one cluster hash tag.
+// KEYS: fingerprint set ordered by insertion, meta hash, documents hash, the
+// four bucket sets of the fingerprint
+// ARGV: fingerprint, max distance (-1 only adds), max size (0 unbounded),
+// bucket key prefix, document
+// Returns the matching fingerprint and its document, or an empty array when
+// the fingerprint was new.
+var SimhashCheckScript = redis.NewScript(`
+local orderKey = KEYS[1]
+local metaKey = KEYS[2]
+local docsKey = KEYS[3]
+local fp = ARGV[1]
+local maxDist = tonumber(ARGV[2])
+local maxSize = tonumber(ARGV[3])
+local prefix = ARGV[4]
+local doc = ARGV[5]
+
+local function bucketKey(f, i)
+    return prefix .. i .. ":" .. string.sub(f, (i - 1) * 4 + 1, i * 4)
+end
+
+local function popcount(x)
+    local n = 0
+    while x ~= 0 do
+        x = bit.band(x, x - 1)
+        n = n + 1
+    end
+    return n
+end
+
+local function half(f, i)
+    return tonumber(string.sub(f, (i - 1) * 8 + 1, i * 8), 16)
+end
+
+local function distance(a, b)
+    return popcount(bit.bxor(half(a, 1), half(b, 1))) + popcount(bit.bxor(half(a, 2), half(b, 2)))
+end
+
+if maxDist >= 0 then
+    for i = 1, 4 do
+        for _, c in ipairs(redis.call("SMEMBERS", KEYS[3 + i])) do
+            if distance(fp, c) <= maxDist then
+                return {c, redis.call("HGET", docsKey, c) or ""}
+            end
+        end
+    end
+end
+
+if redis.call("ZSCORE", orderKey, fp) then
+    return {}
+end
+
+local seq = redis.call("HINCRBY", metaKey, "seq", 1)
+redis.call("ZADD", orderKey, seq, fp)
+redis.call("HSET", docsKey, fp, doc)
+for i = 1, 4 do
+    redis.call("SADD", KEYS[3 + i], fp)
+end
+
+if maxSize > 0 then
+    local over = redis.call("ZCARD", orderKey) - maxSize
+    if over > 0 then
+        -- ZPOPMIN returns member, score pairs
+        local popped = redis.call("ZPOPMIN", orderKey, over)
+        for j = 1, #popped, 2 do
+            for i = 1, 4 do
+                redis.call("SREM", bucketKey(popped[j], i), popped[j])
+            end
+            redis.call("HDEL", docsKey, popped[j])
+        end
+        redis.call("HINCRBY", metaKey, "evicted", over)
+    end
+end
+
+return {}
+`)
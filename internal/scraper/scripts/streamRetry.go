@@ -0,0 +1,19 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// Re-publishes due stream messages from the retry set in one step, so two
+// movers can never add the same message twice.
+// KEYS: retry set, stream
+// ARGV: now ms, batch size
+// Returns the number of messages re-published.
+var StreamRetryScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, tonumber(ARGV[2]))
+
+for _, data in ipairs(due) do
+    redis.call("XADD", KEYS[2], "*", "data", data)
+    redis.call("ZREM", KEYS[1], data)
+end
+
+return #due
+`)
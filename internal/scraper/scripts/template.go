@@ -0,0 +1,72 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// Per-host page templates. While a host is sampled every observed page adds
+// one to the count of each of its blocks and nothing is flagged, so the
+// crawler and the parser, which looks at the page later, strip the same
+// blocks. Once sample pages were observed, the blocks seen on at least min
+// pages and min share of them are frozen into the template set, with a "-"
+// member so an empty template is remembered too, that expires after the
+// relearn ttl. The page that completes the sample is checked against the new
+// set. The counters expire as well, so small hosts don't keep them forever.
+// KEYS: template set, block counts hash, sampled pages counter
+// ARGV: observe (1 counts the page), sample pages, min pages, min share,
+// ttl seconds, block hashes...
+// Returns 1 or 0 per block hash, 1 for a template block.
+var TemplateScript = redis.NewScript(`
+local setKey = KEYS[1]
+local countsKey = KEYS[2]
+local pagesKey = KEYS[3]
+
+local observe = ARGV[1] == "1"
+local sample = tonumber(ARGV[2])
+local minPages = tonumber(ARGV[3])
+local minShare = tonumber(ARGV[4])
+local ttl = tonumber(ARGV[5])
+
+local function members()
+    local flags = {}
+    for i = 6, #ARGV do
+        flags[#flags + 1] = redis.call("SISMEMBER", setKey, ARGV[i])
+    end
+    return flags
+end
+
+if redis.call("EXISTS", setKey) == 1 then
+    return members()
+end
+
+local flags = {}
+for i = 6, #ARGV do
+    flags[#flags + 1] = 0
+end
+
+if not observe then
+    return flags
+end
+
+local pages = redis.call("INCR", pagesKey)
+redis.call("EXPIRE", pagesKey, ttl)
+for i = 6, #ARGV do
+    redis.call("HINCRBY", countsKey, ARGV[i], 1)
+end
+
+if pages < sample then
+    redis.call("EXPIRE", countsKey, ttl)
+    return flags
+end
+
+redis.call("SADD", setKey, "-")
+local counts = redis.call("HGETALL", countsKey)
+for j = 1, #counts, 2 do
+    local count = tonumber(counts[j + 1])
+    if count >= minPages and count >= minShare * pages then
+        redis.call("SADD", setKey, counts[j])
+    end
+end
+redis.call("EXPIRE", setKey, ttl)
+redis.call("DEL", countsKey, pagesKey)
+
+return members()
+`)
@@ -0,0 +1,73 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// returns "" for a healthy url, otherwise the trap reason; offending patterns
+// are written to the quarantine hash unless an operator allowed them. Only
+// patterns with an id or number below the prefix (ARGV[10] == "1") count
+// towards the prefix's pattern limit.
+var TrapCheckScript = redis.NewScript(`
+local allowKey = KEYS[1]
+local quarantineKey = KEYS[2]
+local variantsKey = KEYS[3]
+local urlsKey = KEYS[4]
+local prefixKey = KEYS[5]
+
+local pattern = ARGV[1]
+local prefix = ARGV[2]
+local rawQuery = ARGV[3]
+local normalized = ARGV[4]
+local maxVariants = tonumber(ARGV[5])
+local maxUrls = tonumber(ARGV[6])
+local maxPatterns = tonumber(ARGV[7])
+local ttl = tonumber(ARGV[8])
+local now = ARGV[9]
+local counted = ARGV[10] == "1"
+
+if redis.call("SISMEMBER", allowKey, pattern) == 1 then
+    return ""
+end
+
+if redis.call("HEXISTS", quarantineKey, pattern) == 1 then
+    return "quarantined"
+end
+
+local prefixAllowed = redis.call("SISMEMBER", allowKey, prefix) == 1
+
+if not prefixAllowed and redis.call("HEXISTS", quarantineKey, prefix) == 1 then
+    return "quarantined"
+end
+
+local function quarantine(target, reason, count)
+    local entry = cjson.encode({pattern = target, reason = reason, count = count, since = now})
+    redis.call("HSETNX", quarantineKey, target, entry)
+    return reason
+end
+
+if rawQuery ~= "" then
+    redis.call("PFADD", variantsKey, rawQuery)
+    redis.call("EXPIRE", variantsKey, ttl)
+    local variants = redis.call("PFCOUNT", variantsKey)
+    if variants > maxVariants then
+        return quarantine(pattern, "query_cardinality", variants)
+    end
+end
+
+redis.call("PFADD", urlsKey, normalized)
+redis.call("EXPIRE", urlsKey, ttl)
+local urls = redis.call("PFCOUNT", urlsKey)
+if urls > maxUrls then
+    return quarantine(pattern, "pattern_explosion", urls)
+end
+
+if counted and not prefixAllowed then
+    redis.call("PFADD", prefixKey, pattern)
+    redis.call("EXPIRE", prefixKey, ttl)
+    local patterns = redis.call("PFCOUNT", prefixKey)
+    if patterns > maxPatterns then
+        return quarantine(prefix, "pattern_explosion", patterns)
+    end
+end
+
+return ""
+`)
@@ -0,0 +1,191 @@
+package scripts
+
+import "github.com/redis/go-redis/v9"
+
+// Shared by the scripts that keep the queued-URL index. The index maps a URL
+// to "state|score|job id" where state is ready, retry or processing. Copies
+// of a URL whose job id no longer matches the index are stale and dropped
+// when they come up. A job with an index_key is indexed under it instead of
+// its URL, which is how queues that never merge jobs key them by id.
+const urlIndexLib = `
+local function indexEntry(urlsKey, url)
+    if url == "" then
+        return nil
+    end
+    local entry = redis.call("HGET", urlsKey, url)
+    if not entry then
+        return nil
+    end
+    local state, score, id = string.match(entry, "^(%a+)|(-?%d+)|(.*)$")
+    if not state then
+        return nil
+    end
+    return {state = state, score = tonumber(score), id = id}
+end
+
+-- reads id, index key and base score without re-encoding the job
+local function jobFields(data)
+    local ok, job = pcall(cjson.decode, data)
+    if not ok or type(job) ~= "table" then
+        return "", "", 0
+    end
+    local id = type(job.id) == "string" and job.id or ""
+    local url = type(job.url) == "string" and job.url or ""
+    if type(job.index_key) == "string" and job.index_key ~= "" then
+        url = job.index_key
+    end
+    local score = tonumber(job.base_score) or 0
+    return id, url, score
+end
+
+local function jobPriority(data)
+    local ok, job = pcall(cjson.decode, data)
+    if ok and type(job) == "table" and type(job.priority) == "string" then
+        return job.priority
+    end
+    return ""
+end
+
+local function isLive(urlsKey, url, id)
+    local cur = indexEntry(urlsKey, url)
+    return cur == nil or cur.id == id, cur
+end
+
+-- A ranked queue keeps job ids in a sorted set and the jobs in a hash. The
+-- rank is the score less one point per aging interval since the epoch, so
+-- the highest rank is the job with the best score after aging.
+local function pushRanked(rankedKey, jobsKey, id, score, data, now, agingMs)
+    redis.call("ZADD", rankedKey, score - now / agingMs, id)
+    redis.call("HSET", jobsKey, id, data)
+end
+
+-- pops the best ranked job, false when the set is empty
+local function popRanked(rankedKey, jobsKey)
+    while true do
+        local top = redis.call("ZPOPMAX", rankedKey)
+        if #top == 0 then
+            return false
+        end
+        local data = redis.call("HGET", jobsKey, top[1])
+        if data then
+            redis.call("HDEL", jobsKey, top[1])
+            return data
+        end
+    end
+end
+`
+
+// Adds a job unless its URL is already queued. A duplicate is merged: the
+// copy with the higher score wins and a URL in flight is never queued again.
+// A replaced copy in a ranked set is removed right away.
+// KEYS: url index, ready list, retry set or ranked set, jobs hash when ranked
+// ARGV: url, job id, score, job data, "ready", "retry" or "ranked", due ms
+// for retry or now ms for ranked, aging ms for ranked
+// Returns 1 when added, 2 when it replaced a lower-scored copy, 0 when merged
+// into the existing one.
+var FrontierEnqueueScript = redis.NewScript(urlIndexLib + `
+local urlsKey = KEYS[1]
+local url = ARGV[1]
+local id = ARGV[2]
+local score = tonumber(ARGV[3])
+local mode = ARGV[5]
+
+local cur = indexEntry(urlsKey, url)
+if cur and cur.id ~= id then
+    if cur.state == "processing" or cur.score >= score then
+        return 0
+    end
+end
+
+local state = mode
+if mode == "retry" then
+    redis.call("ZADD", KEYS[2], tonumber(ARGV[6]), ARGV[4])
+elseif mode == "ranked" then
+    if cur and cur.id ~= id and cur.state == "ready" then
+        redis.call("ZREM", KEYS[2], cur.id)
+        redis.call("HDEL", KEYS[3], cur.id)
+    end
+    pushRanked(KEYS[2], KEYS[3], id, score, ARGV[4], tonumber(ARGV[6]), tonumber(ARGV[7]))
+    state = "ready"
+else
+    redis.call("RPUSH", KEYS[2], ARGV[4])
+end
+
+if url ~= "" then
+    redis.call("HSET", urlsKey, url, state .. "|" .. score .. "|" .. id)
+end
+
+if cur and cur.id ~= id then
+    return 2
+end
+return 1
+`)
+
+// Marks a dequeued job as in flight, or reports it stale.
+// KEYS: url index
+// ARGV: url, job id, score
+// Returns 1 when claimed, 0 when a newer copy of the URL is queued.
+var FrontierClaimScript = redis.NewScript(urlIndexLib + `
+local live, cur = isLive(KEYS[1], ARGV[1], ARGV[2])
+if not live then
+    return 0
+end
+
+if ARGV[1] ~= "" then
+    local score = ARGV[3]
+    if cur then
+        score = cur.score
+    end
+    redis.call("HSET", KEYS[1], ARGV[1], "processing|" .. score .. "|" .. ARGV[2])
+end
+return 1
+`)
+
+// Pops the best live job of a ranked queue and marks it in flight. Stale
+// copies of a URL are dropped on the way.
+// KEYS: ranked set, jobs hash, url index
+// Returns {job, score}, score being the one in the index, or {} when the
+// queue is empty.
+var RankedDequeueScript = redis.NewScript(urlIndexLib + `
+local urlsKey = KEYS[3]
+
+local data = popRanked(KEYS[1], KEYS[2])
+while data do
+    local id, url, score = jobFields(data)
+    local live, cur = isLive(urlsKey, url, id)
+    if live then
+        if url ~= "" then
+            if cur then
+                score = cur.score
+            end
+            redis.call("HSET", urlsKey, url, "processing|" .. score .. "|" .. id)
+        end
+        return {data, score}
+    end
+    data = popRanked(KEYS[1], KEYS[2])
+end
+
+return {}
+`)
+
+// Changes the score of a job waiting in a ranked set in place, keeping the
+// aging it has built up.
+// KEYS: url index, ranked set
+// ARGV: url, new score
+// Returns 1 when rescored, 0 when the URL is not waiting in the set.
+var RankedRescoreScript = redis.NewScript(urlIndexLib + `
+local url = ARGV[1]
+local score = tonumber(ARGV[2])
+
+local cur = indexEntry(KEYS[1], url)
+if not cur or cur.state ~= "ready" then
+    return 0
+end
+if not redis.call("ZSCORE", KEYS[2], cur.id) then
+    return 0
+end
+
+redis.call("ZINCRBY", KEYS[2], score - cur.score, cur.id)
+redis.call("HSET", KEYS[1], url, "ready|" .. score .. "|" .. cur.id)
+return 1
+`)
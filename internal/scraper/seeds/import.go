@@ -0,0 +1,153 @@
+package seeds
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+type Format string
+
+const (
+	FORMAT_TEXT     Format = "text"
+	FORMAT_OPML     Format = "opml"
+	FORMAT_MARKDOWN Format = "md"
+)
+
+func DetectFormat(file string) Format {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".opml", ".xml":
+		return FORMAT_OPML
+	case ".md", ".markdown":
+		return FORMAT_MARKDOWN
+	default:
+		return FORMAT_TEXT
+	}
+}
+
+func Parse(r io.Reader, format Format) ([]string, error) {
+	switch format {
+	case FORMAT_TEXT:
+		return parseText(r)
+	case FORMAT_OPML:
+		return parseOPML(r)
+	case FORMAT_MARKDOWN:
+		return parseMarkdown(r)
+	}
+	return nil, fmt.Errorf("unknown seed format %q", format)
+}
+
+// one url per line, blank lines and # comments are ignored
+func parseText(r io.Reader) ([]string, error) {
+	var urls []string
+	seen := make(map[string]struct{})
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		urls = appendUnique(urls, seen, strings.Fields(line)[0])
+	}
+
+	return urls, scanner.Err()
+}
+
+type opmlOutline struct {
+	HTMLURL  string        `xml:"htmlUrl,attr"`
+	XMLURL   string        `xml:"xmlUrl,attr"`
+	URL      string        `xml:"url,attr"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlDoc struct {
+	Outlines []opmlOutline `xml:"body>outline"`
+}
+
+// prefers the site url of an outline over its feed url
+func parseOPML(r io.Reader) ([]string, error) {
+	var doc opmlDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("Can't parse OPML: %w", err)
+	}
+
+	var urls []string
+	seen := make(map[string]struct{})
+
+	var walk func(outlines []opmlOutline)
+	walk = func(outlines []opmlOutline) {
+		for _, o := range outlines {
+			for _, candidate := range []string{o.HTMLURL, o.URL, o.XMLURL} {
+				if candidate != "" {
+					urls = appendUnique(urls, seen, candidate)
+					break
+				}
+			}
+			walk(o.Outlines)
+		}
+	}
+	walk(doc.Outlines)
+
+	return urls, nil
+}
+
+// awesome lists are mostly "- [name](link) - description" bullets
+func parseMarkdown(r io.Reader) ([]string, error) {
+	source, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := goldmark.DefaultParser().Parse(text.NewReader(source))
+
+	var urls []string
+	seen := make(map[string]struct{})
+
+	err = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch node := n.(type) {
+		case *ast.Image:
+			return ast.WalkSkipChildren, nil
+		case *ast.Link:
+			// badge links wrap a single image
+			if _, isImage := node.FirstChild().(*ast.Image); isImage && node.ChildCount() == 1 {
+				return ast.WalkSkipChildren, nil
+			}
+			urls = appendUnique(urls, seen, string(node.Destination))
+		case *ast.AutoLink:
+			urls = appendUnique(urls, seen, string(node.URL(source)))
+		}
+
+		return ast.WalkContinue, nil
+	})
+
+	return urls, err
+}
+
+func appendUnique(urls []string, seen map[string]struct{}, raw string) []string {
+	raw = strings.TrimSpace(raw)
+
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return urls
+	}
+
+	if _, ok := seen[raw]; ok {
+		return urls
+	}
+
+	seen[raw] = struct{}{}
+	return append(urls, raw)
+}
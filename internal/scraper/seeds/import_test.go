@@ -0,0 +1,53 @@
+package seeds
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name   string
+		format Format
+		input  string
+		want   []string
+	}{
+		{
+			name:   "text",
+			format: FORMAT_TEXT,
+			input:  "# docs\nhttps://go.dev/doc/\n\nhttps://go.dev/doc/\nnot-a-url\nhttps://docs.python.org/3/ python\n",
+			want:   []string{"https://go.dev/doc/", "https://docs.python.org/3/"},
+		},
+		{
+			name:   "opml",
+			format: FORMAT_OPML,
+			input: `<opml version="2.0"><body>
+<outline text="Go"><outline text="blog" htmlUrl="https://go.dev/blog/" xmlUrl="https://go.dev/blog/feed.atom"/></outline>
+<outline text="feed only" xmlUrl="https://example.com/rss"/>
+</body></opml>`,
+			want: []string{"https://go.dev/blog/", "https://example.com/rss"},
+		},
+		{
+			name:   "awesome list",
+			format: FORMAT_MARKDOWN,
+			input: `# Awesome Go [![Awesome](https://awesome.re/badge.svg)](https://awesome.re)
+
+- [Gin](https://github.com/gin-gonic/gin) - HTTP web framework.
+- [Contributing](#contributing)
+- <https://pkg.go.dev>
+`,
+			want: []string{"https://github.com/gin-gonic/gin", "https://pkg.go.dev"},
+		},
+	}
+
+	for _, c := range cases {
+		got, err := Parse(strings.NewReader(c.input), c.format)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
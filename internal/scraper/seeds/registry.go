@@ -0,0 +1,240 @@
+package seeds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type Status string
+
+const (
+	SEED_PENDING  Status = "pending"
+	SEED_ENQUEUED Status = "enqueued"
+	SEED_CRAWLED  Status = "crawled"
+)
+
+const (
+	RegistryKey  = "seeds:registry"
+	LastCrawlKey = "seeds:lastcrawl"
+	DiscoveryKey = "seeds:discovered"
+	RemovedKey   = "seeds:removed"
+)
+
+// SOURCE_CONFIG marks seeds from the config file, which are registered again
+// on every start. A removed seed stays removed for this source only.
+const SOURCE_CONFIG = "config"
+
+type Seed struct {
+	URL             string    `json:"url"`
+	Status          Status    `json:"status"`
+	Source          string    `json:"source"`
+	AddedAt         time.Time `json:"added_at"`
+	LastCrawledAt   time.Time `json:"last_crawled_at,omitzero"`
+	PagesDiscovered int64     `json:"pages_discovered"`
+}
+
+type Registry struct {
+	rdb *redis.Client
+}
+
+func NewRegistry(rdb *redis.Client) *Registry {
+	return &Registry{rdb: rdb}
+}
+
+func validate(rawUrl string) (string, error) {
+	rawUrl = strings.TrimSpace(rawUrl)
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", fmt.Errorf("invalid seed url %q: %w", rawUrl, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid seed url %q: need an absolute http(s) url", rawUrl)
+	}
+	return rawUrl, nil
+}
+
+// Add registers new seeds as pending. Seeds that are already known keep their state.
+// Config seeds that were removed are skipped, any other source adds them back.
+func (r *Registry) Add(ctx context.Context, urls []string, source string) (int, error) {
+	valid := make([]string, 0, len(urls))
+	for _, raw := range urls {
+		u, err := validate(raw)
+		if err != nil {
+			return 0, err
+		}
+		valid = append(valid, u)
+	}
+
+	removed := make([]bool, len(valid))
+	if source == SOURCE_CONFIG && len(valid) > 0 {
+		members := make([]any, len(valid))
+		for i, u := range valid {
+			members[i] = u
+		}
+		var err error
+		if removed, err = r.rdb.SMIsMember(ctx, RemovedKey, members...).Result(); err != nil {
+			return 0, err
+		}
+	}
+
+	pipe := r.rdb.Pipeline()
+	var cmds []*redis.BoolCmd
+
+	for i, u := range valid {
+		if removed[i] {
+			continue
+		}
+		if source != SOURCE_CONFIG {
+			pipe.SRem(ctx, RemovedKey, u)
+		}
+
+		data, err := json.Marshal(Seed{
+			URL:     u,
+			Status:  SEED_PENDING,
+			Source:  source,
+			AddedAt: time.Now(),
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		cmds = append(cmds, pipe.HSetNX(ctx, RegistryKey, u, data))
+	}
+
+	if len(cmds) == 0 {
+		return 0, nil
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	added := 0
+	for _, cmd := range cmds {
+		if cmd.Val() {
+			added++
+		}
+	}
+
+	return added, nil
+}
+
+// Remove forgets a seed and keeps the config from registering it again.
+func (r *Registry) Remove(ctx context.Context, rawUrl string) (bool, error) {
+	pipe := r.rdb.TxPipeline()
+	removed := pipe.HDel(ctx, RegistryKey, rawUrl)
+	pipe.SAdd(ctx, RemovedKey, rawUrl)
+	pipe.HDel(ctx, LastCrawlKey, rawUrl)
+	pipe.HDel(ctx, DiscoveryKey, rawUrl)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+
+	return removed.Val() > 0, nil
+}
+
+func (r *Registry) List(ctx context.Context) ([]Seed, error) {
+	pipe := r.rdb.Pipeline()
+	all := pipe.HGetAll(ctx, RegistryKey)
+	crawled := pipe.HGetAll(ctx, LastCrawlKey)
+	discovered := pipe.HGetAll(ctx, DiscoveryKey)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	list := make([]Seed, 0, len(all.Val()))
+
+	for _, data := range all.Val() {
+		var s Seed
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			continue
+		}
+
+		if ms, err := strconv.ParseInt(crawled.Val()[s.URL], 10, 64); err == nil {
+			s.LastCrawledAt = time.UnixMilli(ms)
+			s.Status = SEED_CRAWLED
+		}
+
+		s.PagesDiscovered, _ = strconv.ParseInt(discovered.Val()[s.URL], 10, 64)
+		list = append(list, s)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].URL < list[j].URL
+	})
+
+	return list, nil
+}
+
+func (r *Registry) Pending(ctx context.Context) ([]Seed, error) {
+	list, err := r.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Seed
+	for _, s := range list {
+		if s.Status == SEED_PENDING {
+			pending = append(pending, s)
+		}
+	}
+
+	return pending, nil
+}
+
+func (r *Registry) setStatus(ctx context.Context, rawUrl string, status Status) error {
+	data, err := r.rdb.HGet(ctx, RegistryKey, rawUrl).Bytes()
+	if err == redis.Nil {
+		return fmt.Errorf("unknown seed %s", rawUrl)
+	}
+	if err != nil {
+		return err
+	}
+
+	var s Seed
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	s.Status = status
+
+	data, err = json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	return r.rdb.HSet(ctx, RegistryKey, rawUrl, data).Err()
+}
+
+func (r *Registry) MarkEnqueued(ctx context.Context, rawUrl string) error {
+	return r.setStatus(ctx, rawUrl, SEED_ENQUEUED)
+}
+
+// Requeue puts a seed back to pending so the next seed sync crawls it again.
+func (r *Registry) Requeue(ctx context.Context, rawUrl string) error {
+	if err := r.rdb.HDel(ctx, LastCrawlKey, rawUrl).Err(); err != nil {
+		return err
+	}
+	return r.setStatus(ctx, rawUrl, SEED_PENDING)
+}
+
+func (r *Registry) RecordCrawl(ctx context.Context, rawUrl string) error {
+	return r.rdb.HSet(ctx, LastCrawlKey, rawUrl, time.Now().UnixMilli()).Err()
+}
+
+func (r *Registry) AddDiscovered(ctx context.Context, seed string, n int) error {
+	if seed == "" || n == 0 {
+		return nil
+	}
+	return r.rdb.HIncrBy(ctx, DiscoveryKey, seed, int64(n)).Err()
+}
@@ -0,0 +1,63 @@
+package seeds
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// testRedis connects to the scratch Redis at TEST_REDIS_ADDR, the test is
+// skipped when it is not set. The registry keys are cleared before and
+// after the test.
+func testRedis(t *testing.T) *redis.Client {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR not set")
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	ctx := context.Background()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Fatalf("redis at %s: %v", addr, err)
+	}
+
+	keys := []string{RegistryKey, LastCrawlKey, DiscoveryKey, RemovedKey}
+	rdb.Del(ctx, keys...)
+	t.Cleanup(func() {
+		rdb.Del(ctx, keys...)
+		rdb.Close()
+	})
+
+	return rdb
+}
+
+func TestRemovedConfigSeedStaysRemoved(t *testing.T) {
+	ctx := context.Background()
+	registry := NewRegistry(testRedis(t))
+	config := []string{"https://go.dev/doc/", "https://docs.rs/"}
+
+	if added, err := registry.Add(ctx, config, SOURCE_CONFIG); err != nil || added != 2 {
+		t.Fatalf("added %d, %v", added, err)
+	}
+	if removed, err := registry.Remove(ctx, "https://docs.rs/"); err != nil || !removed {
+		t.Fatalf("removed %v, %v", removed, err)
+	}
+
+	// a restart registers the config seeds again
+	if added, err := registry.Add(ctx, config, SOURCE_CONFIG); err != nil || added != 0 {
+		t.Fatalf("restart added %d, %v", added, err)
+	}
+	if list, _ := registry.List(ctx); len(list) != 1 || list[0].URL != "https://go.dev/doc/" {
+		t.Fatalf("removed seed came back: %+v", list)
+	}
+
+	// adding it by hand brings it back
+	if added, err := registry.Add(ctx, []string{"https://docs.rs/"}, "cli"); err != nil || added != 1 {
+		t.Fatalf("cli added %d, %v", added, err)
+	}
+	if removed, _ := registry.rdb.SIsMember(ctx, RemovedKey, "https://docs.rs/").Result(); removed {
+		t.Error("re-added seed is still marked removed")
+	}
+}
@@ -16,6 +16,11 @@ var (
 	bytesFetched   int64
 	fetchLatencyNs int64
 	fetchCount     int64
+	scopeSkipped   int64
+	trapSkipped    int64
+	noindexPages   int64
+	nofollowPages  int64
+	noarchivePages int64
 )
 
 func IncrementProcessed() {
@@ -30,6 +35,26 @@ func IncrementError() {
 	atomic.AddInt64(&errors, 1)
 }
 
+func IncrementScopeSkip() {
+	atomic.AddInt64(&scopeSkipped, 1)
+}
+
+func IncrementTrapSkip() {
+	atomic.AddInt64(&trapSkipped, 1)
+}
+
+func IncrementDirectives(noindex, nofollow, noarchive bool) {
+	if noindex {
+		atomic.AddInt64(&noindexPages, 1)
+	}
+	if nofollow {
+		atomic.AddInt64(&nofollowPages, 1)
+	}
+	if noarchive {
+		atomic.AddInt64(&noarchivePages, 1)
+	}
+}
+
 func AddBytes(n int64) {
 	atomic.AddInt64(&bytesFetched, n)
 }
@@ -69,6 +94,11 @@ func FinalReport() {
 	bytes := atomic.LoadInt64(&bytesFetched)
 	latency := atomic.LoadInt64(&fetchLatencyNs)
 	count := atomic.LoadInt64(&fetchCount)
+	skipped := atomic.LoadInt64(&scopeSkipped)
+	trapped := atomic.LoadInt64(&trapSkipped)
+	noindex := atomic.LoadInt64(&noindexPages)
+	nofollow := atomic.LoadInt64(&nofollowPages)
+	noarchive := atomic.LoadInt64(&noarchivePages)
 
 	unique := processed - dups
 
@@ -95,6 +125,9 @@ func FinalReport() {
 	log.Printf("%.2f pages/sec", pps)
 	log.Printf("%.0f%% duplicates", dupRatio*100)
 	log.Printf("%d errors", errs)
+	log.Printf("%d urls skipped by scope policy", skipped)
+	log.Printf("%d urls skipped as crawler traps", trapped)
+	log.Printf("%d noindex, %d nofollow, %d noarchive pages", noindex, nofollow, noarchive)
 	log.Printf("%.2f MB downloaded", mb)
 	log.Printf("%.2f ms avg fetch latency", avgLatency)
 
@@ -0,0 +1,133 @@
+package templates
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// testLearner learns templates in the scratch Redis at TEST_REDIS_ADDR, the
+// test is skipped when it is not set. It returns a host of its own, whose
+// keys are dropped after the test.
+func testLearner(t *testing.T, cfg Config) (*Learner, string) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR not set")
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		t.Fatalf("redis at %s: %v", addr, err)
+	}
+
+	l := NewLearner(rdb, cfg)
+	host := uuid.NewString() + ".example.com"
+	t.Cleanup(func() {
+		l.Forget(context.Background(), host)
+		rdb.Close()
+	})
+
+	return l, host
+}
+
+func learnConfig() Config {
+	cfg := DefaultConfig()
+	cfg.SamplePages = 4
+	cfg.MinPages = 2
+	return cfg
+}
+
+// nthPage is the test page with a main paragraph of its own.
+func nthPage(t *testing.T, n int) *goquery.Document {
+	html := strings.Replace(page, "Every option can be set", fmt.Sprintf("Page %d says every option can be set", n), 1)
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestLearnTemplate(t *testing.T) {
+	ctx := context.Background()
+	l, host := testLearner(t, learnConfig())
+
+	// nothing is stripped while the host is sampled, and the parser's
+	// lookups don't count
+	for n := range 3 {
+		if removed, err := l.Strip(ctx, host, nthPage(t, n), true); err != nil || removed != 0 {
+			t.Fatalf("page %d while learning: removed %d, %v", n, removed, err)
+		}
+		if removed, _ := l.Strip(ctx, host, nthPage(t, n), false); removed != 0 {
+			t.Fatalf("parser stripped %d blocks while learning", removed)
+		}
+	}
+	if st, _ := l.Status(ctx, host); st.Learned || st.PagesSampled != 3 {
+		t.Fatalf("status while learning = %+v, want 3 pages sampled", st)
+	}
+
+	// the last sampled page freezes the template and is stripped with it
+	if removed, _ := l.Strip(ctx, host, nthPage(t, 3), true); removed != 2 {
+		t.Errorf("sample completing page: removed %d, want the sidebar and the banner", removed)
+	}
+
+	// sidebar, its list, the version picker and the banner
+	st, _ := l.Status(ctx, host)
+	if !st.Learned || st.Blocks != 4 || st.RelearnIn <= 0 {
+		t.Errorf("status once learned = %+v, want 4 blocks", st)
+	}
+	if removed, _ := l.Strip(ctx, host, nthPage(t, 9), false); removed != 2 {
+		t.Errorf("parser removed %d blocks from a learned host, want 2", removed)
+	}
+}
+
+func TestEmptyTemplateIsRemembered(t *testing.T) {
+	ctx := context.Background()
+	l, host := testLearner(t, learnConfig())
+
+	for n := range 4 {
+		html := fmt.Sprintf("<html><body><div><p>Only page %d has this paragraph of text.</p></div></body></html>", n)
+		doc, _ := goquery.NewDocumentFromReader(strings.NewReader(html))
+		if removed, _ := l.Strip(ctx, host, doc, true); removed != 0 {
+			t.Fatalf("page %d: removed %d", n, removed)
+		}
+	}
+
+	// the "-" marker keeps the host from being sampled again
+	st, _ := l.Status(ctx, host)
+	if !st.Learned || st.Blocks != 0 {
+		t.Errorf("status = %+v, want learned with no blocks", st)
+	}
+	if removed, _ := l.Strip(ctx, host, nthPage(t, 0), true); removed != 0 {
+		t.Errorf("empty template removed %d blocks", removed)
+	}
+}
+
+func TestRelearnAfterExpiry(t *testing.T) {
+	ctx := context.Background()
+	l, host := testLearner(t, learnConfig())
+
+	for n := range 4 {
+		l.Strip(ctx, host, nthPage(t, n), true)
+	}
+	if st, _ := l.Status(ctx, host); !st.Learned {
+		t.Fatal("template not learned")
+	}
+
+	// relearn_after passed
+	l.rdb.PExpire(ctx, fmt.Sprintf(TemplateKey, host), time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+
+	if removed, _ := l.Strip(ctx, host, nthPage(t, 4), true); removed != 0 {
+		t.Errorf("removed %d blocks while learning again", removed)
+	}
+	if st, _ := l.Status(ctx, host); st.Learned || st.PagesSampled != 1 {
+		t.Errorf("status after expiry = %+v, want sampling from scratch", st)
+	}
+}
@@ -0,0 +1,263 @@
+package templates
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scripts"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/cespare/xxhash/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	TemplateKey = "template:%s" // learned block hashes of a host
+	CountsKey   = "template:%s:counts"
+	PagesKey    = "template:%s:pages"
+
+	// blocks looked at per page, outer ones first
+	maxBlocks = 500
+)
+
+// elements that can hold a repeated sidebar, picker or banner; html, body,
+// main and article are left alone
+const blockSelector = "div, section, header, form, ul, ol, dl, table, details, select, p"
+
+// Config controls template learning. A host's template is learned from the
+// first SamplePages pages fetched from it: a block whose text is at least
+// MinChars long and shows up on MinPages pages and MinShare of the sample
+// is template. The template is learned again after RelearnAfter.
+type Config struct {
+	Enabled      bool          `json:"enabled" yaml:"enabled"`
+	SamplePages  int           `json:"sample_pages" yaml:"sample_pages"`
+	MinPages     int           `json:"min_pages" yaml:"min_pages"`
+	MinShare     float64       `json:"min_share" yaml:"min_share"`
+	MinChars     int           `json:"min_chars" yaml:"min_chars"`
+	RelearnAfter time.Duration `json:"relearn_after" yaml:"relearn_after"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Enabled:      true,
+		SamplePages:  100,
+		MinPages:     5,
+		MinShare:     0.5,
+		MinChars:     20,
+		RelearnAfter: 7 * 24 * time.Hour,
+	}
+}
+
+func (c Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MinPages < 2 {
+		return fmt.Errorf("min_pages must be at least 2, got %d", c.MinPages)
+	}
+	if c.SamplePages < c.MinPages {
+		return fmt.Errorf("sample_pages must be at least min_pages")
+	}
+	if c.MinShare <= 0 || c.MinShare > 1 {
+		return fmt.Errorf("min_share must be in (0, 1], got %v", c.MinShare)
+	}
+	if c.MinChars < 0 {
+		return fmt.Errorf("min_chars must not be negative")
+	}
+	if c.RelearnAfter <= 0 {
+		return fmt.Errorf("relearn_after must be positive")
+	}
+	return nil
+}
+
+// Learner finds the blocks that repeat across the pages of a host and strips
+// them. What it learned is kept in Redis, so all workers share it. A nil
+// *Learner strips nothing.
+type Learner struct {
+	rdb *redis.Client
+	cfg Config
+}
+
+// NewLearner returns nil when template learning is off.
+func NewLearner(rdb *redis.Client, cfg Config) *Learner {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Learner{rdb: rdb, cfg: cfg}
+}
+
+type block struct {
+	sel  *goquery.Selection
+	hash string
+}
+
+// blockText is the text a block is recognized by, whitespace collapsed and
+// lowercased.
+func blockText(s *goquery.Selection) string {
+	return strings.ToLower(strings.Join(strings.Fields(s.Text()), " "))
+}
+
+func blocks(doc *goquery.Document, minChars int) []block {
+	var out []block
+
+	doc.Find(blockSelector).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		text := blockText(s)
+		if len(text) < minChars {
+			return true
+		}
+
+		out = append(out, block{sel: s, hash: fmt.Sprintf("%016x", xxhash.Sum64String(text))})
+		return len(out) < maxBlocks
+	})
+
+	return out
+}
+
+// Strip removes the template blocks of host from doc and reports how many it
+// removed. With observe the page also counts towards learning the template;
+// every fetched page should be observed once. Nothing is removed until the
+// template is learned.
+func (l *Learner) Strip(ctx context.Context, host string, doc *goquery.Document, observe bool) (int, error) {
+	if l == nil {
+		return 0, nil
+	}
+
+	found := blocks(doc, l.cfg.MinChars)
+	if len(found) == 0 && !observe {
+		return 0, nil
+	}
+
+	// a page counts each block once
+	var hashes []any
+	seen := map[string]bool{}
+	for _, b := range found {
+		if !seen[b.hash] {
+			seen[b.hash] = true
+			hashes = append(hashes, b.hash)
+		}
+	}
+
+	flag := "0"
+	if observe {
+		flag = "1"
+	}
+	args := append([]any{
+		flag,
+		l.cfg.SamplePages,
+		l.cfg.MinPages,
+		l.cfg.MinShare,
+		int64(l.cfg.RelearnAfter.Seconds()),
+	}, hashes...)
+
+	flags, err := scripts.TemplateScript.Run(
+		ctx,
+		l.rdb,
+		[]string{
+			fmt.Sprintf(TemplateKey, host),
+			fmt.Sprintf(CountsKey, host),
+			fmt.Sprintf(PagesKey, host),
+		},
+		args...,
+	).Int64Slice()
+	if err != nil {
+		return 0, fmt.Errorf("template check for %s: %w", host, err)
+	}
+
+	template := map[string]bool{}
+	for i, f := range flags {
+		if f == 1 && i < len(hashes) {
+			template[hashes[i].(string)] = true
+		}
+	}
+
+	return remove(found, template), nil
+}
+
+// remove drops the template blocks and reports how many it removed.
+func remove(found []block, template map[string]bool) int {
+	removed := 0
+	for _, b := range found {
+		// blocks inside a removed block are gone already
+		if template[b.hash] && b.sel.Closest("html").Length() > 0 {
+			b.sel.Remove()
+			removed++
+		}
+	}
+	return removed
+}
+
+// StripHTML is Strip for a page that was observed already, returning the
+// page's HTML without its template blocks.
+func (l *Learner) StripHTML(ctx context.Context, host string, rawHtml string) (string, int, error) {
+	if l == nil {
+		return rawHtml, 0, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(rawHtml))
+	if err != nil {
+		return rawHtml, 0, err
+	}
+
+	removed, err := l.Strip(ctx, host, doc, false)
+	if err != nil || removed == 0 {
+		return rawHtml, 0, err
+	}
+
+	out, err := doc.Html()
+	if err != nil {
+		return rawHtml, 0, err
+	}
+	return out, removed, nil
+}
+
+// Forget drops what was learned about host, so its template is learned again
+// from the next pages.
+func (l *Learner) Forget(ctx context.Context, host string) error {
+	if l == nil {
+		return nil
+	}
+	return l.rdb.Del(ctx,
+		fmt.Sprintf(TemplateKey, host),
+		fmt.Sprintf(CountsKey, host),
+		fmt.Sprintf(PagesKey, host),
+	).Err()
+}
+
+// Status is what has been learned about a host.
+type Status struct {
+	Host         string        `json:"host"`
+	Learned      bool          `json:"learned"`
+	Blocks       int64         `json:"blocks"`                  // template blocks, once learned
+	PagesSampled int64         `json:"pages_sampled,omitempty"` // while learning
+	Candidates   int64         `json:"candidates,omitempty"`    // distinct blocks seen while learning
+	RelearnIn    time.Duration `json:"relearn_in,omitempty"`
+}
+
+func (l *Learner) Status(ctx context.Context, host string) (Status, error) {
+	st := Status{Host: host}
+	if l == nil {
+		return st, nil
+	}
+
+	setKey := fmt.Sprintf(TemplateKey, host)
+	pipe := l.rdb.Pipeline()
+	card := pipe.SCard(ctx, setKey)
+	ttl := pipe.TTL(ctx, setKey)
+	pages := pipe.Get(ctx, fmt.Sprintf(PagesKey, host))
+	candidates := pipe.HLen(ctx, fmt.Sprintf(CountsKey, host))
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return st, err
+	}
+
+	if n := card.Val(); n > 0 {
+		st.Learned = true
+		st.Blocks = n - 1 // without the marker
+		st.RelearnIn = ttl.Val()
+		return st, nil
+	}
+
+	st.PagesSampled, _ = pages.Int64()
+	st.Candidates = candidates.Val()
+	return st, nil
+}
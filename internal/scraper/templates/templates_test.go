@@ -0,0 +1,96 @@
+package templates
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const page = `<html><body>
+<div class="sidebar">
+  <ul><li>Getting started</li><li>Installation guide</li><li>Configuration</li></ul>
+  <select><option>v2.1 (latest)</option><option>v2.0</option><option>v1.9</option></select>
+</div>
+<div id="cookies"><p>We use cookies to improve your experience on this site.</p></div>
+<main>
+  <h1>Configuration</h1>
+  <p>Every option can be set in the config file or on the command line.</p>
+  <p>ok</p>
+</main>
+</body></html>`
+
+func TestBlocks(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := blocks(doc, 20)
+
+	texts := map[string]bool{}
+	for _, b := range found {
+		texts[blockText(b.sel)] = true
+	}
+	if !texts["we use cookies to improve your experience on this site."] {
+		t.Errorf("cookie banner paragraph not found in %v", texts)
+	}
+	if texts["ok"] {
+		t.Error("blocks shorter than min chars should be skipped")
+	}
+
+	// the same text hashes the same on every page
+	again, _ := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if found[0].hash != blocks(again, 20)[0].hash {
+		t.Error("block hash is not stable")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := blocks(doc, 20)
+	template := map[string]bool{}
+	for _, b := range found {
+		// the sidebar, its list and the cookie banner repeat on every page
+		if b.sel.Is(".sidebar, .sidebar ul, #cookies, #cookies p") {
+			template[b.hash] = true
+		}
+	}
+
+	if removed := remove(found, template); removed != 2 {
+		t.Errorf("removed %d blocks, want the sidebar and the banner", removed)
+	}
+
+	text := doc.Text()
+	for _, gone := range []string{"Installation guide", "v2.1 (latest)", "cookies"} {
+		if strings.Contains(text, gone) {
+			t.Errorf("%q survived", gone)
+		}
+	}
+	if !strings.Contains(text, "Every option can be set") {
+		t.Error("page content was removed")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MinPages = 1
+	if err := cfg.Validate(); err == nil {
+		t.Error("a block on one page is not a template")
+	}
+
+	cfg = DefaultConfig()
+	cfg.SamplePages = 3
+	if err := cfg.Validate(); err == nil {
+		t.Error("sample below min_pages should fail")
+	}
+
+	cfg.Enabled = false
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("disabled config should not be checked: %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package traps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scripts"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	AllowKey      = "traps:allow"
+	QuarantineKey = "traps:quarantine"
+	VariantsKey   = "traps:qvariants:%s"
+	URLsKey       = "traps:urls:%s"
+	PrefixKey     = "traps:patterns:%s"
+
+	counterTTL = 7 * 24 * time.Hour
+)
+
+type QuarantineEntry struct {
+	Pattern string `json:"pattern"`
+	Reason  Reason `json:"reason"`
+	Count   int64  `json:"count"`
+	Since   string `json:"since"`
+}
+
+type Detector struct {
+	cfg Config
+	rdb *redis.Client
+}
+
+func NewDetector(rdb *redis.Client, cfg Config) *Detector {
+	return &Detector{
+		cfg: cfg,
+		rdb: rdb,
+	}
+}
+
+// Check runs all trap heuristics for a discovered link. raw is the link as found
+// on the page, normalized is what would be enqueued.
+func (d *Detector) Check(ctx context.Context, raw *url.URL, normalized *url.URL) (Reason, error) {
+	if reason := checkStatic(d.cfg, raw); reason != OK {
+		return reason, nil
+	}
+
+	if reason := checkStatic(d.cfg, normalized); reason != OK {
+		return reason, nil
+	}
+
+	pattern := Template(normalized)
+	prefix := Prefix(pattern)
+
+	counted := "0"
+	if countsTowardPrefix(pattern) {
+		counted = "1"
+	}
+
+	res, err := scripts.TrapCheckScript.Run(
+		ctx,
+		d.rdb,
+		[]string{
+			AllowKey,
+			QuarantineKey,
+			fmt.Sprintf(VariantsKey, pattern),
+			fmt.Sprintf(URLsKey, pattern),
+			fmt.Sprintf(PrefixKey, prefix),
+		},
+		pattern,
+		prefix,
+		raw.RawQuery,
+		normalized.String(),
+		d.cfg.MaxQueryVariants,
+		d.cfg.MaxURLsPerPattern,
+		d.cfg.MaxPatternsPerPrefix,
+		int64(counterTTL.Seconds()),
+		time.Now().Format(time.RFC3339),
+		counted,
+	).Text()
+
+	if err != nil {
+		return OK, fmt.Errorf("trap check failed: %w", err)
+	}
+
+	return Reason(res), nil
+}
+
+func List(ctx context.Context, rdb *redis.Client) ([]QuarantineEntry, error) {
+	raw, err := rdb.HGetAll(ctx, QuarantineKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]QuarantineEntry, 0, len(raw))
+	for pattern, data := range raw {
+		entry := QuarantineEntry{Pattern: pattern}
+		_ = json.Unmarshal([]byte(data), &entry)
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Pattern < entries[j].Pattern
+	})
+
+	return entries, nil
+}
+
+func ListAllowed(ctx context.Context, rdb *redis.Client) ([]string, error) {
+	patterns, err := rdb.SMembers(ctx, AllowKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(patterns)
+	return patterns, nil
+}
+
+// Allow lifts a quarantine and stops the pattern from being quarantined again.
+func Allow(ctx context.Context, rdb *redis.Client, pattern string) error {
+	pipe := rdb.TxPipeline()
+	pipe.HDel(ctx, QuarantineKey, pattern)
+	pipe.SAdd(ctx, AllowKey, pattern)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Block quarantines a pattern by hand and drops any previous override.
+func Block(ctx context.Context, rdb *redis.Client, pattern string) error {
+	entry, err := json.Marshal(QuarantineEntry{
+		Pattern: pattern,
+		Reason:  "manual",
+		Since:   time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+
+	pipe := rdb.TxPipeline()
+	pipe.SRem(ctx, AllowKey, pattern)
+	pipe.HSet(ctx, QuarantineKey, pattern, entry)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Release removes a pattern from both lists so the heuristics decide again.
+func Release(ctx context.Context, rdb *redis.Client, pattern string) error {
+	pipe := rdb.TxPipeline()
+	pipe.HDel(ctx, QuarantineKey, pattern)
+	pipe.SRem(ctx, AllowKey, pattern)
+	pipe.Del(ctx, fmt.Sprintf(VariantsKey, pattern), fmt.Sprintf(URLsKey, pattern), fmt.Sprintf(PrefixKey, pattern))
+	_, err := pipe.Exec(ctx)
+	return err
+}
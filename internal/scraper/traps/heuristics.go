@@ -0,0 +1,145 @@
+package traps
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type Reason string
+
+const (
+	OK                Reason = ""
+	SESSION_ID        Reason = "session_id"
+	REPEATED_SEGMENTS Reason = "repeated_segments"
+	PATH_TOO_DEEP     Reason = "path_too_deep"
+	URL_TOO_LONG      Reason = "url_too_long"
+	QUERY_CARDINALITY Reason = "query_cardinality"
+	PATTERN_EXPLOSION Reason = "pattern_explosion"
+	QUARANTINED       Reason = "quarantined"
+)
+
+type Config struct {
+	MaxPathDepth         int `json:"max_path_depth" yaml:"max_path_depth"`
+	MaxURLLength         int `json:"max_url_length" yaml:"max_url_length"`
+	MaxSegmentRepeats    int `json:"max_segment_repeats" yaml:"max_segment_repeats"`
+	MaxQueryVariants     int `json:"max_query_variants" yaml:"max_query_variants"`
+	MaxURLsPerPattern    int `json:"max_urls_per_pattern" yaml:"max_urls_per_pattern"`
+	MaxPatternsPerPrefix int `json:"max_patterns_per_prefix" yaml:"max_patterns_per_prefix"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxPathDepth:         12,
+		MaxURLLength:         512,
+		MaxSegmentRepeats:    3,
+		MaxQueryVariants:     200,
+		MaxURLsPerPattern:    5000,
+		MaxPatternsPerPrefix: 500,
+	}
+}
+
+var (
+	rxSessionParam   = regexp.MustCompile(`(?i);\s*(jsessionid|phpsessid|sid|sessionid)=`)
+	rxSessionSegment = regexp.MustCompile(`(?i)^(sess(ion)?|sid)[-_=]?[0-9a-f]{16,}$`)
+	rxOpaqueID       = regexp.MustCompile(`(?i)^[0-9a-f-]{16,}$`)
+	rxDigits         = regexp.MustCompile(`\d+`)
+)
+
+func pathSegments(p string) []string {
+	var segments []string
+	for _, s := range strings.Split(p, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
+
+// checkStatic runs the heuristics that only need the url itself.
+func checkStatic(cfg Config, u *url.URL) Reason {
+	if len(u.String()) > cfg.MaxURLLength {
+		return URL_TOO_LONG
+	}
+
+	if rxSessionParam.MatchString(u.EscapedPath()) {
+		return SESSION_ID
+	}
+
+	segments := pathSegments(u.Path)
+
+	if len(segments) > cfg.MaxPathDepth {
+		return PATH_TOO_DEEP
+	}
+
+	seen := make(map[string]int, len(segments))
+	for _, s := range segments {
+		if rxSessionSegment.MatchString(s) {
+			return SESSION_ID
+		}
+
+		seen[s]++
+		if seen[s] >= cfg.MaxSegmentRepeats {
+			return REPEATED_SEGMENTS
+		}
+	}
+
+	if hasRepeatingRun(segments) {
+		return REPEATED_SEGMENTS
+	}
+
+	return OK
+}
+
+// catches /a/b/a/b style loops that are too short for the per-segment count
+func hasRepeatingRun(segments []string) bool {
+	n := len(segments)
+	for period := 2; period*2 <= n; period++ {
+		for start := 0; start+period*2 <= n; start++ {
+			match := true
+			for i := 0; i < period; i++ {
+				if segments[start+i] != segments[start+period+i] {
+					match = false
+					break
+				}
+			}
+			if match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Template collapses ids and numbers in a path so that urls produced by the same
+// route share one pattern, e.g. go.dev/cal/2024/05/12 -> go.dev/cal/{n}/{n}/{n}.
+func Template(u *url.URL) string {
+	segments := pathSegments(u.Path)
+	for i, s := range segments {
+		if rxOpaqueID.MatchString(s) {
+			segments[i] = "{id}"
+			continue
+		}
+		segments[i] = rxDigits.ReplaceAllString(s, "{n}")
+	}
+
+	return strings.ToLower(u.Hostname()) + "/" + strings.Join(segments, "/")
+}
+
+// Prefix is the host plus first path segment, used to catch a host minting
+// unbounded numbers of distinct patterns under one section.
+func Prefix(template string) string {
+	host, rest, _ := strings.Cut(template, "/")
+	first, _, _ := strings.Cut(rest, "/")
+	return host + "/" + first + "/*"
+}
+
+// countsTowardPrefix reports whether a template counts towards its prefix's
+// pattern limit. Only templates with an id or number below the prefix do:
+// named pages like docs.python.org/{n}/library/os.html are each their own
+// page, not a route minting urls.
+func countsTowardPrefix(template string) bool {
+	_, rest, _ := strings.Cut(template, "/")
+	_, below, _ := strings.Cut(rest, "/")
+	return strings.Contains(below, "{n}") || strings.Contains(below, "{id}")
+}
@@ -0,0 +1,101 @@
+package traps
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestCheckStatic(t *testing.T) {
+	cfg := DefaultConfig()
+
+	cases := []struct {
+		url  string
+		want Reason
+	}{
+		{"https://go.dev/doc/effective_go", OK},
+		{"https://example.com/a/b/a/b/a/b", REPEATED_SEGMENTS},
+		{"https://example.com/docs/api/docs/api", REPEATED_SEGMENTS},
+		{"https://example.com/shop;jsessionid=ABC123/item", SESSION_ID},
+		{"https://example.com/sess_0123456789abcdef0123/docs", SESSION_ID},
+		{"https://example.com/1/2/3/4/5/6/7/8/9/10/11/12/13", PATH_TOO_DEEP},
+	}
+
+	for _, c := range cases {
+		u, err := url.Parse(c.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := checkStatic(cfg, u); got != c.want {
+			t.Errorf("checkStatic(%s) = %q, want %q", c.url, got, c.want)
+		}
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	u, _ := url.Parse("https://Example.com/cal/2024/05/12/page-3")
+	if got, want := Template(u), "example.com/cal/{n}/{n}/{n}/page-{n}"; got != want {
+		t.Errorf("Template = %q, want %q", got, want)
+	}
+
+	u, _ = url.Parse("https://example.com/items/3f2c9a1e-7b4d-4c1a-9e2f-0a1b2c3d4e5f")
+	if got, want := Template(u), "example.com/items/{id}"; got != want {
+		t.Errorf("Template = %q, want %q", got, want)
+	}
+
+	if got, want := Prefix("example.com/cal/{n}/{n}"), "example.com/cal/*"; got != want {
+		t.Errorf("Prefix = %q, want %q", got, want)
+	}
+}
+
+func TestNamedPagesDoNotExplodePrefix(t *testing.T) {
+	cfg := DefaultConfig()
+
+	for _, format := range []string{
+		"https://docs.python.org/3/library/%s.html",
+		"https://github.com/golang/%s",
+	} {
+		patterns := map[string]map[string]bool{}
+		for i := range 600 {
+			word := ""
+			for n := i; ; n /= 26 {
+				word += string(rune('a' + n%26))
+				if n < 26 {
+					break
+				}
+			}
+
+			u, _ := url.Parse(fmt.Sprintf(format, "page"+word))
+			pattern := Template(u)
+			if !countsTowardPrefix(pattern) {
+				continue
+			}
+			prefix := Prefix(pattern)
+			if patterns[prefix] == nil {
+				patterns[prefix] = map[string]bool{}
+			}
+			patterns[prefix][pattern] = true
+		}
+
+		for prefix, seen := range patterns {
+			if len(seen) > cfg.MaxPatternsPerPrefix {
+				t.Errorf("%s would be quarantined with %d patterns", prefix, len(seen))
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		url  string
+		want bool
+	}{
+		{"https://docs.python.org/3/library/os.html", false},
+		{"https://github.com/golang/go", false},
+		{"https://example.com/cal/2024/05", true},
+		{"https://example.com/items/3f2c9a1e-7b4d-4c1a-9e2f-0a1b2c3d4e5f", true},
+	} {
+		u, _ := url.Parse(c.url)
+		if got := countsTowardPrefix(Template(u)); got != c.want {
+			t.Errorf("countsTowardPrefix(%s) = %v, want %v", c.url, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package urlseen
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+const (
+	// every new layer holds twice as many items as the previous one
+	growth = 2
+	// and has half its false positive rate, so the sum stays under the target
+	tightening = 0.5
+
+	// a Redis string holds at most 2^32 bits
+	maxLayerBits = 1 << 32
+)
+
+type Config struct {
+	Capacity          int     `json:"capacity" yaml:"capacity"`
+	FalsePositiveRate float64 `json:"false_positive_rate" yaml:"false_positive_rate"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Capacity:          1_000_000,
+		FalsePositiveRate: 0.001,
+	}
+}
+
+type layer struct {
+	capacity uint64
+	bits     uint64
+	k        int
+	rate     float64
+}
+
+func layerParams(cfg Config, i int) layer {
+	n := float64(cfg.Capacity) * math.Pow(growth, float64(i))
+	p := cfg.FalsePositiveRate * (1 - tightening) * math.Pow(tightening, float64(i))
+
+	m := math.Ceil(-n * math.Log(p) / (math.Ln2 * math.Ln2))
+	m = min(m, maxLayerBits)
+
+	k := int(math.Ceil(m / n * math.Ln2))
+
+	return layer{
+		capacity: uint64(n),
+		bits:     uint64(m),
+		k:        max(k, 1),
+		rate:     p,
+	}
+}
+
+// double hashing, h1 + j*h2, instead of k independent hashes
+func positions(item string, l layer) []uint64 {
+	a := fnv.New64a()
+	a.Write([]byte(item))
+	h1 := a.Sum64()
+
+	b := fnv.New64()
+	b.Write([]byte(item))
+	h2 := b.Sum64() | 1
+
+	pos := make([]uint64, l.k)
+	for j := range pos {
+		pos[j] = (h1 + uint64(j)*h2) % l.bits
+	}
+
+	return pos
+}
+
+// fill-based estimate of the false positive rate of one layer
+func estimatedRate(l layer, items uint64) float64 {
+	if items == 0 {
+		return 0
+	}
+	fill := 1 - math.Exp(-float64(l.k)*float64(items)/float64(l.bits))
+	return math.Pow(fill, float64(l.k))
+}
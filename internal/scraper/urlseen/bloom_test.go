@@ -0,0 +1,78 @@
+package urlseen
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLayerRatesStayUnderTarget(t *testing.T) {
+	cfg := DefaultConfig()
+
+	total := 0.0
+	for i := range 20 {
+		l := layerParams(cfg, i)
+		if l.capacity != uint64(cfg.Capacity)<<i {
+			t.Errorf("layer %d capacity = %d", i, l.capacity)
+		}
+		total += l.rate
+	}
+
+	if total > cfg.FalsePositiveRate {
+		t.Errorf("compound false positive rate %v exceeds target %v", total, cfg.FalsePositiveRate)
+	}
+}
+
+func TestPositionsAreStableAndInRange(t *testing.T) {
+	l := layerParams(Config{Capacity: 1000, FalsePositiveRate: 0.01}, 0)
+
+	a := positions("https://go.dev/doc/", l)
+	b := positions("https://go.dev/doc/", l)
+
+	if len(a) != l.k {
+		t.Fatalf("got %d positions, want %d", len(a), l.k)
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("positions differ between calls")
+		}
+		if a[i] >= l.bits {
+			t.Fatalf("position %d out of range %d", a[i], l.bits)
+		}
+	}
+}
+
+func TestFalsePositiveRateAtCapacity(t *testing.T) {
+	cfg := Config{Capacity: 20000, FalsePositiveRate: 0.01}
+	l := layerParams(cfg, 0)
+
+	bits := make([]bool, l.bits)
+	for i := range cfg.Capacity {
+		for _, p := range positions(fmt.Sprintf("https://example.com/page/%d", i), l) {
+			bits[p] = true
+		}
+	}
+
+	const probes = 100000
+	hits := 0
+	for i := range probes {
+		seen := true
+		for _, p := range positions(fmt.Sprintf("https://other.org/item?id=%d", i), l) {
+			if !bits[p] {
+				seen = false
+				break
+			}
+		}
+		if seen {
+			hits++
+		}
+	}
+
+	rate := float64(hits) / probes
+	if rate > 2*l.rate {
+		t.Errorf("false positive rate %v, layer target %v", rate, l.rate)
+	}
+	if est := estimatedRate(l, uint64(cfg.Capacity)); est > 1.5*l.rate {
+		t.Errorf("estimated rate %v, layer target %v", est, l.rate)
+	}
+}
@@ -0,0 +1,213 @@
+package urlseen
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scripts"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	MetaKey = "urlseen:meta"
+	BitsKey = "urlseen:bits:"
+)
+
+// Filter is a scalable Bloom filter in Redis answering "was this url ever
+// discovered". False positives mean a new url is dropped, never that one is
+// crawled twice.
+type Filter struct {
+	rdb    *redis.Client
+	cfg    Config
+	layers atomic.Int64
+}
+
+type Stats struct {
+	Layers                     int     `json:"layers"`
+	Items                      uint64  `json:"items"`
+	Bytes                      uint64  `json:"bytes"`
+	FalsePositiveRate          float64 `json:"false_positive_rate"`
+	EstimatedFalsePositiveRate float64 `json:"estimated_false_positive_rate"`
+}
+
+func NewFilter(rdb *redis.Client, cfg Config) *Filter {
+	return &Filter{
+		rdb: rdb,
+		cfg: cfg,
+	}
+}
+
+func (f *Filter) Config() Config {
+	return f.cfg
+}
+
+// Open ties the filter to its parameters. The first process stores capacity
+// and false positive rate in the meta hash, and a process configured with
+// other ones is refused: its bit positions would not match the existing
+// layers, so every lookup would miss.
+func (f *Filter) Open(ctx context.Context) error {
+	capacity := strconv.Itoa(f.cfg.Capacity)
+	rate := strconv.FormatFloat(f.cfg.FalsePositiveRate, 'g', -1, 64)
+
+	pipe := f.rdb.TxPipeline()
+	pipe.HSetNX(ctx, MetaKey, "capacity", capacity)
+	pipe.HSetNX(ctx, MetaKey, "false_positive_rate", rate)
+	stored := pipe.HMGet(ctx, MetaKey, "capacity", "false_positive_rate")
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("url-seen filter: %w", err)
+	}
+
+	vals := stored.Val()
+	if vals[0] != capacity || vals[1] != rate {
+		return fmt.Errorf("url-seen filter was built with capacity %v and false positive rate %v, configured %s and %s; "+
+			"restore them or start over with `scraper seen reset`", vals[0], vals[1], capacity, rate)
+	}
+	return nil
+}
+
+// Reset drops every layer and the stored parameters, so every url counts as
+// new again.
+func (f *Filter) Reset(ctx context.Context) error {
+	layers, err := f.rdb.HGet(ctx, MetaKey, "layers").Int()
+	if err == redis.Nil {
+		layers = 1
+	} else if err != nil {
+		return err
+	}
+
+	keys := []string{MetaKey}
+	for i := range layers {
+		keys = append(keys, BitsKey+strconv.Itoa(i))
+	}
+	if err := f.rdb.Del(ctx, keys...).Err(); err != nil {
+		return err
+	}
+
+	f.layers.Store(0)
+	return nil
+}
+
+// Add reports whether the url was new and records it.
+func (f *Filter) Add(ctx context.Context, item string) (bool, error) {
+	for range 3 {
+		layers := f.layers.Load()
+		if layers == 0 {
+			var err error
+			if layers, err = f.loadLayers(ctx); err != nil {
+				return false, err
+			}
+		}
+
+		args := []any{BitsKey, layers}
+		for i := 0; i <= int(layers); i++ {
+			l := layerParams(f.cfg, i)
+			args = append(args, l.capacity, l.k)
+			for _, p := range positions(item, l) {
+				args = append(args, p)
+			}
+		}
+
+		res, err := scripts.BloomAddScript.Run(ctx, f.rdb, []string{MetaKey}, args...).Int64()
+		if err != nil {
+			return false, fmt.Errorf("url-seen filter: %w", err)
+		}
+
+		switch res {
+		case 0:
+			return false, nil
+		case 1:
+			return true, nil
+		case 2:
+			f.layers.CompareAndSwap(layers, layers+1)
+			return true, nil
+		}
+
+		// another process added a layer
+		f.layers.Store(0)
+	}
+
+	return false, fmt.Errorf("url-seen filter: layer count kept changing")
+}
+
+// Contains reports whether the url was (probably) discovered without
+// recording it. A layer opened by another process since the last Add may be
+// missed; Add still has the final say.
+func (f *Filter) Contains(ctx context.Context, item string) (bool, error) {
+	layers := f.layers.Load()
+	if layers == 0 {
+		var err error
+		if layers, err = f.loadLayers(ctx); err != nil {
+			return false, err
+		}
+	}
+
+	pipe := f.rdb.Pipeline()
+	bits := make([][]*redis.IntCmd, layers)
+	for i := range bits {
+		for _, p := range positions(item, layerParams(f.cfg, i)) {
+			bits[i] = append(bits[i], pipe.GetBit(ctx, BitsKey+strconv.Itoa(i), int64(p)))
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, fmt.Errorf("url-seen filter: %w", err)
+	}
+
+	for _, layer := range bits {
+		all := true
+		for _, bit := range layer {
+			if bit.Val() == 0 {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *Filter) loadLayers(ctx context.Context) (int64, error) {
+	layers, err := f.rdb.HGet(ctx, MetaKey, "layers").Int64()
+	if err == redis.Nil {
+		layers = 1
+	} else if err != nil {
+		return 0, err
+	}
+
+	f.layers.Store(layers)
+	return layers, nil
+}
+
+func (f *Filter) Stats(ctx context.Context) (*Stats, error) {
+	meta, err := f.rdb.HGetAll(ctx, MetaKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	layers := 1
+	if v, ok := meta["layers"]; ok {
+		layers, _ = strconv.Atoi(v)
+	}
+
+	stats := &Stats{
+		Layers:            layers,
+		FalsePositiveRate: f.cfg.FalsePositiveRate,
+	}
+
+	// a lookup is a false positive when any layer says yes
+	allClear := 1.0
+	for i := range layers {
+		l := layerParams(f.cfg, i)
+		items, _ := strconv.ParseUint(meta["count:"+strconv.Itoa(i)], 10, 64)
+
+		stats.Items += items
+		stats.Bytes += l.bits / 8
+		allClear *= 1 - estimatedRate(l, items)
+	}
+	stats.EstimatedFalsePositiveRate = 1 - allClear
+
+	return stats, nil
+}
@@ -0,0 +1,76 @@
+package urlseen
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// testRedis connects to the scratch Redis at TEST_REDIS_ADDR, the test is
+// skipped when it is not set. The filter keys are cleared before and after
+// the test.
+func testRedis(t *testing.T) *redis.Client {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR not set")
+	}
+
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	ctx := context.Background()
+	if err := rdb.Ping(ctx).Err(); err != nil {
+		t.Fatalf("redis at %s: %v", addr, err)
+	}
+
+	reset := func() { NewFilter(rdb, DefaultConfig()).Reset(ctx) }
+	reset()
+	t.Cleanup(func() {
+		reset()
+		rdb.Close()
+	})
+
+	return rdb
+}
+
+func TestOpenRefusesOtherParameters(t *testing.T) {
+	ctx := context.Background()
+	rdb := testRedis(t)
+
+	cfg := DefaultConfig()
+	filter := NewFilter(rdb, cfg)
+	if err := filter.Open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if added, err := filter.Add(ctx, "https://go.dev/doc/"); err != nil || !added {
+		t.Fatalf("added %v, %v", added, err)
+	}
+
+	// the same parameters open again
+	if err := NewFilter(rdb, cfg).Open(ctx); err != nil {
+		t.Errorf("same parameters refused: %v", err)
+	}
+
+	other := cfg
+	other.Capacity *= 2
+	if err := NewFilter(rdb, other).Open(ctx); err == nil {
+		t.Error("another capacity should be refused")
+	}
+	other = cfg
+	other.FalsePositiveRate = 0.01
+	if err := NewFilter(rdb, other).Open(ctx); err == nil {
+		t.Error("another false positive rate should be refused")
+	}
+
+	// after a reset the new parameters are taken and the url is new again
+	if err := filter.Reset(ctx); err != nil {
+		t.Fatal(err)
+	}
+	filter = NewFilter(rdb, other)
+	if err := filter.Open(ctx); err != nil {
+		t.Fatalf("reset filter refused: %v", err)
+	}
+	if added, _ := filter.Add(ctx, "https://go.dev/doc/"); !added {
+		t.Error("url still seen after reset")
+	}
+}
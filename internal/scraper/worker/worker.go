@@ -17,9 +17,29 @@ import (
 )
 
 const (
-	TaskTimeout = 5 * time.Minute
+	TaskTimeout       = 5 * time.Minute
+	DequeueTimeout    = 5 * time.Second
+	ReapInterval      = 30 * time.Second
+	HeartbeatInterval = 10 * time.Second
 )
 
+type Options struct {
+	TaskTimeout    time.Duration
+	DequeueTimeout time.Duration
+	ReapInterval   time.Duration
+	// a worker counts as dead after three missed heartbeats
+	HeartbeatInterval time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		TaskTimeout:       TaskTimeout,
+		DequeueTimeout:    DequeueTimeout,
+		ReapInterval:      ReapInterval,
+		HeartbeatInterval: HeartbeatInterval,
+	}
+}
+
 type ExecFunc func(ctx context.Context, job *queues.Job) error
 
 type Worker struct {
@@ -33,30 +53,57 @@ type Worker struct {
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 
-	concurrency int
-	timeout     time.Duration
+	concurrency       int
+	timeout           time.Duration
+	dequeueTimeout    time.Duration
+	reapInterval      time.Duration
+	heartbeatInterval time.Duration
+	heartbeatWg       sync.WaitGroup
 }
 
-func NewWorker(workerName string, queue *queues.Queue, queues []string, concurrency int, exec ExecFunc) *Worker {
+func NewWorker(workerName string, queue *queues.Queue, queues []string, concurrency int, exec ExecFunc, opts Options) *Worker {
 	ctx, cancel := context.WithCancel(context.Background())
 	workerID := fmt.Sprintf("%s-%s", workerName, uuid.New().String())
 	return &Worker{
-		ID:          workerID,
-		queue:       queue,
-		execFunc:    exec,
-		queues:      queues,
-		ctx:         ctx,
-		cancel:      cancel,
-		concurrency: concurrency,
-		timeout:     TaskTimeout,
+		ID:             workerID,
+		queue:          queue,
+		execFunc:       exec,
+		queues:         queues,
+		ctx:            ctx,
+		cancel:         cancel,
+		concurrency:    concurrency,
+		timeout:        opts.TaskTimeout,
+		dequeueTimeout: opts.DequeueTimeout,
+		reapInterval:   opts.ReapInterval,
+
+		heartbeatInterval: opts.HeartbeatInterval,
 	}
 }
 
 func (w *Worker) Start() {
 	log.Printf("Worker %s starting with %d concurrent processors", w.ID, w.concurrency)
+
+	w.heartbeat()
+
+	w.heartbeatWg.Add(1)
+	go func() {
+		defer w.heartbeatWg.Done()
+		ticker := time.NewTicker(w.heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-ticker.C:
+				w.heartbeat()
+			}
+		}
+	}()
+
 	// start processing reaper in the background
 	go func() {
-		ticker := time.NewTicker(30 * time.Second)
+		ticker := time.NewTicker(w.reapInterval)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-w.ctx.Done():
@@ -66,6 +113,10 @@ func (w *Worker) Start() {
 				if err != nil {
 					log.Printf("Worker %s: reaper error: %v", w.ID, err)
 				}
+
+				if _, err := w.queue.ReclaimOrphans(); err != nil {
+					log.Printf("Worker %s: orphan recovery error: %v", w.ID, err)
+				}
 			}
 		}
 	}()
@@ -82,9 +133,20 @@ func (w *Worker) Stop() {
 	log.Printf("Worker %s stopping...", w.ID)
 	w.cancel()  // cancels context
 	w.wg.Wait() // waits for all goroutines/ tasks to complete
+	w.heartbeatWg.Wait()
+
+	if err := w.queue.Deregister(w.ID); err != nil {
+		log.Printf("Worker %s: deregister error: %v", w.ID, err)
+	}
 	log.Printf("Worker %s stopped", w.ID)
 }
 
+func (w *Worker) heartbeat() {
+	if err := w.queue.Heartbeat(w.ID, 3*w.heartbeatInterval); err != nil {
+		log.Printf("Worker %s: heartbeat error: %v", w.ID, err)
+	}
+}
+
 func (w *Worker) processTasks() {
 	defer w.wg.Done()
 
@@ -94,7 +156,7 @@ func (w *Worker) processTasks() {
 			return
 		default:
 		}
-		job, err := w.queue.Dequeue(w.queues, w.ID, 5*time.Second)
+		job, err := w.queue.Dequeue(w.queues, w.ID, w.dequeueTimeout)
 		if err != nil {
 			log.Printf("Worker %s: Error dequeuing job: %v", w.ID, err)
 			time.Sleep(time.Second)
@@ -114,7 +176,7 @@ func (w *Worker) processTask(job *queues.Job) {
 	start := time.Now()
 
 	log.Printf("Worker %s: Processing job %s (url: %s, attempt: %d/%d)",
-		w.ID, job.ID, job.URL, job.RetryCount+1, queues.MAX_RETRIES+1)
+		w.ID, job.ID, job.URL, job.RetryCount+1, w.queue.MaxRetries()+1)
 
 	ctx, cancel := context.WithTimeout(w.ctx, w.timeout)
 	defer cancel()
@@ -178,13 +240,15 @@ func (w *Worker) completeTask(job *queues.Job, success bool, retryLater bool, er
 			}
 		}
 
-		if err := w.queue.RequeueWithDelay(job, delay); err != nil {
+		// a deferred job isn't a failed attempt, so it skips CompleteJob
+		if err := w.queue.Defer(job, delay, w.ID); err != nil {
 			log.Printf("Worker %s: Requeue error: %v", w.ID, err)
 		}
+		return
 	} else {
 		stats.IncrementError()
 		log.Printf("Worker %s: Job %s for %s failed: %s (attempt %d/%d)",
-			w.ID, job.ID, job.URL, errorMsg, job.RetryCount+1, queues.MAX_RETRIES+1)
+			w.ID, job.ID, job.URL, errorMsg, job.RetryCount+1, w.queue.MaxRetries()+1)
 	}
 
 	if err := w.queue.CompleteJob(job, result, w.ID); err != nil {
@@ -18,3 +18,50 @@ CREATE TABLE IF NOT EXISTS inverted_index (
 
 CREATE INDEX IF NOT EXISTS idx_term ON inverted_index(term);
 CREATE INDEX IF NOT EXISTS idx_doc ON inverted_index(content_hash);
+
+-- number of postings written with the document; a mismatch with
+-- inverted_index marks a half-indexed document
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS posting_count INT;
+
+UPDATE documents d
+SET posting_count = (SELECT count(*) FROM inverted_index i WHERE i.content_hash = d.content_hash)
+WHERE d.posting_count IS NULL
+  AND EXISTS (SELECT 1 FROM inverted_index i WHERE i.content_hash = d.content_hash);
+
+-- idempotency keys: one row per indexed stream message and content
+CREATE TABLE IF NOT EXISTS processed_messages (
+    message_id TEXT,
+    content_hash TEXT,
+    processed_at TIMESTAMP DEFAULT NOW(),
+
+    PRIMARY KEY (message_id, content_hash)
+);
+
+-- message ids are not stream ids, an index casting their prefix to a number
+-- broke every insert
+DROP INDEX IF EXISTS idx_processed_ms;
+
+-- rows are pruned by age once the stream no longer holds the message
+CREATE INDEX IF NOT EXISTS idx_processed_at ON processed_messages(processed_at);
+
+-- small bits of indexer state, e.g. how far the repair scan got
+CREATE TABLE IF NOT EXISTS indexer_state (
+    name TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+-- near-duplicate clusters: only the canonical page is indexed, the aliases
+-- are the other urls it can be found at
+CREATE TABLE IF NOT EXISTS document_clusters (
+    cluster TEXT PRIMARY KEY,
+    canonical_url TEXT NOT NULL,
+    version INT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS document_aliases (
+    url TEXT PRIMARY KEY,
+    cluster TEXT NOT NULL,
+    canonical_url TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_alias_canonical ON document_aliases(canonical_url);
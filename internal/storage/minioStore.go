@@ -72,7 +72,7 @@ func (m *MinioStore) StoreRawData(ctx context.Context, raw []byte, url string, t
 	return contentPath, nil
 }
 
-func (m *MinioStore) StoreTextData(ctx context.Context, text string, hash uint64) error {
+func (m *MinioStore) StoreTextData(ctx context.Context, text string, hash uint64) (string, error) {
 	contentPath := fmt.Sprintf("text/%d", hash)
 	textBytes := []byte(text)
 
@@ -80,7 +80,11 @@ func (m *MinioStore) StoreTextData(ctx context.Context, text string, hash uint64
 		ContentType: "text",
 	})
 
-	return err
+	if err != nil {
+		return "", err
+	}
+
+	return contentPath, nil
 }
 
 func (m *MinioStore) GetObject(ctx context.Context, objectName string) ([]byte, error) {
@@ -97,3 +101,20 @@ func (m *MinioStore) GetObject(ctx context.Context, objectName string) ([]byte,
 
 	return data, nil
 }
+
+func (m *MinioStore) DeleteObject(ctx context.Context, objectName string) error {
+	return m.Client.RemoveObject(ctx, m.Bucket, objectName, minio.RemoveObjectOptions{})
+}
+
+func (m *MinioStore) PutObject(ctx context.Context, objectName string, data []byte, contentType string) error {
+	_, err := m.Client.PutObject(ctx, m.Bucket, objectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+
+	return err
+}
+
+// IsNotExist reports whether err means the object does not exist.
+func IsNotExist(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
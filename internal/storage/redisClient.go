@@ -2,14 +2,35 @@ package storage
 
 import (
 	"os"
+	"sync"
 
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	redisMu     sync.Mutex
+	redisAddr   = os.Getenv("REDIS_ADDR")
+	redisClient *redis.Client
+)
+
+// ConfigureRedis sets the address used by GetRedisClient. It has to run before
+// the first client is handed out.
+func ConfigureRedis(addr string) {
+	redisMu.Lock()
+	defer redisMu.Unlock()
+
+	redisAddr = addr
+	redisClient = nil
+}
+
 func GetRedisClient() *redis.Client {
+	redisMu.Lock()
+	defer redisMu.Unlock()
 
-	rdb := redis.NewClient(&redis.Options{
-		Addr: os.Getenv("REDIS_ADDR"),
-	})
-	return rdb
+	if redisClient == nil {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr: redisAddr,
+		})
+	}
+	return redisClient
 }
@@ -0,0 +1,169 @@
+package streams
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DeadStreamKey is the dead-letter stream of a namespace, e.g. parser-dead.
+const DeadStreamKey = "%s-dead"
+
+// DeadLetterFilter selects entries of the dead-letter stream. Zero fields
+// match everything.
+type DeadLetterFilter struct {
+	IDs   []string // message ids or dead-letter stream ids
+	Error string   // case-insensitive substring of the last error
+}
+
+func (f DeadLetterFilter) Empty() bool {
+	return len(f.IDs) == 0 && f.Error == ""
+}
+
+func (f DeadLetterFilter) matches(entry *DeadLetter) bool {
+	if len(f.IDs) > 0 && !slices.Contains(f.IDs, entry.Msg.ID) && !slices.Contains(f.IDs, entry.DeadID) {
+		return false
+	}
+	if f.Error != "" && !strings.Contains(strings.ToLower(entry.Msg.LastError), strings.ToLower(f.Error)) {
+		return false
+	}
+	return true
+}
+
+type DeadLetter struct {
+	DeadID string `json:"dead_id"`
+	Msg    *Msg   `json:"msg"`
+}
+
+func (ms *MsgStream) DeadStreamName() string {
+	return fmt.Sprintf(DeadStreamKey, ms.namespace)
+}
+
+func (ms *MsgStream) deadLetter(msg *Msg) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead msg: %w", err)
+	}
+
+	err = ms.client.XAdd(ms.ctx, &redis.XAddArgs{
+		Stream: ms.DeadStreamName(),
+		Values: map[string]any{
+			"data": data,
+		},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter message %s: %w", msg.ID, err)
+	}
+
+	return nil
+}
+
+// DeadLetterCount is the length of the dead-letter stream.
+func (ms *MsgStream) DeadLetterCount() (int64, error) {
+	return ms.client.XLen(ms.ctx, ms.DeadStreamName()).Result()
+}
+
+// DeadLetters lists the dead-letter entries matching the filter, newest first.
+func (ms *MsgStream) DeadLetters(filter DeadLetterFilter) ([]DeadLetter, error) {
+	entries, err := ms.client.XRevRange(ms.ctx, ms.DeadStreamName(), "+", "-").Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var dead []DeadLetter
+
+	for _, entry := range entries {
+		msg, ok := decodeMsg(entry.Values["data"])
+		if !ok {
+			continue
+		}
+
+		d := DeadLetter{DeadID: entry.ID, Msg: msg}
+		if filter.matches(&d) {
+			dead = append(dead, d)
+		}
+	}
+
+	return dead, nil
+}
+
+// Redrive moves matching dead letters back onto the live stream with a fresh
+// retry budget. The attempt history is kept. An entry is only deleted from the
+// dead-letter stream once it is published again, so a failed publish never
+// loses it; two concurrent re-drives of one entry may both publish it, which
+// the indexer tolerates.
+func (ms *MsgStream) Redrive(filter DeadLetterFilter) (int, error) {
+	dead, err := ms.DeadLetters(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	redriven := 0
+
+	for _, entry := range dead {
+		msg := entry.Msg
+		msg.RetryCount = 0
+		msg.Status = READY
+		msg.DeadAt = time.Time{}
+		msg.StreamID = ""
+
+		if err := ms.AddMsg(msg); err != nil {
+			return redriven, err
+		}
+
+		removed, err := ms.client.XDel(ms.ctx, ms.DeadStreamName(), entry.DeadID).Result()
+		if err != nil {
+			return redriven, err
+		}
+		if removed == 0 {
+			continue
+		}
+
+		redriven++
+	}
+
+	return redriven, nil
+}
+
+// PurgeDeadLetters drops matching dead letters for good.
+func (ms *MsgStream) PurgeDeadLetters(filter DeadLetterFilter) (int, error) {
+	dead, err := ms.DeadLetters(filter)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+
+	for _, entry := range dead {
+		removed, err := ms.client.XDel(ms.ctx, ms.DeadStreamName(), entry.DeadID).Result()
+		if err != nil {
+			return purged, err
+		}
+		purged += int(removed)
+	}
+
+	return purged, nil
+}
+
+func decodeMsg(raw any) (*Msg, bool) {
+	var bytes []byte
+	switch v := raw.(type) {
+	case string:
+		bytes = []byte(v)
+	case []byte:
+		bytes = v
+	default:
+		return nil, false
+	}
+
+	var msg Msg
+	if err := json.Unmarshal(bytes, &msg); err != nil {
+		return nil, false
+	}
+
+	return &msg, true
+}
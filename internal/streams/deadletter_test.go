@@ -0,0 +1,46 @@
+package streams
+
+import "testing"
+
+func TestDeadLetterFilter(t *testing.T) {
+	entry := &DeadLetter{
+		DeadID: "1700000000000-0",
+		Msg:    &Msg{ID: "parser-stream-abc", LastError: "Msg processing timed out after 15m0s"},
+	}
+
+	cases := []struct {
+		name   string
+		filter DeadLetterFilter
+		want   bool
+	}{
+		{"empty", DeadLetterFilter{}, true},
+		{"message id", DeadLetterFilter{IDs: []string{"parser-stream-abc"}}, true},
+		{"dead id", DeadLetterFilter{IDs: []string{"1700000000000-0"}}, true},
+		{"other id", DeadLetterFilter{IDs: []string{"parser-stream-xyz"}}, false},
+		{"error", DeadLetterFilter{Error: "TIMED OUT"}, true},
+		{"other error", DeadLetterFilter{Error: "bucket"}, false},
+	}
+
+	for _, c := range cases {
+		if got := c.filter.matches(entry); got != c.want {
+			t.Errorf("%s: matches = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDecodeMsg(t *testing.T) {
+	msg, ok := decodeMsg(`{"id":"m1","retry_count":5,"attempts":[{"at":"2026-01-02T03:04:05Z","error":"boom"}]}`)
+	if !ok {
+		t.Fatal("expected message to decode")
+	}
+	if msg.ID != "m1" || len(msg.Attempts) != 1 || msg.Attempts[0].Error != "boom" {
+		t.Errorf("unexpected message %+v", msg)
+	}
+
+	if _, ok := decodeMsg(42); ok {
+		t.Error("non-string values must be rejected")
+	}
+	if _, ok := decodeMsg("not json"); ok {
+		t.Error("invalid json must be rejected")
+	}
+}
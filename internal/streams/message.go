@@ -21,11 +21,21 @@ const (
 type Msg struct {
 	ID            string          `json:"id"`
 	StreamID      string          `json:"stream_id"`
+	OriginID      string          `json:"origin_id,omitempty"` // stream id of the first delivery
 	RetryCount    int             `json:"retry_count"`
 	Payload       json.RawMessage `json:"payload"`
 	Status        MsgStatus       `json:"status"`
 	AddedAt       time.Time       `json:"added_at"`
 	LastFetchedAt time.Time       `json:"last_fetched_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	Attempts      []Attempt       `json:"attempts,omitempty"`
+	DeadAt        time.Time       `json:"dead_at,omitzero"`
+}
+
+// Attempt is one failed processing run of a message.
+type Attempt struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error"`
 }
 
 func NewMsg(payload []byte, streamer string) *Msg {
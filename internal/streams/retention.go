@@ -0,0 +1,318 @@
+package streams
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// entries over max_len a trim reads to find its cut, a bigger excess is
+// worked off over several trims
+const trimLenBatch = 10_000
+
+// Config bounds how much history a stream keeps. Entries are only trimmed
+// once every consumer group has acknowledged them.
+type Config struct {
+	MaxLen            int64         `json:"max_len" yaml:"max_len"` // 0 keeps any number of entries
+	MaxAge            time.Duration `json:"max_age" yaml:"max_age"` // 0 keeps entries of any age
+	TrimInterval      time.Duration `json:"trim_interval" yaml:"trim_interval"`
+	StaleConsumerIdle time.Duration `json:"stale_consumer_idle" yaml:"stale_consumer_idle"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxLen:            100_000,
+		MaxAge:            72 * time.Hour,
+		TrimInterval:      time.Minute,
+		StaleConsumerIdle: time.Hour,
+	}
+}
+
+type ConsumerStatus struct {
+	Name    string        `json:"name"`
+	Pending int64         `json:"pending"`
+	Idle    time.Duration `json:"idle"`
+}
+
+type GroupStatus struct {
+	Name             string           `json:"name"`
+	Pending          int64            `json:"pending"`
+	OldestPendingID  string           `json:"oldest_pending_id,omitempty"`
+	OldestPendingAge time.Duration    `json:"oldest_pending_age"`
+	LastDeliveredID  string           `json:"last_delivered_id"`
+	Lag              int64            `json:"lag"` // undelivered entries, -1 when redis cannot tell
+	Consumers        []ConsumerStatus `json:"consumers"`
+}
+
+type StreamStatus struct {
+	Stream     string        `json:"stream"`
+	Length     int64         `json:"length"`
+	FirstID    string        `json:"first_id,omitempty"`
+	LastID     string        `json:"last_id,omitempty"`
+	Retrying   int64         `json:"retrying"`
+	DeadLetter int64         `json:"dead_letter"`
+	Groups     []GroupStatus `json:"groups"`
+}
+
+// Status reports length, per-group pending counts and consumer idle times
+// from XINFO and XPENDING.
+func (ms *MsgStream) Status() (*StreamStatus, error) {
+	info, err := ms.client.XInfoStream(ms.ctx, ms.streamName).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &StreamStatus{
+		Stream:  ms.streamName,
+		Length:  info.Length,
+		FirstID: info.FirstEntry.ID,
+		LastID:  info.LastEntry.ID,
+	}
+
+	if status.Retrying, err = ms.RetryCount(); err != nil {
+		return nil, err
+	}
+	if status.DeadLetter, err = ms.DeadLetterCount(); err != nil {
+		return nil, err
+	}
+
+	groups, err := ms.client.XInfoGroups(ms.ctx, ms.streamName).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	for _, g := range groups {
+		group := GroupStatus{
+			Name:            g.Name,
+			Pending:         g.Pending,
+			LastDeliveredID: g.LastDeliveredID,
+			Lag:             g.Lag,
+		}
+
+		if g.Pending > 0 {
+			pending, err := ms.client.XPending(ms.ctx, ms.streamName, g.Name).Result()
+			if err != nil {
+				return nil, err
+			}
+			group.OldestPendingID = pending.Lower
+			if added, ok := idTime(pending.Lower); ok {
+				group.OldestPendingAge = now.Sub(added).Round(time.Second)
+			}
+		}
+
+		consumers, err := ms.client.XInfoConsumers(ms.ctx, ms.streamName, g.Name).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range consumers {
+			group.Consumers = append(group.Consumers, ConsumerStatus{
+				Name:    c.Name,
+				Pending: c.Pending,
+				Idle:    c.Idle,
+			})
+		}
+
+		status.Groups = append(status.Groups, group)
+	}
+
+	return status, nil
+}
+
+// Trim drops entries outside the retention window that every group has
+// already acknowledged. Entries still pending in, or not yet delivered to,
+// any group are kept whatever their age. Returns the number of entries
+// removed.
+func (ms *MsgStream) Trim(cfg Config) (int64, error) {
+	floor := ""
+
+	if cfg.MaxAge > 0 {
+		floor = fmt.Sprintf("%d-0", time.Now().Add(-cfg.MaxAge).UnixMilli())
+	}
+
+	if cfg.MaxLen > 0 {
+		length, err := ms.client.XLen(ms.ctx, ms.streamName).Result()
+		if err != nil {
+			return 0, err
+		}
+
+		// only the entries past the bound are read, a few at a time
+		if over := length - cfg.MaxLen; over > 0 {
+			oldest, err := ms.client.XRangeN(ms.ctx, ms.streamName, "-", "+", min(over, trimLenBatch)).Result()
+			if err != nil {
+				return 0, err
+			}
+			if len(oldest) > 0 {
+				floor = maxID(floor, nextID(oldest[len(oldest)-1].ID))
+			}
+		}
+	}
+
+	if floor == "" {
+		return 0, nil
+	}
+
+	groups, err := ms.client.XInfoGroups(ms.ctx, ms.streamName).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	var safe []string
+	for _, g := range groups {
+		if g.Pending == 0 {
+			safe = append(safe, nextID(g.LastDeliveredID))
+			continue
+		}
+
+		pending, err := ms.client.XPending(ms.ctx, ms.streamName, g.Name).Result()
+		if err != nil {
+			return 0, err
+		}
+		safe = append(safe, pending.Lower)
+	}
+
+	minID := trimBound(floor, safe)
+
+	// approximate trimming only removes whole nodes below minID, so it never
+	// goes further than the exact bound
+	trimmed, err := ms.client.XTrimMinIDApprox(ms.ctx, ms.streamName, minID, 0).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	if trimmed > 0 {
+		log.Printf("Trimmed %d entries below %s from %s", trimmed, minID, ms.streamName)
+	}
+	return trimmed, nil
+}
+
+// Floor is when the oldest entry still in the stream was added, or now for
+// an empty stream. Messages added before it can't be delivered again.
+func (ms *MsgStream) Floor() (time.Time, error) {
+	now := time.Now()
+
+	info, err := ms.client.XInfoStream(ms.ctx, ms.streamName).Result()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if added, ok := idTime(info.FirstEntry.ID); ok && added.Before(now) {
+		return added, nil
+	}
+	return now, nil
+}
+
+// CleanupConsumers deletes consumers that own no pending entries and have
+// been idle longer than idle, e.g. those of indexers that restarted under a
+// new name. Consumers with pending entries are left for XAUTOCLAIM, deleting
+// them would drop their entries from the pending list.
+func (ms *MsgStream) CleanupConsumers(idle time.Duration) (int, error) {
+	groups, err := ms.client.XInfoGroups(ms.ctx, ms.streamName).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+
+	for _, g := range groups {
+		consumers, err := ms.client.XInfoConsumers(ms.ctx, ms.streamName, g.Name).Result()
+		if err != nil {
+			return removed, err
+		}
+
+		for _, c := range consumers {
+			if c.Pending > 0 || c.Idle < idle {
+				continue
+			}
+
+			if err := ms.client.XGroupDelConsumer(ms.ctx, ms.streamName, g.Name, c.Name).Err(); err != nil {
+				return removed, err
+			}
+			log.Printf("Removed stale consumer %s from %s (idle %v)", c.Name, g.Name, c.Idle.Round(time.Second))
+			removed++
+		}
+	}
+
+	return removed, nil
+}
+
+// trimBound is the MINID to trim to: the retention floor, held back by the
+// first entry each group still needs.
+func trimBound(floor string, safe []string) string {
+	bound := floor
+	for _, id := range safe {
+		if _, _, ok := parseID(id); ok && compareIDs(id, bound) < 0 {
+			bound = id
+		}
+	}
+	return bound
+}
+
+func parseID(id string) (ms, seq uint64, ok bool) {
+	msPart, seqPart, found := strings.Cut(id, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if !found {
+		return ms, 0, true
+	}
+	seq, err = strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return ms, seq, true
+}
+
+// compareIDs orders stream ids; unparsable ids sort first.
+func compareIDs(a, b string) int {
+	aMs, aSeq, aOk := parseID(a)
+	bMs, bSeq, bOk := parseID(b)
+
+	switch {
+	case !aOk && !bOk:
+		return 0
+	case !aOk:
+		return -1
+	case !bOk:
+		return 1
+	case aMs != bMs:
+		if aMs < bMs {
+			return -1
+		}
+		return 1
+	case aSeq != bSeq:
+		if aSeq < bSeq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func maxID(a, b string) string {
+	if a == "" || compareIDs(b, a) > 0 {
+		return b
+	}
+	return a
+}
+
+// nextID is the smallest id after id.
+func nextID(id string) string {
+	ms, seq, ok := parseID(id)
+	if !ok {
+		return "0-0"
+	}
+	return fmt.Sprintf("%d-%d", ms, seq+1)
+}
+
+func idTime(id string) (time.Time, bool) {
+	ms, _, ok := parseID(id)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(int64(ms)), true
+}
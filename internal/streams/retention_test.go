@@ -0,0 +1,62 @@
+package streams
+
+import "testing"
+
+func TestCompareIDs(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"1-0", "1-0", 0},
+		{"1-0", "1-1", -1},
+		{"2-0", "10-0", -1},
+		{"10-5", "2-9", 1},
+		{"5", "5-0", 0},
+		{"bad", "0-0", -1},
+	}
+
+	for _, c := range cases {
+		if got := compareIDs(c.a, c.b); got != c.want {
+			t.Errorf("compareIDs(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestNextID(t *testing.T) {
+	if got := nextID("1700000000000-3"); got != "1700000000000-4" {
+		t.Errorf("nextID = %q", got)
+	}
+	if got := nextID("0-0"); got != "0-1" {
+		t.Errorf("nextID of an empty group = %q", got)
+	}
+}
+
+func TestTrimBound(t *testing.T) {
+	cases := []struct {
+		name  string
+		floor string
+		safe  []string
+		want  string
+	}{
+		{"all acked", "100-0", []string{"200-1"}, "100-0"},
+		{"pending entry holds back", "100-0", []string{"50-2", "300-0"}, "50-2"},
+		{"undelivered entries hold back", "100-0", []string{"80-1"}, "80-1"},
+		{"bad ids are ignored", "100-0", []string{""}, "100-0"},
+		{"no groups", "100-0", nil, "100-0"},
+	}
+
+	for _, c := range cases {
+		if got := trimBound(c.floor, c.safe); got != c.want {
+			t.Errorf("%s: trimBound = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMaxID(t *testing.T) {
+	if got := maxID("", "5-0"); got != "5-0" {
+		t.Errorf("maxID with no floor = %q", got)
+	}
+	if got := maxID("7-0", "5-0"); got != "7-0" {
+		t.Errorf("maxID = %q", got)
+	}
+}
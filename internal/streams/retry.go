@@ -0,0 +1,101 @@
+package streams
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/KingrogKDR/Dev-Search/internal/scraper/scripts"
+	"github.com/redis/go-redis/v9"
+)
+
+// RetryKey is the sorted set of failed messages waiting for their next
+// attempt, scored by due time in milliseconds, e.g. parser-retry.
+const RetryKey = "%s-retry"
+
+const retryMoveBatch = 500
+
+// Backoff spaces out the attempts of a failing message. The n-th retry waits
+// Base * 2^(n-1), capped at Max, with the upper half of that randomised so
+// messages that failed together do not come back together.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func DefaultBackoff() Backoff {
+	return Backoff{
+		Base: time.Second,
+		Max:  5 * time.Minute,
+	}
+}
+
+// Delay is the wait before retry n (starting at 1). r is a uniform value in
+// [0, 1).
+func (b Backoff) Delay(n int, r float64) time.Duration {
+	d := b.Base
+	for i := 1; i < n && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max || d <= 0 {
+		d = b.Max
+	}
+
+	half := d / 2
+	return half + time.Duration(r*float64(d-half))
+}
+
+func (ms *MsgStream) RetryKey() string {
+	return fmt.Sprintf(RetryKey, ms.namespace)
+}
+
+// scheduleRetry parks a failed message until its backoff has passed.
+// ProcessRetries puts it back on the stream.
+func (ms *MsgStream) scheduleRetry(msg *Msg, data []byte) error {
+	due := time.Now().Add(ms.backoff.Delay(msg.RetryCount, rand.Float64()))
+
+	err := ms.client.ZAdd(ms.ctx, ms.RetryKey(), redis.Z{
+		Score:  float64(due.UnixMilli()),
+		Member: data,
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to schedule retry of message %s: %w", msg.ID, err)
+	}
+
+	return nil
+}
+
+// ProcessRetries re-publishes due retries to the stream. Safe to run from any
+// number of workers at once.
+func (ms *MsgStream) ProcessRetries() (int, error) {
+	moved := 0
+
+	for {
+		n, err := scripts.StreamRetryScript.Run(
+			ms.ctx,
+			ms.client,
+			[]string{ms.RetryKey(), ms.streamName},
+			time.Now().UnixMilli(),
+			retryMoveBatch,
+		).Int()
+		if err != nil {
+			return moved, err
+		}
+
+		moved += n
+		if n < retryMoveBatch {
+			break
+		}
+	}
+
+	if moved > 0 {
+		log.Printf("Re-published %d retried messages to %s", moved, ms.streamName)
+	}
+	return moved, nil
+}
+
+// RetryCount is the number of messages waiting for a retry.
+func (ms *MsgStream) RetryCount() (int64, error) {
+	return ms.client.ZCard(ms.ctx, ms.RetryKey()).Result()
+}
@@ -0,0 +1,31 @@
+package streams
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Base: time.Second, Max: time.Minute}
+
+	cases := []struct {
+		n        int
+		min, max time.Duration
+	}{
+		{0, 500 * time.Millisecond, time.Second},
+		{1, 500 * time.Millisecond, time.Second},
+		{2, time.Second, 2 * time.Second},
+		{4, 4 * time.Second, 8 * time.Second},
+		{7, 30 * time.Second, time.Minute},
+		{100, 30 * time.Second, time.Minute},
+	}
+
+	for _, c := range cases {
+		if got := b.Delay(c.n, 0); got != c.min {
+			t.Errorf("Delay(%d, 0) = %v, want %v", c.n, got, c.min)
+		}
+		if got := b.Delay(c.n, 0.999999); got < c.min || got > c.max {
+			t.Errorf("Delay(%d, ~1) = %v, want within [%v, %v]", c.n, got, c.min, c.max)
+		}
+	}
+}
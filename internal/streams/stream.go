@@ -21,6 +21,7 @@ type MsgStream struct {
 	groupName  string
 	client     *redis.Client
 	ctx        context.Context
+	backoff    Backoff
 }
 
 func NewMsgStream(client *redis.Client, namespace string, consumerGroup string) *MsgStream {
@@ -33,12 +34,18 @@ func NewMsgStream(client *redis.Client, namespace string, consumerGroup string)
 		groupName:  groupName,
 		client:     client,
 		ctx:        context.Background(),
+		backoff:    DefaultBackoff(),
 	}
 	ms.ensureGroup(streamName, groupName)
 
 	return ms
 }
 
+// SetBackoff changes how long failed messages wait before their next attempt.
+func (ms *MsgStream) SetBackoff(b Backoff) {
+	ms.backoff = b
+}
+
 func (ms *MsgStream) ensureGroup(streamName, groupName string) {
 	err := ms.client.XGroupCreateMkStream(ms.ctx, streamName, groupName, "0").Err()
 	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
@@ -130,28 +137,13 @@ func (ms *MsgStream) GetMsg(consumer string) ([]Msg, error) {
 
 	for _, stream := range streams {
 		for _, message := range stream.Messages {
-			raw, ok := message.Values["data"]
+			msg, ok := decodeMsg(message.Values["data"])
 			if !ok {
 				continue
 			}
-
-			var bytes []byte
-			switch v := raw.(type) {
-			case string:
-				bytes = []byte(v)
-			case []byte:
-				bytes = v
-			default:
-				continue
-			}
-
-			var msg Msg
-			if err := json.Unmarshal(bytes, &msg); err != nil {
-				continue
-			}
 			msg.StreamID = message.ID
 			msg.LastFetchedAt = time.Now()
-			result = append(result, msg)
+			result = append(result, *msg)
 
 		}
 	}
@@ -161,41 +153,50 @@ func (ms *MsgStream) GetMsg(consumer string) ([]Msg, error) {
 	return result, nil
 }
 
-func (ms *MsgStream) CompleteMessage(msg *Msg, success bool, streamName string, groupName string) error {
-	if err := ms.client.XAck(ms.ctx, streamName, groupName, msg.StreamID).Err(); err != nil {
-		return fmt.Errorf("failed to XACK message %s: %w", msg.StreamID, err)
-	}
+// CompleteMessage acks a processed message. Failed messages wait in the
+// retry set for their backoff, without holding up the worker, until
+// MAX_RETRIES is used up and are then moved to the dead-letter stream with
+// their attempt history. The message is written before it is acked so a
+// crash in between redelivers it instead of losing it.
+func (ms *MsgStream) CompleteMessage(msg *Msg, success bool, errMsg string, streamName string, groupName string) error {
 	if !success {
 		msg.RetryCount++
-		time.Sleep(time.Duration(msg.RetryCount) * time.Second)
+		msg.LastError = errMsg
+		msg.Attempts = append(msg.Attempts, Attempt{At: time.Now(), Error: errMsg})
+		if msg.OriginID == "" {
+			msg.OriginID = msg.StreamID
+		}
 
 		if msg.RetryCount <= MAX_RETRIES {
+			msg.Status = FAILED
+
 			data, err := json.Marshal(msg)
 			if err != nil {
 				return fmt.Errorf("failed to marshal retry msg: %w", err)
 			}
 
-			err = ms.client.XAdd(ms.ctx, &redis.XAddArgs{
-				Stream: streamName,
-				Values: map[string]interface{}{
-					"data": data,
-				},
-			}).Err()
-			if err != nil {
-				return fmt.Errorf("failed to requeue message: %w", err)
+			if err := ms.scheduleRetry(msg, data); err != nil {
+				return err
 			}
+		} else {
+			msg.Status = DEAD
+			msg.DeadAt = time.Now()
 
-			return nil
+			if err := ms.deadLetter(msg); err != nil {
+				return err
+			}
+			log.Printf("Message %s is dead after %d attempts: %s", msg.ID, len(msg.Attempts), errMsg)
 		}
+	}
 
-		msg.Status = DEAD
-		log.Printf("Message %s is dead!", msg.ID)
-		return nil
+	if err := ms.client.XAck(ms.ctx, streamName, groupName, msg.StreamID).Err(); err != nil {
+		return fmt.Errorf("failed to XACK message %s: %w", msg.StreamID, err)
 	}
 
-	msg.Status = DONE
-	log.Printf("Message %s successfully processed!", msg.ID)
+	if success {
+		msg.Status = DONE
+		log.Printf("Message %s successfully processed!", msg.ID)
+	}
 
 	return nil
-
 }